@@ -37,12 +37,17 @@ import (
 	"github.com/kcp-dev/kcp/pkg/apis/tenancy/v1alpha1/helper"
 	conditionsutil "github.com/kcp-dev/kcp/pkg/apis/third_party/conditions/util/conditions"
 	kcp "github.com/kcp-dev/kcp/pkg/client/clientset/versioned"
+	kcpinformer "github.com/kcp-dev/kcp/pkg/client/informers/externalversions"
 	"github.com/kcp-dev/logicalcluster/v2"
 
 	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	"github.com/kuadrant/kcp-glbc/pkg/admission"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 	kuadrantv1 "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/clientset/versioned"
 	kuadrantinformer "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/informers/externalversions"
 	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/healthcheck"
 	"github.com/kuadrant/kcp-glbc/pkg/domains/domainverification"
 	"github.com/kuadrant/kcp-glbc/pkg/metrics"
 	"github.com/kuadrant/kcp-glbc/pkg/migration/deployment"
@@ -71,10 +76,37 @@ var options struct {
 	Domain string
 	// The DNS provider
 	DNSProvider string
+	// The DNS zones the records are published to
+	DNSZones string
+	// The interval the published DNS records are checked for drift at
+	DNSDriftCheckInterval time.Duration
+	// Whether the drifted DNS records are re-applied
+	DNSDriftCorrection bool
+	// The interval the health of the health checked endpoints is polled from the DNS provider at
+	DNSHealthStatusInterval time.Duration
+	// The interval the orphaned health checks are garbage collected at
+	DNSHealthCheckSweepInterval time.Duration
+	// Whether the orphaned health checks are reported without being deleted
+	DNSHealthCheckSweepDryRun bool
+	// The nameservers to query instead of the system configured ones
+	Nameservers string
+	// Whether the DNS records are verified against the nameservers once propagated
+	DNSVerifyRecords bool
+	// The minimum TTL of the DNS records, in seconds
+	DNSMinTTL int
+	// The maximum TTL of the DNS records, in seconds
+	DNSMaxTTL int
+	// The workspace of the SyncTargets located for geo aware DNS
+	GeoSyncTargetWorkspace string
 	// The AWS Route53 region
 	Region string
 	// The port number of the metrics endpoint
 	MonitoringPort int
+	// The port number of the admission webhooks
+	WebhookPort int
+	// The TLS certificate and key files of the admission webhooks
+	WebhookTLSCertFile string
+	WebhookTLSKeyFile  string
 	// The glbc exports to use
 	ExportName string
 }
@@ -95,12 +127,27 @@ func init() {
 	flagSet.StringVar(&options.TLSProvider, "glbc-tls-provider", env.GetEnvString("GLBC_TLS_PROVIDER", "glbc-ca"), "The TLS certificate issuer, one of [glbc-ca, le-staging, le-production]")
 	// DNS management options
 	flagSet.StringVar(&options.Domain, "domain", env.GetEnvString("GLBC_DOMAIN", "dev.hcpapps.net"), "The domain to use to expose ingresses")
-	flag.StringVar(&options.DNSProvider, "dns-provider", env.GetEnvString("GLBC_DNS_PROVIDER", "fake"), "The DNS provider being used [aws, fake]")
+	flag.StringVar(&options.DNSProvider, "dns-provider", env.GetEnvString("GLBC_DNS_PROVIDER", "fake"), "The DNS provider being used [aws, azure, gcp, rfc2136, inmemory, fake]")
+	flagSet.StringVar(&options.DNSZones, "dns-zones", env.GetEnvString("GLBC_DNS_ZONES", ""), "Comma separated list of DNS zones the records are published to, by the domain of their DNS names, each as <domain>/<id> or <domain>/<key>=<value>[;<key>=<value>] to reference the zone by tags. Defaults to the AWS_DNS_PUBLIC_ZONE_ID zone for the domain")
+	flagSet.DurationVar(&options.DNSDriftCheckInterval, "dns-drift-check-interval", env.GetEnvDuration("GLBC_DNS_DRIFT_CHECK_INTERVAL", 0), "The interval the records published by the DNS provider are compared with the DNSRecord endpoints at, setting the Drifted condition (disabled when 0)")
+	flagSet.BoolVar(&options.DNSDriftCorrection, "dns-drift-correction", env.GetEnvBool("GLBC_DNS_DRIFT_CORRECTION", false), "Re-apply the DNS records that have drifted")
+	flagSet.DurationVar(&options.DNSHealthStatusInterval, "dns-health-status-interval", env.GetEnvDuration("GLBC_DNS_HEALTH_STATUS_INTERVAL", time.Minute), "The interval the health of the health checked endpoints is polled from the DNS provider at, reporting it on the DNSRecords and the traffic objects (disabled when 0)")
+	flagSet.DurationVar(&options.DNSHealthCheckSweepInterval, "dns-health-check-sweep-interval", env.GetEnvDuration("GLBC_DNS_HEALTH_CHECK_SWEEP_INTERVAL", 0), "The interval the health checks created by the DNS provider and no longer referenced by any endpoint are deleted at (disabled when 0)")
+	flagSet.BoolVar(&options.DNSHealthCheckSweepDryRun, "dns-health-check-sweep-dry-run", env.GetEnvBool("GLBC_DNS_HEALTH_CHECK_SWEEP_DRY_RUN", false), "Report the orphaned health checks without deleting them")
+	flagSet.StringVar(&options.GeoSyncTargetWorkspace, "geo-sync-target-workspace", env.GetEnvString("GLBC_GEO_SYNC_TARGET_WORKSPACE", ""), "The workspace of the SyncTargets labelled with their continent or region, enables geo aware DNS and the latency routing policy when set (\"*\" for all the workspaces)")
+	flagSet.StringVar(&options.Nameservers, "dns-nameservers", env.GetEnvString("GLBC_DNS_NAMESERVERS", ""), "Comma separated list of nameservers (host:port) to query instead of the system configured ones, e.g. the in-memory DNS provider server")
+	flagSet.BoolVar(&options.DNSVerifyRecords, "dns-verify-records", env.GetEnvBool("GLBC_DNS_VERIFY_RECORDS", false), "Verify that the DNS records are answered by the authoritative nameservers once propagated, setting the Verified condition")
+	flagSet.IntVar(&options.DNSMinTTL, "dns-min-ttl", env.GetEnvInt("GLBC_DNS_MIN_TTL", 10), "The minimum TTL, in seconds, of the DNS records set with the kuadrant.experimental/ttl annotation")
+	flagSet.IntVar(&options.DNSMaxTTL, "dns-max-ttl", env.GetEnvInt("GLBC_DNS_MAX_TTL", 86400), "The maximum TTL, in seconds, of the DNS records set with the kuadrant.experimental/ttl annotation (unbounded when 0)")
 
 	// // AWS Route53 options
 	flag.StringVar(&options.Region, "region", env.GetEnvString("AWS_REGION", "eu-central-1"), "the region we should target with AWS clients")
 	//  Observability options
 	flagSet.IntVar(&options.MonitoringPort, "monitoring-port", 8080, "The port of the metrics endpoint (can be set to \"0\" to disable the metrics serving)")
+	// Admission options
+	flagSet.IntVar(&options.WebhookPort, "webhook-port", env.GetEnvInt("GLBC_WEBHOOK_PORT", 0), "The port of the DNSRecord validating webhook (disabled when 0)")
+	flagSet.StringVar(&options.WebhookTLSCertFile, "webhook-tls-cert-file", env.GetEnvString("GLBC_WEBHOOK_TLS_CERT_FILE", ""), "The TLS certificate file of the admission webhooks")
+	flagSet.StringVar(&options.WebhookTLSKeyFile, "webhook-tls-key-file", env.GetEnvString("GLBC_WEBHOOK_TLS_KEY_FILE", ""), "The TLS private key file of the admission webhooks")
 
 	opts := log.Options{
 		EncoderConfigOptions: []log.EncoderConfigOption{
@@ -188,10 +235,32 @@ func main() {
 
 	exitOnError(err, "Failed to create TLS certificate controller")
 
+	// SyncTargets are located from their labels when geo aware DNS is enabled
+	var geoLocator dns.GeoLocator
+	var syncTargetInformerFactory kcpinformer.SharedInformerFactory
+	if options.GeoSyncTargetWorkspace != "" {
+		// The geolocation and latency records rely on the Route53 routing policies
+		if options.DNSProvider != "aws" {
+			exitOnError(fmt.Errorf("geo aware DNS is only supported by the aws DNS provider, got %q", options.DNSProvider), "Invalid geo sync target workspace")
+		}
+		syncTargetInformerFactory = kcpinformer.NewSharedInformerFactory(kcpClient.Cluster(logicalcluster.New(options.GeoSyncTargetWorkspace)), resyncPeriod)
+		geoLocator = dns.NewSyncTargetGeoLocator(syncTargetInformerFactory.Workload().V1alpha1().SyncTargets().Lister())
+	}
+
+	dnsZones, err := getDNSZones(options.DNSZones, options.Domain)
+	exitOnError(err, "Failed to parse the DNS zones")
+
+	// start serving the admission webhooks alongside the metrics
+	recordValidator := dns.NewRecordValidator(dnsZones, options.Domain, v1.TTL(options.DNSMinTTL), v1.TTL(options.DNSMaxTTL))
+	admissionServer, err := admission.NewServer(options.WebhookPort, options.WebhookTLSCertFile, options.WebhookTLSKeyFile, recordValidator)
+	exitOnError(err, "Failed to create admission webhooks server")
+	g.Go(admissionServer.Start)
+
 	apiExportNames := strings.Split(options.ExportName, ",")
 	log.Logger.Info(fmt.Sprintf("Instantiating controllers for APIExports: %v", apiExportNames))
 
 	var apiExportClusterInformers []APIExportClusterInformers
+	var kuadrantInformerFactories []kuadrantinformer.SharedInformerFactory
 	var controllers []Controller
 	for _, name := range apiExportNames {
 		glbcAPIExport, err := kcpClient.Cluster(logicalcluster.New(options.GLBCWorkspace)).ApisV1alpha1().APIExports().Get(ctx, name, metav1.GetOptions{})
@@ -219,10 +288,14 @@ func main() {
 		clusterInformers.SharedInformerFactory = kcpKubeInformerFactory
 		clusterInformers.KuadrantSharedInformerFactory = kcpKuadrantInformerFactory
 		clusterInformers.KCPDynamicInformerFactory = kcpDynamicInformerFactory
+		kuadrantInformerFactories = append(kuadrantInformerFactories, kcpKuadrantInformerFactory)
 
 		isControllerLeader := len(controllers) == 0
 
-		dnsClient, domainVerifier := getDNSUtilities(os.Getenv("GLBC_HOST_RESOLVER"))
+		nameservers := getNameservers(options.Nameservers)
+		dnsClient, domainVerifier := getDNSUtilities(os.Getenv("GLBC_HOST_RESOLVER"), nameservers)
+		recordVerifier := dns.NewRecordVerifier(nameservers...)
+		ttlBounds := traffic.TTLBounds{Min: v1.TTL(options.DNSMinTTL), Max: v1.TTL(options.DNSMaxTTL)}
 
 		routeController := route.NewController(&route.ControllerConfig{
 			ControllerConfig: &reconciler.ControllerConfig{
@@ -240,6 +313,10 @@ func main() {
 			Domain:                          options.Domain,
 			CertProvider:                    certProvider,
 			HostResolver:                    dnsClient,
+			RecordVerifier:                  recordVerifier,
+			TTLBounds:                       ttlBounds,
+			GeoLocator:                      geoLocator,
+			DNSZones:                        dnsZones,
 			GLBCWorkspace:                   logicalcluster.New(options.GLBCWorkspace),
 		})
 
@@ -259,6 +336,10 @@ func main() {
 			Domain:                   options.Domain,
 			CertProvider:             certProvider,
 			HostResolver:             dnsClient,
+			RecordVerifier:           recordVerifier,
+			TTLBounds:                ttlBounds,
+			GeoLocator:               geoLocator,
+			DNSZones:                 dnsZones,
 			GLBCWorkspace:            logicalcluster.New(options.GLBCWorkspace),
 		})
 		controllers = append(controllers, ingressController)
@@ -270,10 +351,26 @@ func main() {
 			DnsRecordClient:       kcpKuadrantClient,
 			SharedInformerFactory: kcpKuadrantInformerFactory,
 			DNSProvider:           options.DNSProvider,
+			DNSZones:              dnsZones,
+			DriftCheckInterval:    options.DNSDriftCheckInterval,
+			DriftCorrection:       options.DNSDriftCorrection,
+			HealthStatusInterval:  options.DNSHealthStatusInterval,
+			RecordVerifier:        getDNSRecordVerifier(options.DNSVerifyRecords, recordVerifier),
 		})
 		exitOnError(err, "Failed to create DNSRecord controller")
 		controllers = append(controllers, dnsRecordController)
 
+		healthCheckController, err := healthcheck.NewController(&healthcheck.ControllerConfig{
+			ControllerConfig: &reconciler.ControllerConfig{
+				NameSuffix: name,
+			},
+			HealthCheckClient:     kcpKuadrantClient,
+			SharedInformerFactory: kcpKuadrantInformerFactory,
+			DNSProvider:           options.DNSProvider,
+		})
+		exitOnError(err, "Failed to create HealthCheck controller")
+		controllers = append(controllers, healthCheckController)
+
 		domainVerificationController, err := domainverification.NewController(&domainverification.ControllerConfig{
 			ControllerConfig: &reconciler.ControllerConfig{
 				NameSuffix: name,
@@ -326,6 +423,18 @@ func main() {
 		apiExportClusterInformers = append(apiExportClusterInformers, *clusterInformers)
 	}
 
+	// The health checks are garbage collected across the APIExports, as they share the DNS provider
+	healthCheckSweeper, err := dns.NewHealthCheckSweeper(&dns.HealthCheckSweeperConfig{
+		DNSProvider:             options.DNSProvider,
+		SharedInformerFactories: kuadrantInformerFactories,
+		DNSZones:                dnsZones,
+		Interval:                options.DNSHealthCheckSweepInterval,
+		DryRun:                  options.DNSHealthCheckSweepDryRun,
+		OwnerID:                 env.GetEnvString(aws.OwnerIDEnvVar, ""),
+	})
+	exitOnError(err, "Failed to create health check sweeper")
+	controllers = append(controllers, healthCheckSweeper)
+
 	for _, clusterInformers := range apiExportClusterInformers {
 		clusterInformers.SharedInformerFactory.Start(ctx.Done())
 		clusterInformers.SharedInformerFactory.WaitForCacheSync(ctx.Done())
@@ -341,14 +450,21 @@ func main() {
 	certificateInformerFactory.WaitForCacheSync(ctx.Done())
 	glbcKubeInformerFactory.Start(ctx.Done())
 	glbcKubeInformerFactory.WaitForCacheSync(ctx.Done())
+	if syncTargetInformerFactory != nil {
+		syncTargetInformerFactory.Start(ctx.Done())
+		syncTargetInformerFactory.WaitForCacheSync(ctx.Done())
+	}
 
 	for _, controller := range controllers {
 		start(gCtx, controller)
 	}
 
 	g.Go(func() error {
-		// wait until the controllers have return before stopping serving metrics
+		// wait until the controllers have return before stopping serving metrics and admission webhooks
 		controllersGroup.Wait()
+		if err := admissionServer.Shutdown(); err != nil {
+			return err
+		}
 		return metricsServer.Shutdown()
 	})
 
@@ -395,11 +511,41 @@ func printOptions() {
 	}
 }
 
-func getDNSUtilities(hostResolverType string) (dns.HostResolver, domainverification.DNSVerifier) {
+func getNameservers(nameservers string) []string {
+	var result []string
+	for _, nameserver := range strings.Split(nameservers, ",") {
+		if nameserver = strings.TrimSpace(nameserver); nameserver != "" {
+			result = append(result, nameserver)
+		}
+	}
+	return result
+}
+
+// getDNSZones returns the DNS zones parsed from zones, or the zone set with the AWS_DNS_PUBLIC_ZONE_ID environment
+// variable for domain if zones is empty
+func getDNSZones(zones, domain string) (dns.Zones, error) {
+	if zones != "" {
+		return dns.ParseZones(zones)
+	}
+	if zoneID, ok := os.LookupEnv(aws.ZoneIDEnvVar); ok {
+		return dns.Zones{{Domain: domain, DNSZone: v1.DNSZone{ID: zoneID}}}, nil
+	}
+	return nil, nil
+}
+
+// getDNSRecordVerifier returns the verifier of the published DNS records, or nil if they are not verified
+func getDNSRecordVerifier(enabled bool, verifier dns.RecordVerifier) dns.RecordVerifier {
+	if !enabled {
+		return nil
+	}
+	return verifier
+}
+
+func getDNSUtilities(hostResolverType string, nameservers []string) (dns.HostResolver, domainverification.DNSVerifier) {
 	switch hostResolverType {
 	case "default":
 		log.Logger.Info("using default host resolver")
-		return dns.NewDefaultHostResolver(), dns.NewVerifier(gonet.DefaultResolver)
+		return dns.NewDefaultHostResolver(nameservers...), dns.NewVerifier(gonet.DefaultResolver)
 	case "e2e-mock":
 		log.Logger.Info("using e2e-mock host resolver")
 		resolver := &dns.ConfigMapHostResolver{
@@ -410,6 +556,6 @@ func getDNSUtilities(hostResolverType string) (dns.HostResolver, domainverificat
 		return resolver, resolver
 	default:
 		log.Logger.Info("using default host resolver")
-		return dns.NewDefaultHostResolver(), dns.NewVerifier(gonet.DefaultResolver)
+		return dns.NewDefaultHostResolver(nameservers...), dns.NewVerifier(gonet.DefaultResolver)
 	}
 }
@@ -13,7 +13,6 @@ require (
 	github.com/kcp-dev/kcp v0.9.0
 	github.com/kcp-dev/kcp/pkg/apis v0.9.0
 	github.com/kcp-dev/logicalcluster/v2 v2.0.0-alpha.3
-	github.com/lixiangzhong/dnsutil v1.4.0
 	github.com/miekg/dns v1.1.40
 	github.com/onsi/gomega v1.17.0
 	github.com/openshift/api v3.9.0+incompatible
@@ -23,6 +22,7 @@ require (
 	github.com/rs/xid v1.3.0
 	go.uber.org/zap v1.21.0
 	golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4
+	golang.org/x/time v0.0.0-20220210224613-90d013bbcef8
 	k8s.io/api v0.24.3
 	k8s.io/apimachinery v0.24.3
 	k8s.io/apiserver v0.24.3
@@ -126,11 +126,10 @@ require (
 	golang.org/x/exp v0.0.0-20221012134508-3640c57a48ea
 	golang.org/x/mod v0.6.0-dev.0.20220419223038-86c51ed26bb4 // indirect
 	golang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect
-	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8 // indirect
+	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8
 	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f // indirect
 	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211 // indirect
 	golang.org/x/text v0.3.7 // indirect
-	golang.org/x/time v0.0.0-20220210224613-90d013bbcef8 // indirect
 	golang.org/x/tools v0.1.12 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20220118154757-00ab72f36ad5 // indirect
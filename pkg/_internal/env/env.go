@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 const namespaceEnvVariable = "NAMESPACE"
@@ -39,6 +40,18 @@ func GetEnvInt(key string, fallback int) int {
 	return value
 }
 
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	strValue, found := os.LookupEnv(key)
+	if !found {
+		return fallback
+	}
+	value, err := time.ParseDuration(strValue)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 func GetNamespace() string {
 	return GetEnvString(namespaceEnvVariable, "")
 }
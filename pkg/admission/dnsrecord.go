@@ -0,0 +1,56 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+)
+
+var dnsRecordGroupKind = schema.GroupKind{Group: v1.SchemeGroupVersion.Group, Kind: "DNSRecord"}
+
+// NewDNSRecordValidationHandler returns the handler of the AdmissionReviews of DNSRecords, denying the creation and
+// update of the DNSRecords the validator returns errors for
+func NewDNSRecordValidationHandler(validator *dns.RecordValidator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := &admissionv1.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+			http.Error(w, fmt.Sprintf("invalid AdmissionReview: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		review.Response = validateDNSRecord(review.Request, validator)
+		review.Response.UID = review.Request.UID
+		review.Request = nil
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(review); err != nil {
+			log.Logger.Error(err, "Failed to write the AdmissionReview response")
+		}
+	})
+}
+
+func validateDNSRecord(request *admissionv1.AdmissionRequest, validator *dns.RecordValidator) *admissionv1.AdmissionResponse {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	record := &v1.DNSRecord{}
+	if err := json.Unmarshal(request.Object.Raw, record); err != nil {
+		return &admissionv1.AdmissionResponse{Result: &apierrors.NewBadRequest(err.Error()).ErrStatus}
+	}
+
+	if errs := validator.Validate(record); len(errs) > 0 {
+		log.Logger.V(3).Info("Denying invalid DNSRecord", "name", request.Name, "namespace", request.Namespace, "errors", errs.ToAggregate().Error())
+		return &admissionv1.AdmissionResponse{Result: &apierrors.NewInvalid(dnsRecordGroupKind, request.Name, errs).ErrStatus}
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true, Result: &metav1.Status{Status: metav1.StatusSuccess}}
+}
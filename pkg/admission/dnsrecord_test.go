@@ -0,0 +1,101 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+)
+
+func TestDNSRecordValidationHandler(t *testing.T) {
+	handler := NewDNSRecordValidationHandler(dns.NewRecordValidator(nil, "dev.hcpapps.net", 10, 3600))
+
+	cases := []struct {
+		Name          string
+		Operation     admissionv1.Operation
+		Endpoint      *v1.Endpoint
+		ExpectAllowed bool
+	}{
+		{
+			Name:          "valid record",
+			Operation:     admissionv1.Create,
+			Endpoint:      &v1.Endpoint{DNSName: "app.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 60},
+			ExpectAllowed: true,
+		},
+		{
+			Name:      "invalid record",
+			Operation: admissionv1.Update,
+			Endpoint:  &v1.Endpoint{DNSName: "app.example.com", RecordType: "A", Targets: v1.Targets{"lb.example.com"}, RecordTTL: 60},
+		},
+		{
+			Name:          "deleted invalid record",
+			Operation:     admissionv1.Delete,
+			Endpoint:      &v1.Endpoint{DNSName: "app.example.com", RecordType: "A", Targets: v1.Targets{"lb.example.com"}, RecordTTL: 60},
+			ExpectAllowed: true,
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			record := &v1.DNSRecord{
+				ObjectMeta: metav1.ObjectMeta{Name: "app"},
+				Spec:       v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{testCase.Endpoint}},
+			}
+			raw, err := json.Marshal(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       types.UID("1234"),
+					Name:      record.Name,
+					Operation: testCase.Operation,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, ValidateDNSRecordPath, bytes.NewReader(body)))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+			}
+
+			review := &admissionv1.AdmissionReview{}
+			if err := json.NewDecoder(recorder.Body).Decode(review); err != nil {
+				t.Fatal(err)
+			}
+			if review.Response == nil || review.Response.UID != "1234" {
+				t.Fatalf("expected a response to the request, got %v", review.Response)
+			}
+			if review.Response.Allowed != testCase.ExpectAllowed {
+				t.Errorf("expected allowed %t, got %t: %v", testCase.ExpectAllowed, review.Response.Allowed, review.Response.Result)
+			}
+			if !review.Response.Allowed && (review.Response.Result == nil || len(review.Response.Result.Details.Causes) != 2) {
+				t.Errorf("expected the DNS name and target errors, got %v", review.Response.Result)
+			}
+		})
+	}
+}
+
+func TestDNSRecordValidationHandlerInvalidReview(t *testing.T) {
+	handler := NewDNSRecordValidationHandler(dns.NewRecordValidator(nil, "dev.hcpapps.net", 10, 3600))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, ValidateDNSRecordPath, bytes.NewReader([]byte("{}"))))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
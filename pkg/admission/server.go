@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+)
+
+// ValidateDNSRecordPath is the path of the DNSRecord validating webhook
+const ValidateDNSRecordPath = "/validate-dnsrecord"
+
+// Server serves the admission webhooks over TLS
+type Server struct {
+	httpServer http.Server
+	listener   net.Listener
+	certFile   string
+	keyFile    string
+}
+
+// NewServer returns a server of the DNSRecord validating webhook listening on port, or a disabled server if port is 0.
+// certFile and keyFile are the TLS certificate and key the API server verifies with the webhook CA bundle.
+func NewServer(port int, certFile, keyFile string, validator *dns.RecordValidator) (*Server, error) {
+	if port == 0 {
+		return &Server{}, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("a TLS certificate and key are required to serve the admission webhooks")
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(ValidateDNSRecordPath, NewDNSRecordValidationHandler(validator))
+
+	return &Server{
+		listener: listener,
+		certFile: certFile,
+		keyFile:  keyFile,
+		httpServer: http.Server{
+			Handler: mux,
+		},
+	}, nil
+}
+
+func (s *Server) Start() (err error) {
+	if s.listener == nil {
+		log.Logger.Info("Serving admission webhooks is disabled")
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("serving admission webhooks failed: %v", r)
+		}
+	}()
+	log.Logger.Info("Started serving admission webhooks", "address", s.listener.Addr())
+	if e := s.httpServer.ServeTLS(s.listener, s.certFile, s.keyFile); e != http.ErrServerClosed {
+		err = e
+	}
+	return
+}
+
+func (s *Server) Shutdown() error {
+	if s.listener == nil {
+		return nil
+	}
+	log.Logger.Info("Stopping admission webhooks server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(shutdownCtx)
+}
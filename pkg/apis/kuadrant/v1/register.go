@@ -29,6 +29,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&DNSRecordList{},
 		&DomainVerificationList{},
 		&DomainVerification{},
+		&HealthCheck{},
+		&HealthCheckList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"fmt"
+	"reflect"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -100,6 +101,9 @@ type TTL int64
 // it is then stored in a persistent storage via serialization
 type Labels map[string]string
 
+// ClusterLabel is the label of the endpoints set to the cluster exposing the address they are health checked at
+const ClusterLabel = "cluster"
+
 // ProviderSpecific holds configuration which is specific to individual DNS providers
 type ProviderSpecific []ProviderSpecificProperty
 
@@ -121,6 +125,15 @@ type Endpoint struct {
 	// ProviderSpecific stores provider specific config
 	// +optional
 	ProviderSpecific ProviderSpecific `json:"providerSpecific,omitempty"`
+	// HealthCheckRef references the HealthCheck the endpoint is health checked with, in the namespace of the record
+	// +optional
+	HealthCheckRef *HealthCheckReference `json:"healthCheckRef,omitempty"`
+}
+
+// HealthCheckReference is a reference to a HealthCheck in the namespace of the referencing object.
+type HealthCheckReference struct {
+	// name is the name of the HealthCheck.
+	Name string `json:"name"`
 }
 
 // DNSRecordSpec contains the details of a DNS record.
@@ -143,6 +156,23 @@ type DNSRecordStatus struct {
 	// needs to retry the update for that specific zone.
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// clusters are the health of the clusters exposing the health checked endpoints of the record.
+	// +optional
+	Clusters []ClusterHealth `json:"clusters,omitempty"`
+}
+
+// ClusterHealth is the health of the health checked endpoints exposed by a cluster.
+type ClusterHealth struct {
+	// cluster is the name of the cluster.
+	Cluster string `json:"cluster"`
+	// health is Unhealthy when any endpoint of the cluster is unhealthy, Healthy when all of them are healthy, and
+	// Unknown otherwise.
+	Health EndpointHealth `json:"health"`
+	// endpoints is the number of health checked endpoints exposed by the cluster.
+	Endpoints int `json:"endpoints"`
+	// healthyEndpoints is the number of healthy endpoints exposed by the cluster.
+	HealthyEndpoints int `json:"healthyEndpoints"`
 }
 
 // DNSZone is used to define a DNS hosted zone.
@@ -186,11 +216,79 @@ type DNSZoneStatus struct {
 	// Note: This will not be required if/when we switch to using external-dns since when
 	// running with a "sync" policy it will clean up unused records automatically.
 	Endpoints []*Endpoint `json:"endpoints,omitempty"`
+	// changeID is the identifier of the last change submitted to the provider, for the providers applying the
+	// changes asynchronously. It is used to track the propagation of the change, reported with the "Propagated"
+	// condition.
+	// +optional
+	ChangeID string `json:"changeID,omitempty"`
+	// endpointStatuses are the results of publishing each endpoint of the record to the zone.
+	// +optional
+	EndpointStatuses []EndpointStatus `json:"endpointStatuses,omitempty"`
 }
 
+// EndpointStatus is the result of publishing an endpoint of a record to a zone.
+type EndpointStatus struct {
+	// dnsName is the hostname of the endpoint.
+	DNSName string `json:"dnsName"`
+	// recordType is the record type of the endpoint.
+	// +optional
+	RecordType string `json:"recordType,omitempty"`
+	// setIdentifier is the set identifier of the endpoint, e.g. the key of the cluster it targets.
+	// +optional
+	SetIdentifier string `json:"setIdentifier,omitempty"`
+	// state is whether the endpoint has been published, rejected by the DNS provider, is pending the
+	// publication of the other endpoints of the record, or has been withdrawn for failing its health checks.
+	State EndpointState `json:"state"`
+	// message is a human readable description of the state, e.g. the reason the endpoint was rejected.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// healthCheckID is the identifier of the health check of the endpoint in the DNS provider.
+	// +optional
+	HealthCheckID string `json:"healthCheckID,omitempty"`
+	// health is the current health of the endpoint, when it is health checked.
+	// +optional
+	Health EndpointHealth `json:"health,omitempty"`
+}
+
+// EndpointState is the publication state of an endpoint.
+// +kubebuilder:validation:Enum=Published;Rejected;Pending;Withdrawn
+type EndpointState string
+
+const (
+	// EndpointPublished means the endpoint has been published to the zone.
+	EndpointPublished EndpointState = "Published"
+	// EndpointRejected means the DNS provider rejected the endpoint, e.g. for an invalid target.
+	EndpointRejected EndpointState = "Rejected"
+	// EndpointPending means the endpoint hasn't been published, e.g. because another endpoint of the record was
+	// rejected.
+	EndpointPending EndpointState = "Pending"
+	// EndpointWithdrawn means the endpoint has been withdrawn from the zone, as it failed the health checks probed by
+	// GLBC.
+	EndpointWithdrawn EndpointState = "Withdrawn"
+)
+
+// EndpointHealth is the health of a health checked endpoint.
+// +kubebuilder:validation:Enum=Healthy;Unhealthy;Unknown
+type EndpointHealth string
+
+const (
+	EndpointHealthy   EndpointHealth = "Healthy"
+	EndpointUnhealthy EndpointHealth = "Unhealthy"
+	EndpointUnknown   EndpointHealth = "Unknown"
+)
+
 var (
 	// Succeeded means the record is available within a zone if the status condition is true.
 	DNSRecordSucceededConditionType = "Succeeded"
+	// Drifted means the records published to a zone differ from the record endpoints if the status condition is true,
+	// e.g. when they have been edited or deleted outside of the controller.
+	DNSRecordDriftedConditionType = "Drifted"
+	// Propagated means the last change of the record in a zone has been propagated to all the authoritative
+	// nameservers of the zone if the status condition is true.
+	DNSRecordPropagatedConditionType = "Propagated"
+	// Verified means the endpoints of the record are answered by all the authoritative nameservers of a zone if the
+	// status condition is true.
+	DNSRecordVerifiedConditionType = "Verified"
 )
 
 // DNSZoneCondition is just the standard condition fields.
@@ -209,7 +307,7 @@ type DNSZoneCondition struct {
 }
 
 // DNSRecordType is a DNS resource record type.
-// +kubebuilder:validation:Enum=CNAME;A
+// +kubebuilder:validation:Enum=CNAME;A;AAAA;TXT
 type DNSRecordType string
 
 const (
@@ -218,6 +316,12 @@ const (
 
 	// ARecordType is an RFC 1035 A record.
 	ARecordType DNSRecordType = "A"
+
+	// AAAARecordType is an RFC 3596 AAAA record.
+	AAAARecordType DNSRecordType = "AAAA"
+
+	// TXTRecordType is an RFC 1035 TXT record.
+	TXTRecordType DNSRecordType = "TXT"
 )
 
 // +kubebuilder:object:root=true
@@ -236,9 +340,9 @@ func (endpoint *Endpoint) SetProviderSpecific(name, value string) {
 		endpoint.ProviderSpecific = ProviderSpecific{}
 	}
 
-	for _, pair := range endpoint.ProviderSpecific {
-		if pair.Name == name {
-			property = &pair
+	for i := range endpoint.ProviderSpecific {
+		if endpoint.ProviderSpecific[i].Name == name {
+			property = &endpoint.ProviderSpecific[i]
 		}
 	}
 
@@ -290,17 +394,118 @@ func (endpoint *Endpoint) GetAddress() (string, bool) {
 	return string(endpoint.Targets[0]), true
 }
 
-// not currently a generated API used internally only
+// ZoneEndpoints returns the endpoints last published to the zone, or none if the record has not been published to it
+func (status *DNSRecordStatus) ZoneEndpoints(zone DNSZone) []*Endpoint {
+	for _, zoneStatus := range status.Zones {
+		if reflect.DeepEqual(zoneStatus.DNSZone, zone) {
+			return zoneStatus.Endpoints
+		}
+	}
+	return []*Endpoint{}
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// HealthCheck is the health check of the DNSRecord endpoints referencing it.
 type HealthCheck struct {
-	Id               string
-	Name             string
-	Port             *int64
-	FailureThreshold *int64
-	Path             string
-	Protocol         *HealthCheckProtocol
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// spec is the specification of the health check.
+	Spec HealthCheckSpec `json:"spec"`
+	// status is the most recently observed status of the health checks of the endpoints.
+	Status HealthCheckStatus `json:"status,omitempty"`
+}
+
+// HealthCheckSpec is the specification of a health check.
+type HealthCheckSpec struct {
+	// path is the path of the health endpoint of the service, required by the HTTP and HTTPS health checks. It is
+	// ignored by the TCP health checks.
+	// +optional
+	Path string `json:"path,omitempty"`
+	// port is the port the health checks are performed on.
+	// +optional
+	Port *int64 `json:"port,omitempty"`
+	// protocol is the protocol the health checks request the endpoint with. The HTTPS health checks send the DNS
+	// name of the endpoint as the TLS server name.
+	// +optional
+	Protocol *HealthCheckProtocol `json:"protocol,omitempty"`
+	// failureThreshold is the number of consecutive health checks the endpoint can fail before it is considered
+	// unhealthy.
+	// +optional
+	FailureThreshold *int64 `json:"failureThreshold,omitempty"`
+	// searchString is the string the HTTP and HTTPS health checks search the first 5120 bytes of the response body
+	// for. The endpoint is unhealthy when the response body doesn't contain it.
+	// +kubebuilder:validation:MaxLength=255
+	// +optional
+	SearchString string `json:"searchString,omitempty"`
+	// interval is the number of seconds between the health checks of the endpoint, either 10 or 30.
+	// +kubebuilder:validation:Enum=10;30
+	// +optional
+	Interval *int64 `json:"interval,omitempty"`
+	// regions are the regions the endpoint is health checked from, at least 3 when set. The endpoint is health
+	// checked from all the regions when empty. Only supported by the aws DNS provider.
+	// +kubebuilder:validation:MinItems=3
+	// +optional
+	Regions []string `json:"regions,omitempty"`
+}
+
+// HealthCheckStatus is the most recently observed status of the health checks of the endpoints.
+type HealthCheckStatus struct {
+	// observedGeneration is the most recently observed generation of the HealthCheck.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// endpoints are the health checks of the DNSRecord endpoints referencing the HealthCheck.
+	// +optional
+	Endpoints []HealthCheckEndpointStatus `json:"endpoints,omitempty"`
+}
+
+// HealthCheckEndpointStatus is the status of the health check of an endpoint.
+type HealthCheckEndpointStatus struct {
+	// dnsRecord is the name of the DNSRecord of the endpoint.
+	DNSRecord string `json:"dnsRecord"`
+	// dnsName is the hostname of the endpoint.
+	DNSName string `json:"dnsName"`
+	// setIdentifier is the set identifier of the endpoint.
+	// +optional
+	SetIdentifier string `json:"setIdentifier,omitempty"`
+	// address is the health checked address of the endpoint.
+	// +optional
+	Address string `json:"address,omitempty"`
+	// healthCheckID is the identifier of the health check in the DNS provider.
+	// +optional
+	HealthCheckID string `json:"healthCheckID,omitempty"`
+	// health is the current health of the endpoint.
+	// +optional
+	Health EndpointHealth `json:"health,omitempty"`
+	// message is a human readable description of the last failure to reconcile the health check, if any.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HealthCheckList contains a list of health checks.
+type HealthCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HealthCheck `json:"items"`
+}
+
+// EndpointHealthCheck is the health check of an endpoint reconciled with the DNS provider. It is not a generated API
+// and is used internally only.
+type EndpointHealthCheck struct {
+	Id   string
+	Name string
+	HealthCheckSpec
 }
 
+// HealthCheckProtocol is the protocol of a health check.
+// +kubebuilder:validation:Enum=HTTP;HTTPS;TCP
 type HealthCheckProtocol string
 
 const HealthCheckProtocolHTTP HealthCheckProtocol = "HTTP"
 const HealthCheckProtocolHTTPS HealthCheckProtocol = "HTTPS"
+const HealthCheckProtocolTCP HealthCheckProtocol = "TCP"
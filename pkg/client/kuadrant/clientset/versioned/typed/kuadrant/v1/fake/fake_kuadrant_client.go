@@ -20,6 +20,10 @@ func (c *FakeKuadrantV1) DomainVerifications() v1.DomainVerificationInterface {
 	return &FakeDomainVerifications{c}
 }
 
+func (c *FakeKuadrantV1) HealthChecks(namespace string) v1.HealthCheckInterface {
+	return &FakeHealthChecks{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeKuadrantV1) RESTClient() rest.Interface {
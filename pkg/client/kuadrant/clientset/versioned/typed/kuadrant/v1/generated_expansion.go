@@ -5,3 +5,5 @@ package v1
 type DNSRecordExpansion interface{}
 
 type DomainVerificationExpansion interface{}
+
+type HealthCheckExpansion interface{}
@@ -15,6 +15,7 @@ type KuadrantV1Interface interface {
 	RESTClient() rest.Interface
 	DNSRecordsGetter
 	DomainVerificationsGetter
+	HealthChecksGetter
 }
 
 // KuadrantV1Client is used to interact with features provided by the kuadrant.dev group.
@@ -31,6 +32,10 @@ func (c *KuadrantV1Client) DomainVerifications() DomainVerificationInterface {
 	return newDomainVerifications(c)
 }
 
+func (c *KuadrantV1Client) HealthChecks(namespace string) HealthCheckInterface {
+	return newHealthChecks(c, namespace)
+}
+
 // NewForConfig creates a new KuadrantV1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
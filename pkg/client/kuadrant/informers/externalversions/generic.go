@@ -41,6 +41,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Kuadrant().V1().DNSRecords().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("domainverifications"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Kuadrant().V1().DomainVerifications().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("healthchecks"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Kuadrant().V1().HealthChecks().Informer()}, nil
 
 	}
 
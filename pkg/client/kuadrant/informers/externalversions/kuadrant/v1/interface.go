@@ -12,6 +12,8 @@ type Interface interface {
 	DNSRecords() DNSRecordInformer
 	// DomainVerifications returns a DomainVerificationInformer.
 	DomainVerifications() DomainVerificationInformer
+	// HealthChecks returns a HealthCheckInformer.
+	HealthChecks() HealthCheckInformer
 }
 
 type version struct {
@@ -34,3 +36,8 @@ func (v *version) DNSRecords() DNSRecordInformer {
 func (v *version) DomainVerifications() DomainVerificationInformer {
 	return &domainVerificationInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
+
+// HealthChecks returns a HealthCheckInformer.
+func (v *version) HealthChecks() HealthCheckInformer {
+	return &healthCheckInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
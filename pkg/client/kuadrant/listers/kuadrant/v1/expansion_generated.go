@@ -13,3 +13,11 @@ type DNSRecordNamespaceListerExpansion interface{}
 // DomainVerificationListerExpansion allows custom methods to be added to
 // DomainVerificationLister.
 type DomainVerificationListerExpansion interface{}
+
+// HealthCheckListerExpansion allows custom methods to be added to
+// HealthCheckLister.
+type HealthCheckListerExpansion interface{}
+
+// HealthCheckNamespaceListerExpansion allows custom methods to be added to
+// HealthCheckNamespaceLister.
+type HealthCheckNamespaceListerExpansion interface{}
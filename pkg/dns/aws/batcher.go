@@ -0,0 +1,181 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultChangeBatchWindow is the time the changes to a hosted zone are collected for before being submitted
+	DefaultChangeBatchWindow = 100 * time.Millisecond
+	// maxChangeBatchRecords is the maximum number of ResourceRecord elements of a batch, and maxChangeBatchCharacters
+	// the maximum number of characters of their values, the records of an UPSERT counting twice
+	// See https://docs.aws.amazon.com/Route53/latest/DeveloperGuide/DNSLimitations.html#limits-api-requests-changeresourcerecordsets
+	maxChangeBatchRecords    = 1000
+	maxChangeBatchCharacters = 32000
+	// changeRequestsPerSecond is the Route53 API requests limit per account
+	changeRequestsPerSecond = 5
+)
+
+type changeRequest struct {
+	changes []*route53.Change
+	result  chan changeResult
+}
+
+type changeResult struct {
+	info *route53.ChangeInfo
+	err  error
+}
+
+// changeBatcher coalesces the changes submitted to a hosted zone within a window into change batches, and submits
+// them at the rate allowed by Route53. The changes of a request are always submitted within the same batch, so that
+// they are applied atomically. When a batch fails, its requests are resubmitted individually, so that the failure is
+// only returned to the requests it is caused by.
+type changeBatcher struct {
+	route53      *InstrumentedRoute53
+	limiter      *rate.Limiter
+	window       time.Duration
+	maxBatchSize changeBatchSize
+	logger       logr.Logger
+
+	lock    sync.Mutex
+	pending map[string][]*changeRequest
+}
+
+func newChangeBatcher(route53 *InstrumentedRoute53, window time.Duration, logger logr.Logger) *changeBatcher {
+	return &changeBatcher{
+		route53:      route53,
+		limiter:      rate.NewLimiter(changeRequestsPerSecond, 1),
+		window:       window,
+		maxBatchSize: changeBatchSize{records: maxChangeBatchRecords, characters: maxChangeBatchCharacters},
+		logger:       logger,
+		pending:      map[string][]*changeRequest{},
+	}
+}
+
+// submit submits the changes to the hosted zone, and returns once the batch they belong to has been submitted
+func (b *changeBatcher) submit(zoneID string, changes []*route53.Change) (*route53.ChangeInfo, error) {
+	request := &changeRequest{
+		changes: changes,
+		result:  make(chan changeResult, 1),
+	}
+
+	b.lock.Lock()
+	if _, ok := b.pending[zoneID]; !ok {
+		time.AfterFunc(b.window, func() { b.flush(zoneID) })
+	}
+	b.pending[zoneID] = append(b.pending[zoneID], request)
+	b.lock.Unlock()
+
+	result := <-request.result
+	return result.info, result.err
+}
+
+func (b *changeBatcher) flush(zoneID string) {
+	b.lock.Lock()
+	requests := b.pending[zoneID]
+	delete(b.pending, zoneID)
+	b.lock.Unlock()
+
+	for _, batch := range b.batches(requests) {
+		b.submitBatch(zoneID, batch)
+	}
+}
+
+// batches splits the requests into batches within the maximum batch size. A request larger than the maximum
+// batch size is submitted alone, and rejected by Route53.
+func (b *changeBatcher) batches(requests []*changeRequest) [][]*changeRequest {
+	var batches [][]*changeRequest
+	var batch []*changeRequest
+	size := changeBatchSize{}
+	for _, request := range requests {
+		requestSize := changesSize(request.changes)
+		if len(batch) > 0 && size.add(requestSize).exceeds(b.maxBatchSize) {
+			batches = append(batches, batch)
+			batch, size = nil, changeBatchSize{}
+		}
+		batch = append(batch, request)
+		size = size.add(requestSize)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func (b *changeBatcher) submitBatch(zoneID string, requests []*changeRequest) {
+	var changes []*route53.Change
+	for _, request := range requests {
+		changes = append(changes, request.changes...)
+	}
+
+	if err := b.limiter.Wait(context.Background()); err != nil {
+		b.reply(requests, nil, err)
+		return
+	}
+	output, err := b.route53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch:  &route53.ChangeBatch{Changes: changes},
+	})
+	if err != nil && len(requests) > 1 {
+		b.logger.Info("Change batch failed, resubmitting its requests individually", "zone", zoneID, "requests", len(requests), "error", err.Error())
+		for _, request := range requests {
+			b.submitBatch(zoneID, []*changeRequest{request})
+		}
+		return
+	}
+	if err != nil {
+		b.reply(requests, nil, fmt.Errorf("couldn't submit changes to zone %s: %w", zoneID, err))
+		return
+	}
+	b.logger.V(3).Info("Submitted change batch", "zone", zoneID, "requests", len(requests), "changes", len(changes))
+	b.reply(requests, output.ChangeInfo, nil)
+}
+
+func (b *changeBatcher) reply(requests []*changeRequest, info *route53.ChangeInfo, err error) {
+	for _, request := range requests {
+		request.result <- changeResult{info: info, err: err}
+	}
+}
+
+// changeBatchSize is the size of a change batch, as limited by Route53
+type changeBatchSize struct {
+	// records is the number of ResourceRecord elements
+	records int
+	// characters is the number of characters of the values of the ResourceRecord elements
+	characters int
+}
+
+func (s changeBatchSize) add(other changeBatchSize) changeBatchSize {
+	return changeBatchSize{records: s.records + other.records, characters: s.characters + other.characters}
+}
+
+func (s changeBatchSize) exceeds(max changeBatchSize) bool {
+	return s.records > max.records || s.characters > max.characters
+}
+
+// changesSize returns the size of the changes, an alias record set counting as a single record
+func changesSize(changes []*route53.Change) changeBatchSize {
+	size := changeBatchSize{}
+	for _, change := range changes {
+		changeSize := changeBatchSize{records: 1}
+		if change.ResourceRecordSet != nil && len(change.ResourceRecordSet.ResourceRecords) > 0 {
+			changeSize.records = len(change.ResourceRecordSet.ResourceRecords)
+			for _, record := range change.ResourceRecordSet.ResourceRecords {
+				changeSize.characters += len(aws.StringValue(record.Value))
+			}
+		}
+		if aws.StringValue(change.Action) == route53.ChangeActionUpsert {
+			changeSize = changeSize.add(changeSize)
+		}
+		size = size.add(changeSize)
+	}
+	return size
+}
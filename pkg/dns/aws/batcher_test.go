@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/go-logr/logr"
+)
+
+// fakeChangeRoute53 records the change batches by zone, and rejects the batches holding a change of an invalid name
+type fakeChangeRoute53 struct {
+	route53iface.Route53API
+	lock    sync.Mutex
+	batches map[string][]int
+}
+
+func (f *fakeChangeRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, change := range input.ChangeBatch.Changes {
+		if aws.StringValue(change.ResourceRecordSet.Name) == "invalid" {
+			return nil, errors.New("InvalidChangeBatch")
+		}
+	}
+	zoneID := aws.StringValue(input.HostedZoneId)
+	f.batches[zoneID] = append(f.batches[zoneID], len(input.ChangeBatch.Changes))
+	return &route53.ChangeResourceRecordSetsOutput{ChangeInfo: &route53.ChangeInfo{Id: aws.String(fmt.Sprintf("%s-%d", zoneID, len(f.batches[zoneID])))}}, nil
+}
+
+func changes(action string, names ...string) []*route53.Change {
+	var changes []*route53.Change
+	for _, name := range names {
+		changes = append(changes, &route53.Change{
+			Action:            aws.String(action),
+			ResourceRecordSet: &route53.ResourceRecordSet{Name: aws.String(name)},
+		})
+	}
+	return changes
+}
+
+func TestChangeBatcher(t *testing.T) {
+	fake := &fakeChangeRoute53{batches: map[string][]int{}}
+	batcher := newChangeBatcher(&InstrumentedRoute53{fake}, 50*time.Millisecond, logr.Discard())
+	batcher.maxBatchSize = changeBatchSize{records: 6, characters: maxChangeBatchCharacters}
+
+	requests := []struct {
+		zoneID    string
+		changes   []*route53.Change
+		expectErr bool
+	}{
+		{zoneID: "Z1", changes: changes("UPSERT", "a", "b")},
+		{zoneID: "Z1", changes: changes("DELETE", "c", "d")},
+		{zoneID: "Z1", changes: changes("UPSERT", "e")},
+		{zoneID: "Z1", changes: changes("UPSERT", "invalid"), expectErr: true},
+		{zoneID: "Z2", changes: changes("UPSERT", "a")},
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(requests))
+	infos := make([]*route53.ChangeInfo, len(requests))
+	for i, request := range requests {
+		wg.Add(1)
+		go func(i int, zoneID string, changes []*route53.Change) {
+			defer wg.Done()
+			infos[i], errs[i] = batcher.submit(zoneID, changes)
+		}(i, request.zoneID, request.changes)
+		// The requests are pending in order
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+
+	for i, request := range requests {
+		if request.expectErr != (errs[i] != nil) {
+			t.Errorf("unexpected error for request %d: %v", i, errs[i])
+		}
+		if !request.expectErr && infos[i] == nil {
+			t.Errorf("expected the change info to be returned for request %d", i)
+		}
+	}
+	// The requests of the first zone are split by size, an UPSERT counting twice, and the failing batch is
+	// resubmitted per request
+	if batches := fake.batches["Z1"]; fmt.Sprint(batches) != "[4 1]" {
+		t.Errorf("unexpected change batches %v", batches)
+	}
+	if batches := fake.batches["Z2"]; fmt.Sprint(batches) != "[1]" {
+		t.Errorf("unexpected change batches %v", batches)
+	}
+}
+
+func TestChangesSize(t *testing.T) {
+	recordSet := func(values ...string) *route53.ResourceRecordSet {
+		rrs := &route53.ResourceRecordSet{Name: aws.String("echo.example.com")}
+		for _, value := range values {
+			rrs.ResourceRecords = append(rrs.ResourceRecords, &route53.ResourceRecord{Value: aws.String(value)})
+		}
+		return rrs
+	}
+	alias := &route53.ResourceRecordSet{Name: aws.String("echo.example.com"), AliasTarget: &route53.AliasTarget{DNSName: aws.String("lb.example.com")}}
+
+	cases := []struct {
+		name     string
+		changes  []*route53.Change
+		expected changeBatchSize
+	}{
+		{
+			name:     "create",
+			changes:  []*route53.Change{{Action: aws.String("CREATE"), ResourceRecordSet: recordSet("192.168.0.1", "192.168.0.2")}},
+			expected: changeBatchSize{records: 2, characters: 22},
+		},
+		{
+			name:     "upsert counting twice",
+			changes:  []*route53.Change{{Action: aws.String("UPSERT"), ResourceRecordSet: recordSet("192.168.0.1", "192.168.0.2")}},
+			expected: changeBatchSize{records: 4, characters: 44},
+		},
+		{
+			name: "alias",
+			changes: []*route53.Change{
+				{Action: aws.String("DELETE"), ResourceRecordSet: alias},
+				{Action: aws.String("UPSERT"), ResourceRecordSet: alias},
+			},
+			expected: changeBatchSize{records: 3},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if size := changesSize(tc.changes); size != tc.expected {
+				t.Fatalf("expected size %+v, got %+v", tc.expected, size)
+			}
+		})
+	}
+
+	// A batch is split when the characters of its values exceed the limit
+	batcher := newChangeBatcher(nil, 0, logr.Discard())
+	batcher.maxBatchSize = changeBatchSize{records: maxChangeBatchRecords, characters: 30}
+	requests := []*changeRequest{
+		{changes: []*route53.Change{{Action: aws.String("CREATE"), ResourceRecordSet: recordSet("192.168.0.1")}}},
+		{changes: []*route53.Change{{Action: aws.String("CREATE"), ResourceRecordSet: recordSet("192.168.0.2")}}},
+		{changes: []*route53.Change{{Action: aws.String("CREATE"), ResourceRecordSet: recordSet("192.168.0.3")}}},
+	}
+	if batches := batcher.batches(requests); len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("expected the requests to be split into 2 batches, got %v", batches)
+	}
+}
@@ -0,0 +1,65 @@
+package aws
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/kcp-dev/logicalcluster/v2"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// changeIDs holds the ID of the last change submitted for each record to each hosted zone
+type changeIDs struct {
+	lock sync.Mutex
+	ids  map[string]string
+}
+
+func newChangeIDs() *changeIDs {
+	return &changeIDs{ids: map[string]string{}}
+}
+
+func (c *changeIDs) set(record *v1.DNSRecord, zoneID, changeID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if changeID == "" {
+		delete(c.ids, changeKey(record, zoneID))
+		return
+	}
+	c.ids[changeKey(record, zoneID)] = changeID
+}
+
+func (c *changeIDs) get(record *v1.DNSRecord, zoneID string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ids[changeKey(record, zoneID)]
+}
+
+func changeKey(record *v1.DNSRecord, zoneID string) string {
+	return fmt.Sprintf("%s|%s/%s|%s", logicalcluster.From(record), record.Namespace, record.Name, zoneID)
+}
+
+// LastChange returns the ID of the last change of the record submitted to the hosted zone.
+func (p *Provider) LastChange(record *v1.DNSRecord, zone v1.DNSZone) string {
+	zoneID, err := p.zoneIDs.get(zone)
+	if err != nil {
+		return ""
+	}
+	return p.changeIDs.get(record, zoneID)
+}
+
+// ChangePropagated returns whether the change is INSYNC, i.e. it has been propagated to all the Route53 DNS servers.
+// The changes that are no longer found, as Route53 only keeps them for 90 days, are propagated.
+func (p *Provider) ChangePropagated(changeID string) (bool, error) {
+	output, err := p.route53.GetChange(&route53.GetChangeInput{Id: aws.String(changeID)})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == route53.ErrCodeNoSuchChange {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to get change %s: %v", changeID, err)
+	}
+	return aws.StringValue(output.ChangeInfo.Status) == route53.ChangeStatusInsync, nil
+}
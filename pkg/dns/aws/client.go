@@ -22,10 +22,11 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 )
 
 type InstrumentedRoute53 struct {
-	route53 *route53.Route53
+	route53 route53iface.Route53API
 }
 
 func observe(operation string, f func() error) {
@@ -59,6 +60,14 @@ func (c *InstrumentedRoute53) ListHostedZones(input *route53.ListHostedZonesInpu
 	return
 }
 
+func (c *InstrumentedRoute53) ListResourceRecordSetsPages(input *route53.ListResourceRecordSetsInput, fn func(*route53.ListResourceRecordSetsOutput, bool) bool) (err error) {
+	observe("ListResourceRecordSetsPages", func() error {
+		err = c.route53.ListResourceRecordSetsPages(input, fn)
+		return err
+	})
+	return
+}
+
 func (c *InstrumentedRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (output *route53.ChangeResourceRecordSetsOutput, err error) {
 	observe("ChangeResourceRecordSets", func() error {
 		output, err = c.route53.ChangeResourceRecordSets(input)
@@ -67,6 +76,14 @@ func (c *InstrumentedRoute53) ChangeResourceRecordSets(input *route53.ChangeReso
 	return
 }
 
+func (c *InstrumentedRoute53) GetChange(input *route53.GetChangeInput) (output *route53.GetChangeOutput, err error) {
+	observe("GetChange", func() error {
+		output, err = c.route53.GetChange(input)
+		return err
+	})
+	return
+}
+
 func (c *InstrumentedRoute53) CreateHealthCheck(input *route53.CreateHealthCheckInput) (output *route53.CreateHealthCheckOutput, err error) {
 	observe("CreateHealthCheck", func() error {
 		output, err = c.route53.CreateHealthCheck(input)
@@ -106,3 +123,27 @@ func (c *InstrumentedRoute53) ChangeTagsForResourceWithContext(ctx aws.Context,
 	})
 	return
 }
+
+func (c *InstrumentedRoute53) GetHealthCheckStatusWithContext(ctx aws.Context, input *route53.GetHealthCheckStatusInput, opts ...request.Option) (output *route53.GetHealthCheckStatusOutput, err error) {
+	observe("GetHealthCheckStatusWithContext", func() error {
+		output, err = c.route53.GetHealthCheckStatusWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *InstrumentedRoute53) ListHealthChecksPagesWithContext(ctx aws.Context, input *route53.ListHealthChecksInput, fn func(*route53.ListHealthChecksOutput, bool) bool, opts ...request.Option) (err error) {
+	observe("ListHealthChecksPagesWithContext", func() error {
+		err = c.route53.ListHealthChecksPagesWithContext(ctx, input, fn, opts...)
+		return err
+	})
+	return
+}
+
+func (c *InstrumentedRoute53) ListTagsForResourcesWithContext(ctx aws.Context, input *route53.ListTagsForResourcesInput, opts ...request.Option) (output *route53.ListTagsForResourcesOutput, err error) {
+	observe("ListTagsForResourcesWithContext", func() error {
+		output, err = c.route53.ListTagsForResourcesWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
@@ -3,13 +3,17 @@ package aws
 import (
 	"context"
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
 	"github.com/aws/aws-sdk-go/service/route53"
 
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -22,19 +26,37 @@ const (
 	// chinaRoute53Endpoint is the Route 53 service endpoint used for AWS China regions.
 	chinaRoute53Endpoint = "https://route53.amazonaws.com.cn"
 
+	ProviderSpecificAlias                = "aws/alias"
 	ProviderSpecificEvaluateTargetHealth = "aws/evaluate-target-health"
 	ProviderSpecificWeight               = "aws/weight"
 	ProviderSpecificRegion               = "aws/region"
 	ProviderSpecificFailover             = "aws/failover"
 	ProviderSpecificMultiValueAnswer     = "aws/multi-value-answer"
 	ProviderSpecificHealthCheckID        = "aws/health-check-id"
-	ZoneIDEnvVar                         = "AWS_DNS_PUBLIC_ZONE_ID"
+	// ProviderSpecificHealthCheckHost is the host the health checks request the endpoint with, when it isn't the
+	// DNS name of the endpoint, e.g. for the location hosts of geo aware DNS
+	ProviderSpecificHealthCheckHost = "aws/health-check-host"
+	// ProviderSpecificGeolocationContinentCode routes the queries from a continent, e.g. NA, to the record
+	ProviderSpecificGeolocationContinentCode = "aws/geolocation-continent-code"
+	// ProviderSpecificGeolocationCountryCode routes the queries from a country to the record, "*" being the
+	// default location, for the queries not matching any other location
+	ProviderSpecificGeolocationCountryCode = "aws/geolocation-country-code"
+	ZoneIDEnvVar                           = "AWS_DNS_PUBLIC_ZONE_ID"
+	// ChangeBatchWindowEnvVar is the time the changes to a hosted zone are collected for before being submitted
+	ChangeBatchWindowEnvVar = "GLBC_DNS_CHANGE_BATCH_WINDOW"
+
+	// FailoverPrimary and FailoverSecondary are the values of the ProviderSpecificFailover property
+	FailoverPrimary   = route53.ResourceRecordSetFailoverPrimary
+	FailoverSecondary = route53.ResourceRecordSetFailoverSecondary
 )
 
 // Inspired by https://github.com/openshift/cluster-ingress-operator/blob/master/pkg/dns/aws/dns.go
 type Provider struct {
 	route53               *InstrumentedRoute53
 	healthCheckReconciler *Route53HealthCheckReconciler
+	zoneIDs               *zoneIDs
+	changeBatcher         *changeBatcher
+	changeIDs             *changeIDs
 	config                Config
 	logger                logr.Logger
 }
@@ -43,6 +65,12 @@ type Provider struct {
 type Config struct {
 	// Region is the AWS region ELBs are created in.
 	Region string
+	// OwnerID is the ID of the GLBC instance the records are published by. The names are only changed when they
+	// are owned by the instance, as recorded in companion ownership TXT records, when set.
+	OwnerID string
+	// ChangeBatchWindow is the time the changes to a hosted zone are collected for, to be submitted as a single
+	// change batch. Defaults to DefaultChangeBatchWindow.
+	ChangeBatchWindow time.Duration
 }
 
 func NewProvider(config Config) (*Provider, error) {
@@ -80,14 +108,22 @@ func NewProvider(config Config) (*Provider, error) {
 
 	p := &Provider{
 		route53: &InstrumentedRoute53{route53.New(sess, r53Config)},
+		// The tags of the Route 53 resources are queried in the same region as Route 53
+		zoneIDs: newZoneIDs(resourcegroupstaggingapi.New(sess, aws.NewConfig().WithRegion(aws.StringValue(r53Config.Region)))),
 		config:  config,
 		logger:  log.Logger.WithName("aws-route53").WithValues("region", r53Config.Region),
 	}
 	if err := validateServiceEndpoints(p); err != nil {
 		return nil, fmt.Errorf("failed to validate AWS provider service endpoints: %v", err)
 	}
+	window := config.ChangeBatchWindow
+	if window <= 0 {
+		window = DefaultChangeBatchWindow
+	}
+	p.changeBatcher = newChangeBatcher(p.route53, window, p.logger)
+	p.changeIDs = newChangeIDs()
 	if p.healthCheckReconciler == nil {
-		p.healthCheckReconciler = newRoute53HealthCheckReconciler(p.route53, p.logger)
+		p.healthCheckReconciler = newRoute53HealthCheckReconciler(p.route53, config.OwnerID, p.logger)
 	}
 
 	return p, nil
@@ -119,7 +155,33 @@ func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
 	return p.change(record, zone, deleteAction)
 }
 
-func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error {
+// ZoneConditions reports the ownership of the records, and the hosted zone found for the zones referenced by tags.
+func (p *Provider) ZoneConditions(_ *v1.DNSRecord, zone v1.DNSZone) []v1.DNSZoneCondition {
+	var conditions []v1.DNSZoneCondition
+	if p.config.OwnerID != "" {
+		conditions = append(conditions, v1.DNSZoneCondition{
+			Type:    OwnershipConflictConditionType,
+			Status:  "False",
+			Reason:  "Owned",
+			Message: fmt.Sprintf("The records are owned by %s", p.config.OwnerID),
+		})
+	}
+	if zone.ID != "" {
+		return conditions
+	}
+	id, err := p.zoneIDs.get(zone)
+	if err != nil {
+		return conditions
+	}
+	return append(conditions, v1.DNSZoneCondition{
+		Type:    ZoneLookupConditionType,
+		Status:  "True",
+		Reason:  "ZoneFound",
+		Message: fmt.Sprintf("Found hosted zone %s with tags %s", id, tagsKey(zone.Tags)),
+	})
+}
+
+func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
 
 	return p.healthCheckReconciler.reconcile(ctx, hc, endpoint)
 }
@@ -128,12 +190,24 @@ func (p *Provider) DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint)
 	return p.healthCheckReconciler.deleteHealthCheck(ctx, endpoint)
 }
 
+func (p *Provider) HealthCheckStatus(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	return p.healthCheckReconciler.status(ctx, endpoint)
+}
+
+func (p *Provider) ManagedHealthChecks(ctx context.Context) (map[string]string, error) {
+	return p.healthCheckReconciler.managedHealthChecks(ctx)
+}
+
 // change will perform an action on a record.
 func (p *Provider) change(record *v1.DNSRecord, zone v1.DNSZone, action action) error {
+	zoneID, err := p.zoneIDs.get(zone)
+	if err != nil {
+		return err
+	}
 	// Configure records.
-	err := p.updateRecord(record, zone.ID, string(action))
+	err = p.updateRecord(record, zone, zoneID, string(action))
 	if err != nil {
-		return fmt.Errorf("failed to update record in zone %s: %v", zone.ID, err)
+		return fmt.Errorf("failed to update record in zone %s: %w", zoneID, err)
 	}
 	switch action {
 	case upsertAction:
@@ -144,55 +218,100 @@ func (p *Provider) change(record *v1.DNSRecord, zone v1.DNSZone, action action)
 	return nil
 }
 
-func (p *Provider) updateRecord(record *v1.DNSRecord, zoneID, action string) error {
-	input := route53.ChangeResourceRecordSetsInput{HostedZoneId: aws.String(zoneID)}
-
-	expectedEndpointsMap := make(map[string]struct{})
+func (p *Provider) updateRecord(record *v1.DNSRecord, zone v1.DNSZone, zoneID, action string) error {
+	expectedEndpointsMap := make(map[recordSetKey]struct{})
 	var changes []*route53.Change
+	rejected := &RejectedEndpointsError{}
 	for _, endpoint := range record.Spec.Endpoints {
-		expectedEndpointsMap[endpoint.SetID()] = struct{}{}
+		expectedEndpointsMap[recordSetKeyForEndpoint(endpoint)] = struct{}{}
 		change, err := p.changeForEndpoint(endpoint, action)
 		if err != nil {
-			return err
+			rejected.Endpoints = append(rejected.Endpoints, RejectedEndpoint{Endpoint: endpoint, Err: err})
+			continue
 		}
 		changes = append(changes, change)
 	}
+	// The change batch is applied atomically, so none of the endpoints is published when one is rejected
+	if len(rejected.Endpoints) > 0 {
+		return rejected
+	}
 
-	// Delete any previously published records that are no longer present in record.Spec.Endpoints
+	// Delete any previously published records that are no longer present in record.Spec.Endpoints.
+	// The deletions go first, so that a record set can be replaced by one of another type for the
+	// same name, e.g. an A record by a CNAME, within the same change batch.
 	if action != string(deleteAction) {
-		lastPublishedEndpoints, err := p.endpointsFromZoneStatus(record, zoneID)
-		if err != nil {
-			return err
-		}
+		lastPublishedEndpoints := record.Status.ZoneEndpoints(zone)
+		var deletions []*route53.Change
 		for _, endpoint := range lastPublishedEndpoints {
-			if _, found := expectedEndpointsMap[endpoint.SetID()]; !found {
+			if _, found := expectedEndpointsMap[recordSetKeyForEndpoint(endpoint)]; !found {
 				change, err := p.changeForEndpoint(endpoint, string(deleteAction))
 				if err != nil {
 					return err
 				}
-				changes = append(changes, change)
+				deletions = append(deletions, change)
 			}
 		}
+		changes = append(deletions, changes...)
 	}
 
+	if len(changes) == 0 || action == string(deleteAction) {
+		p.changeIDs.set(record, zoneID, "")
+	}
 	if len(changes) == 0 {
 		return nil
 	}
-	input.ChangeBatch = &route53.ChangeBatch{
-		Changes: changes,
+	if p.config.OwnerID != "" {
+		lastPublishedEndpoints := record.Status.ZoneEndpoints(zone)
+		ownershipChanges, err := p.ownershipChanges(record, zoneID, changes, lastPublishedEndpoints)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, ownershipChanges...)
+	}
+	info, err := p.changeBatcher.submit(zoneID, changes)
+	if rejected, ok := invalidChangeBatchError(record.Spec.Endpoints, err); ok {
+		return rejected
 	}
-	resp, err := p.route53.ChangeResourceRecordSets(&input)
 	if err != nil {
-		return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zoneID, err)
+		return fmt.Errorf("couldn't update DNS record %s in zone %s: %w", record.Name, zoneID, err)
 	}
-	p.logger.Info("Updated DNS record", "record", record, "zone", zoneID, "response", resp)
+	if action != string(deleteAction) {
+		p.changeIDs.set(record, zoneID, aws.StringValue(info.Id))
+	}
+	p.logger.Info("Updated DNS record", "record", record, "zone", zoneID, "change", info)
 	return nil
 }
 
-func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*route53.Change, error) {
-	if endpoint.RecordType != string(v1.ARecordType) {
-		return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+// RejectedEndpoint is an endpoint that can't be published, and the reason why
+type RejectedEndpoint struct {
+	Endpoint *v1.Endpoint
+	Err      error
+}
+
+// RejectedEndpointsError is returned when some endpoints of a record can't be published, e.g. for an invalid target
+// or when Route53 rejects their change
+type RejectedEndpointsError struct {
+	Endpoints []RejectedEndpoint
+}
+
+func (e *RejectedEndpointsError) Error() string {
+	var rejected []string
+	for _, endpoint := range e.Endpoints {
+		rejected = append(rejected, fmt.Sprintf("%s: %v", recordSetKeyForEndpoint(endpoint.Endpoint), endpoint.Err))
 	}
+	return fmt.Sprintf("rejected endpoints: %s", strings.Join(rejected, ", "))
+}
+
+// RejectedEndpoints returns the reasons the endpoints were rejected
+func (e *RejectedEndpointsError) RejectedEndpoints() map[*v1.Endpoint]error {
+	rejected := make(map[*v1.Endpoint]error, len(e.Endpoints))
+	for _, endpoint := range e.Endpoints {
+		rejected[endpoint.Endpoint] = endpoint.Err
+	}
+	return rejected
+}
+
+func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*route53.Change, error) {
 	domain, targets := endpoint.DNSName, endpoint.Targets
 	if len(domain) == 0 {
 		return nil, fmt.Errorf("domain is required")
@@ -201,16 +320,23 @@ func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*rou
 		return nil, fmt.Errorf("targets is required")
 	}
 
-	var resourceRecords []*route53.ResourceRecord
-	for _, target := range endpoint.Targets {
-		resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(target)})
-	}
-
 	resourceRecordSet := &route53.ResourceRecordSet{
-		Name:            aws.String(endpoint.DNSName),
-		Type:            aws.String(route53.RRTypeA),
-		TTL:             aws.Int64(int64(endpoint.RecordTTL)),
-		ResourceRecords: resourceRecords,
+		Name: aws.String(endpoint.DNSName),
+		Type: aws.String(endpoint.RecordType),
+	}
+	if isAlias(endpoint) {
+		aliasTarget, err := p.aliasTargetForEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		resourceRecordSet.AliasTarget = aliasTarget
+	} else {
+		resourceRecords, err := resourceRecordsForEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		resourceRecordSet.TTL = aws.Int64(int64(endpoint.RecordTTL))
+		resourceRecordSet.ResourceRecords = resourceRecords
 	}
 
 	if endpoint.SetIdentifier != "" {
@@ -228,6 +354,10 @@ func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*rou
 		resourceRecordSet.Region = aws.String(prop.Value)
 	}
 	if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificFailover); ok {
+		// Route53 always considers a primary record without health check healthy, so it would never fail over
+		if _, hasHealthCheck := endpoint.GetProviderSpecificProperty(ProviderSpecificHealthCheckID); prop.Value == FailoverPrimary && !hasHealthCheck && !isAlias(endpoint) {
+			return nil, fmt.Errorf("primary failover record %s requires a health check", endpoint.DNSName)
+		}
 		resourceRecordSet.Failover = aws.String(prop.Value)
 	}
 	if _, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificMultiValueAnswer); ok {
@@ -236,6 +366,11 @@ func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*rou
 	if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificHealthCheckID); ok {
 		resourceRecordSet.HealthCheckId = aws.String(prop.Value)
 	}
+	if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificGeolocationContinentCode); ok {
+		resourceRecordSet.GeoLocation = &route53.GeoLocation{ContinentCode: aws.String(prop.Value)}
+	} else if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificGeolocationCountryCode); ok {
+		resourceRecordSet.GeoLocation = &route53.GeoLocation{CountryCode: aws.String(prop.Value)}
+	}
 
 	change := &route53.Change{
 		Action:            aws.String(action),
@@ -244,11 +379,97 @@ func (p *Provider) changeForEndpoint(endpoint *v1.Endpoint, action string) (*rou
 	return change, nil
 }
 
-func (p *Provider) endpointsFromZoneStatus(record *v1.DNSRecord, zoneID string) ([]*v1.Endpoint, error) {
-	for _, zoneStatus := range record.Status.Zones {
-		if zoneStatus.DNSZone.ID == zoneID {
-			return zoneStatus.Endpoints, nil
+func isAlias(endpoint *v1.Endpoint) bool {
+	prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificAlias)
+	return ok && prop.Value == "true"
+}
+
+// aliasTargetForEndpoint returns the alias target of an endpoint pointing to an
+// AWS load balancer hostname. Alias records are answered with the current IPs
+// of the load balancer by Route53, and have no TTL of their own.
+func (p *Provider) aliasTargetForEndpoint(endpoint *v1.Endpoint) (*route53.AliasTarget, error) {
+	if endpoint.RecordType != string(v1.ARecordType) && endpoint.RecordType != string(v1.AAAARecordType) {
+		return nil, fmt.Errorf("alias record %s must be of type A or AAAA, got %s", endpoint.DNSName, endpoint.RecordType)
+	}
+	if len(endpoint.Targets) != 1 {
+		return nil, fmt.Errorf("alias record %s must have exactly one target, got %d", endpoint.DNSName, len(endpoint.Targets))
+	}
+	target := endpoint.Targets[0]
+	hostedZoneID, ok := CanonicalHostedZone(target)
+	if !ok {
+		return nil, fmt.Errorf("alias record %s target %s is not a known AWS load balancer hostname", endpoint.DNSName, target)
+	}
+
+	evaluateTargetHealth := false
+	if prop, ok := endpoint.GetProviderSpecificProperty(ProviderSpecificEvaluateTargetHealth); ok {
+		value, err := strconv.ParseBool(prop.Value)
+		if err != nil {
+			p.logger.Error(err, "Failed parsing value, not evaluating target health", "property", ProviderSpecificEvaluateTargetHealth, "value", prop.Value)
+		}
+		evaluateTargetHealth = value
+	}
+
+	return &route53.AliasTarget{
+		DNSName:              aws.String(target),
+		HostedZoneId:         aws.String(hostedZoneID),
+		EvaluateTargetHealth: aws.Bool(evaluateTargetHealth),
+	}, nil
+}
+
+// resourceRecordsForEndpoint validates the targets of the endpoint against its
+// record type and returns them as Route53 resource records.
+func resourceRecordsForEndpoint(endpoint *v1.Endpoint) ([]*route53.ResourceRecord, error) {
+	var resourceRecords []*route53.ResourceRecord
+	switch v1.DNSRecordType(endpoint.RecordType) {
+	case v1.ARecordType:
+		for _, target := range endpoint.Targets {
+			if ip := net.ParseIP(target); ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("invalid A record target %s", target)
+			}
+			resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(target)})
 		}
+	case v1.AAAARecordType:
+		for _, target := range endpoint.Targets {
+			if ip := net.ParseIP(target); ip == nil || ip.To4() != nil {
+				return nil, fmt.Errorf("invalid AAAA record target %s", target)
+			}
+			resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(target)})
+		}
+	case v1.CNAMERecordType:
+		// A CNAME record set holds a single record
+		if len(endpoint.Targets) != 1 {
+			return nil, fmt.Errorf("CNAME record %s must have exactly one target, got %d", endpoint.DNSName, len(endpoint.Targets))
+		}
+		resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(endpoint.Targets[0])})
+	case v1.TXTRecordType:
+		// Route53 requires TXT values to be enclosed in quotes
+		for _, target := range endpoint.Targets {
+			resourceRecords = append(resourceRecords, &route53.ResourceRecord{Value: aws.String(quoteTXT(target))})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+	}
+	return resourceRecords, nil
+}
+
+func quoteTXT(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// recordSetKey identifies a Route53 resource record set within a hosted zone.
+type recordSetKey struct {
+	name          string
+	recordType    string
+	setIdentifier string
+}
+
+func recordSetKeyForEndpoint(endpoint *v1.Endpoint) recordSetKey {
+	return recordSetKey{
+		name:          strings.ToLower(strings.TrimSuffix(endpoint.DNSName, ".")),
+		recordType:    endpoint.RecordType,
+		setIdentifier: endpoint.SetIdentifier,
 	}
-	return []*v1.Endpoint{}, nil
 }
@@ -0,0 +1,246 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/go-logr/logr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+func TestChangeForEndpoint(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Endpoint       *v1.Endpoint
+		ExpectErr      bool
+		ExpectedType   string
+		ExpectedValues []string
+	}{
+		{
+			Name:           "A record",
+			Endpoint:       &v1.Endpoint{DNSName: "test.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1", "192.168.0.2"}},
+			ExpectedType:   "A",
+			ExpectedValues: []string{"192.168.0.1", "192.168.0.2"},
+		},
+		{
+			Name:      "A record with IPv6 target",
+			Endpoint:  &v1.Endpoint{DNSName: "test.example.com", RecordType: "A", Targets: v1.Targets{"2001:db8::1"}},
+			ExpectErr: true,
+		},
+		{
+			Name:           "AAAA record",
+			Endpoint:       &v1.Endpoint{DNSName: "test.example.com", RecordType: "AAAA", Targets: v1.Targets{"2001:db8::1"}},
+			ExpectedType:   "AAAA",
+			ExpectedValues: []string{"2001:db8::1"},
+		},
+		{
+			Name:      "AAAA record with IPv4 target",
+			Endpoint:  &v1.Endpoint{DNSName: "test.example.com", RecordType: "AAAA", Targets: v1.Targets{"192.168.0.1"}},
+			ExpectErr: true,
+		},
+		{
+			Name:           "CNAME record",
+			Endpoint:       &v1.Endpoint{DNSName: "test.example.com", RecordType: "CNAME", Targets: v1.Targets{"lb-123.elb.us-east-1.amazonaws.com"}},
+			ExpectedType:   "CNAME",
+			ExpectedValues: []string{"lb-123.elb.us-east-1.amazonaws.com"},
+		},
+		{
+			Name:      "CNAME record with several targets",
+			Endpoint:  &v1.Endpoint{DNSName: "test.example.com", RecordType: "CNAME", Targets: v1.Targets{"a.example.net", "b.example.net"}},
+			ExpectErr: true,
+		},
+		{
+			Name:           "TXT record",
+			Endpoint:       &v1.Endpoint{DNSName: "test.example.com", RecordType: "TXT", Targets: v1.Targets{"owner=glbc", `"quoted"`, `say "hi"`}},
+			ExpectedType:   "TXT",
+			ExpectedValues: []string{`"owner=glbc"`, `"quoted"`, `"say \"hi\""`},
+		},
+		{
+			Name:      "unsupported record type",
+			Endpoint:  &v1.Endpoint{DNSName: "test.example.com", RecordType: "SRV", Targets: v1.Targets{"0 5 5060 sip.example.com"}},
+			ExpectErr: true,
+		},
+	}
+
+	p := &Provider{logger: logr.Discard()}
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			change, err := p.changeForEndpoint(testCase.Endpoint, string(upsertAction))
+			if testCase.ExpectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got change %v", change)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := aws.StringValue(change.ResourceRecordSet.Type); got != testCase.ExpectedType {
+				t.Fatalf("expected record type %s, got %s", testCase.ExpectedType, got)
+			}
+			var values []string
+			for _, rr := range change.ResourceRecordSet.ResourceRecords {
+				values = append(values, aws.StringValue(rr.Value))
+			}
+			if len(values) != len(testCase.ExpectedValues) {
+				t.Fatalf("expected values %v, got %v", testCase.ExpectedValues, values)
+			}
+			for i := range values {
+				if values[i] != testCase.ExpectedValues[i] {
+					t.Fatalf("expected values %v, got %v", testCase.ExpectedValues, values)
+				}
+			}
+		})
+	}
+}
+
+func TestChangeForAliasEndpoint(t *testing.T) {
+	p := &Provider{logger: logr.Discard()}
+
+	endpoint := &v1.Endpoint{
+		DNSName:       "test.example.com",
+		RecordType:    "A",
+		SetIdentifier: "lb-1234.elb.eu-west-1.amazonaws.com",
+		Targets:       v1.Targets{"lb-1234.elb.eu-west-1.amazonaws.com"},
+		RecordTTL:     60,
+	}
+	endpoint.SetProviderSpecific(ProviderSpecificAlias, "true")
+	endpoint.SetProviderSpecific(ProviderSpecificEvaluateTargetHealth, "true")
+	endpoint.SetProviderSpecific(ProviderSpecificWeight, "120")
+
+	change, err := p.changeForEndpoint(endpoint, string(upsertAction))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rrset := change.ResourceRecordSet
+	if rrset.AliasTarget == nil || rrset.TTL != nil || len(rrset.ResourceRecords) != 0 {
+		t.Fatalf("expected an alias record set without TTL nor records, got %v", rrset)
+	}
+	if got := aws.StringValue(rrset.AliasTarget.HostedZoneId); got != "Z2IFOLAFXWLO4F" {
+		t.Fatalf("expected the hosted zone of the eu-west-1 network load balancers, got %s", got)
+	}
+	if !aws.BoolValue(rrset.AliasTarget.EvaluateTargetHealth) {
+		t.Fatalf("expected target health to be evaluated")
+	}
+	if aws.Int64Value(rrset.Weight) != 120 {
+		t.Fatalf("expected weight to be set on the alias record, got %v", rrset.Weight)
+	}
+
+	endpoint.Targets = v1.Targets{"lb.example.net"}
+	if _, err := p.changeForEndpoint(endpoint, string(upsertAction)); err == nil {
+		t.Fatalf("expected an error for an alias to an unknown load balancer")
+	}
+}
+
+func TestChangeForGeolocationEndpoint(t *testing.T) {
+	p := &Provider{logger: logr.Discard()}
+
+	continent := &v1.Endpoint{DNSName: "xyz.example.com", RecordType: "CNAME", SetIdentifier: "NA", Targets: v1.Targets{"xyz.na.example.com"}}
+	continent.SetProviderSpecific(ProviderSpecificGeolocationContinentCode, "NA")
+	change, err := p.changeForEndpoint(continent, string(upsertAction))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if geo := change.ResourceRecordSet.GeoLocation; geo == nil || aws.StringValue(geo.ContinentCode) != "NA" || geo.CountryCode != nil {
+		t.Fatalf("expected NA continent geolocation, got %v", geo)
+	}
+
+	defaultLocation := &v1.Endpoint{DNSName: "xyz.example.com", RecordType: "CNAME", SetIdentifier: "default", Targets: v1.Targets{"xyz.na.example.com"}}
+	defaultLocation.SetProviderSpecific(ProviderSpecificGeolocationCountryCode, "*")
+	change, err = p.changeForEndpoint(defaultLocation, string(upsertAction))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if geo := change.ResourceRecordSet.GeoLocation; geo == nil || aws.StringValue(geo.CountryCode) != "*" || geo.ContinentCode != nil {
+		t.Fatalf("expected default geolocation, got %v", geo)
+	}
+}
+
+func TestChangeForLatencyEndpoint(t *testing.T) {
+	p := &Provider{logger: logr.Discard()}
+
+	endpoint := &v1.Endpoint{DNSName: "xyz.example.com", RecordType: "CNAME", SetIdentifier: "us-east-1", Targets: v1.Targets{"xyz.us-east-1.example.com"}}
+	endpoint.SetProviderSpecific(ProviderSpecificRegion, "us-east-1")
+	change, err := p.changeForEndpoint(endpoint, string(upsertAction))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := aws.StringValue(change.ResourceRecordSet.Region); got != "us-east-1" {
+		t.Fatalf("expected us-east-1 region, got %q", got)
+	}
+	if change.ResourceRecordSet.Weight != nil || change.ResourceRecordSet.GeoLocation != nil {
+		t.Fatalf("expected a latency record set only, got %v", change.ResourceRecordSet)
+	}
+}
+
+func TestChangeForFailoverEndpoint(t *testing.T) {
+	p := &Provider{logger: logr.Discard()}
+
+	primary := &v1.Endpoint{DNSName: "xyz.example.com", RecordType: "A", SetIdentifier: "primary", Targets: v1.Targets{"192.168.0.1"}}
+	primary.SetProviderSpecific(ProviderSpecificFailover, FailoverPrimary)
+	if _, err := p.changeForEndpoint(primary, string(upsertAction)); err == nil {
+		t.Fatalf("expected an error for a primary record without health check")
+	}
+
+	primary.SetProviderSpecific(ProviderSpecificHealthCheckID, "abc")
+	change, err := p.changeForEndpoint(primary, string(upsertAction))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if aws.StringValue(change.ResourceRecordSet.Failover) != FailoverPrimary || aws.StringValue(change.ResourceRecordSet.HealthCheckId) != "abc" {
+		t.Fatalf("expected a primary record with its health check, got %v", change.ResourceRecordSet)
+	}
+
+	secondary := &v1.Endpoint{DNSName: "xyz.example.com", RecordType: "A", SetIdentifier: "secondary", Targets: v1.Targets{"192.168.1.1", "192.168.1.2"}}
+	secondary.SetProviderSpecific(ProviderSpecificFailover, FailoverSecondary)
+	if _, err := p.changeForEndpoint(secondary, string(upsertAction)); err != nil {
+		t.Fatalf("unexpected error for a secondary record without health check: %v", err)
+	}
+}
+
+func TestRecordSetKeyForEndpoint(t *testing.T) {
+	a := &v1.Endpoint{DNSName: "test.example.com", RecordType: "A", SetIdentifier: "cluster1"}
+	cname := &v1.Endpoint{DNSName: "test.example.com.", RecordType: "CNAME", SetIdentifier: "cluster1"}
+	if recordSetKeyForEndpoint(a) == recordSetKeyForEndpoint(cname) {
+		t.Fatalf("expected record sets of different types to have different keys")
+	}
+	upper := &v1.Endpoint{DNSName: "Test.Example.com.", RecordType: "A", SetIdentifier: "cluster1"}
+	if recordSetKeyForEndpoint(a) != recordSetKeyForEndpoint(upper) {
+		t.Fatalf("expected record set names to be compared case insensitively")
+	}
+}
+
+func TestRejectedEndpoints(t *testing.T) {
+	valid := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 60}
+	invalid := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c2", Targets: v1.Targets{"lb.example.com"}, RecordTTL: 60}
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec:       v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{valid, invalid}},
+	}
+
+	fake := &fakeRoute53{}
+	p := &Provider{
+		route53: &InstrumentedRoute53{fake},
+		zoneIDs: newZoneIDs(nil),
+		logger:  logr.Discard(),
+	}
+	p.changeBatcher = newChangeBatcher(p.route53, 0, p.logger)
+	p.changeIDs = newChangeIDs()
+
+	err := p.Ensure(record, v1.DNSZone{ID: "Z1"})
+	var rejectedErr *RejectedEndpointsError
+	if !errors.As(err, &rejectedErr) {
+		t.Fatalf("expected rejected endpoints, got %v", err)
+	}
+	rejected := rejectedErr.RejectedEndpoints()
+	if _, ok := rejected[invalid]; !ok || len(rejected) != 1 {
+		t.Errorf("expected endpoint %v to be rejected, got %v", invalid, rejected)
+	}
+	if len(fake.batches) != 0 {
+		t.Errorf("expected no change to be submitted, got %v", fake.batches)
+	}
+}
@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// Drift compares the record sets of the record endpoints with the record sets published to the hosted zone.
+func (p *Provider) Drift(record *v1.DNSRecord, zone v1.DNSZone) ([]string, error) {
+	zoneID, err := p.zoneIDs.get(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := map[recordSetKey]*route53.ResourceRecordSet{}
+	names := map[string]struct{}{}
+	for _, endpoint := range record.Spec.Endpoints {
+		change, err := p.changeForEndpoint(endpoint, string(upsertAction))
+		if err != nil {
+			return nil, err
+		}
+		key := recordSetKeyForEndpoint(endpoint)
+		desired[key] = change.ResourceRecordSet
+		names[key.name] = struct{}{}
+	}
+
+	actual := map[recordSetKey]*route53.ResourceRecordSet{}
+	for name := range names {
+		recordSets, err := p.recordSets(zoneID, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, recordSet := range recordSets {
+			actual[recordSetKeyForRecordSet(recordSet)] = recordSet
+		}
+	}
+
+	return recordSetsDrift(desired, actual), nil
+}
+
+// recordSets returns the record sets of the hosted zone with the name, listed by name starting from it
+func (p *Provider) recordSets(zoneID, name string) ([]*route53.ResourceRecordSet, error) {
+	name = normalizeRecordSetName(name)
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneID),
+		StartRecordName: aws.String(name),
+	}
+	var recordSets []*route53.ResourceRecordSet
+	err := p.route53.ListResourceRecordSetsPages(input, func(output *route53.ListResourceRecordSetsOutput, _ bool) bool {
+		for _, recordSet := range output.ResourceRecordSets {
+			if normalizeRecordSetName(aws.StringValue(recordSet.Name)) != name {
+				return false
+			}
+			recordSets = append(recordSets, recordSet)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record sets %s in zone %s: %v", name, zoneID, err)
+	}
+	return recordSets, nil
+}
+
+// recordSetsDrift returns the differences between the desired and actual record sets
+func recordSetsDrift(desired, actual map[recordSetKey]*route53.ResourceRecordSet) []string {
+	keys := make([]recordSetKey, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	var drift []string
+	for _, key := range keys {
+		recordSet, ok := actual[key]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("record set %s is missing", key))
+			continue
+		}
+		if !recordSetsEqual(desired[key], recordSet) {
+			drift = append(drift, fmt.Sprintf("record set %s has been modified", key))
+		}
+	}
+	return drift
+}
+
+func recordSetsEqual(a, b *route53.ResourceRecordSet) bool {
+	if aws.Int64Value(a.TTL) != aws.Int64Value(b.TTL) ||
+		!int64PtrEqual(a.Weight, b.Weight) ||
+		aws.StringValue(a.Region) != aws.StringValue(b.Region) ||
+		aws.StringValue(a.Failover) != aws.StringValue(b.Failover) ||
+		aws.BoolValue(a.MultiValueAnswer) != aws.BoolValue(b.MultiValueAnswer) ||
+		aws.StringValue(a.HealthCheckId) != aws.StringValue(b.HealthCheckId) {
+		return false
+	}
+	if (a.GeoLocation == nil) != (b.GeoLocation == nil) {
+		return false
+	}
+	if a.GeoLocation != nil && (aws.StringValue(a.GeoLocation.ContinentCode) != aws.StringValue(b.GeoLocation.ContinentCode) ||
+		aws.StringValue(a.GeoLocation.CountryCode) != aws.StringValue(b.GeoLocation.CountryCode)) {
+		return false
+	}
+	if (a.AliasTarget == nil) != (b.AliasTarget == nil) {
+		return false
+	}
+	if a.AliasTarget != nil && (normalizeRecordSetName(aws.StringValue(a.AliasTarget.DNSName)) != normalizeRecordSetName(aws.StringValue(b.AliasTarget.DNSName)) ||
+		aws.StringValue(a.AliasTarget.HostedZoneId) != aws.StringValue(b.AliasTarget.HostedZoneId) ||
+		aws.BoolValue(a.AliasTarget.EvaluateTargetHealth) != aws.BoolValue(b.AliasTarget.EvaluateTargetHealth)) {
+		return false
+	}
+	return strings.Join(resourceRecordValues(a), ",") == strings.Join(resourceRecordValues(b), ",")
+}
+
+func int64PtrEqual(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func resourceRecordValues(recordSet *route53.ResourceRecordSet) []string {
+	var values []string
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		value := aws.StringValue(resourceRecord.Value)
+		if aws.StringValue(recordSet.Type) == route53.RRTypeCname {
+			value = normalizeRecordSetName(value)
+		}
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func recordSetKeyForRecordSet(recordSet *route53.ResourceRecordSet) recordSetKey {
+	return recordSetKey{
+		name:          normalizeRecordSetName(aws.StringValue(recordSet.Name)),
+		recordType:    aws.StringValue(recordSet.Type),
+		setIdentifier: aws.StringValue(recordSet.SetIdentifier),
+	}
+}
+
+// normalizeRecordSetName returns the name of a record set as set on endpoints. Route53 returns fully qualified
+// names, with the * character escaped.
+func normalizeRecordSetName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.ReplaceAll(name, `\052`, "*"), "."))
+}
+
+func (k recordSetKey) String() string {
+	if k.setIdentifier == "" {
+		return fmt.Sprintf("%s %s", k.recordType, k.name)
+	}
+	return fmt.Sprintf("%s %s (%s)", k.recordType, k.name, k.setIdentifier)
+}
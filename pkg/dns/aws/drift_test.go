@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestRecordSetsDrift(t *testing.T) {
+	weighted := recordSetKey{name: "*.example.com", recordType: "A", setIdentifier: "192.168.0.1"}
+	cname := recordSetKey{name: "www.example.com", recordType: "CNAME"}
+	desired := map[recordSetKey]*route53.ResourceRecordSet{
+		weighted: {
+			Name:            aws.String("*.example.com"),
+			Type:            aws.String("A"),
+			SetIdentifier:   aws.String("192.168.0.1"),
+			TTL:             aws.Int64(60),
+			Weight:          aws.Int64(120),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("192.168.0.1")}},
+		},
+		cname: {
+			Name:            aws.String("www.example.com"),
+			Type:            aws.String("CNAME"),
+			TTL:             aws.Int64(60),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("lb.example.com")}},
+		},
+	}
+
+	cases := []struct {
+		Name          string
+		Actual        []*route53.ResourceRecordSet
+		ExpectedDrift []string
+	}{
+		{
+			Name: "in sync",
+			Actual: []*route53.ResourceRecordSet{
+				{
+					Name:            aws.String(`\052.example.com.`),
+					Type:            aws.String("A"),
+					SetIdentifier:   aws.String("192.168.0.1"),
+					TTL:             aws.Int64(60),
+					Weight:          aws.Int64(120),
+					ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("192.168.0.1")}},
+				},
+				{
+					Name:            aws.String("www.example.com."),
+					Type:            aws.String("CNAME"),
+					TTL:             aws.Int64(60),
+					ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("lb.example.com.")}},
+				},
+			},
+		},
+		{
+			Name: "missing and modified",
+			Actual: []*route53.ResourceRecordSet{
+				{
+					Name:            aws.String(`\052.example.com.`),
+					Type:            aws.String("A"),
+					SetIdentifier:   aws.String("192.168.0.1"),
+					TTL:             aws.Int64(60),
+					Weight:          aws.Int64(0),
+					ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("192.168.0.1")}},
+				},
+			},
+			ExpectedDrift: []string{
+				"record set A *.example.com (192.168.0.1) has been modified",
+				"record set CNAME www.example.com is missing",
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			actual := map[recordSetKey]*route53.ResourceRecordSet{}
+			for _, recordSet := range testCase.Actual {
+				actual[recordSetKeyForRecordSet(recordSet)] = recordSet
+			}
+			drift := recordSetsDrift(desired, actual)
+			if !reflect.DeepEqual(drift, testCase.ExpectedDrift) {
+				t.Fatalf("expected drift %v, got %v", testCase.ExpectedDrift, drift)
+			}
+		})
+	}
+}
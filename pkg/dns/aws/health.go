@@ -3,18 +3,34 @@ package aws
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/rs/xid"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/route53"
 
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/slice"
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 )
 
 const (
 	idTag = "kuadrant.dev/healthcheck"
+	// ownerTag is the ID of the GLBC instance the health check is created by, when set
+	ownerTag = "kuadrant.dev/owner"
+
+	// healthyCheckersRatio is the ratio of the Route53 health checkers that must
+	// report the endpoint healthy for the endpoint to be considered healthy
+	healthyCheckersRatio = 0.18
+
+	// defaultRequestInterval is the number of seconds between the requests of the Route53 health checkers when
+	// the request interval isn't set
+	defaultRequestInterval = 30
+
+	// maxTaggedResources is the maximum number of resources the tags can be listed for per request
+	maxTaggedResources = 10
 )
 
 var (
@@ -22,18 +38,26 @@ var (
 )
 
 type Route53HealthCheckReconciler struct {
-	client *InstrumentedRoute53
-	logger logr.Logger
+	client  *InstrumentedRoute53
+	ownerID string
+	logger  logr.Logger
 }
 
-func newRoute53HealthCheckReconciler(c *InstrumentedRoute53, l logr.Logger) *Route53HealthCheckReconciler {
+func newRoute53HealthCheckReconciler(c *InstrumentedRoute53, ownerID string, l logr.Logger) *Route53HealthCheckReconciler {
 	return &Route53HealthCheckReconciler{
-		client: c,
-		logger: l.WithName("health"),
+		client:  c,
+		ownerID: ownerID,
+		logger:  l.WithName("health"),
 	}
 }
 
-func (r *Route53HealthCheckReconciler) reconcile(ctx context.Context, spec v1.HealthCheck, endpoint *v1.Endpoint) error {
+func (r *Route53HealthCheckReconciler) reconcile(ctx context.Context, spec v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
+	// The health of alias targets is evaluated by Route53 itself
+	if isAlias(endpoint) {
+		r.logger.V(3).Info("Skipping health check for alias record", "endpoint", endpoint.SetID())
+		return nil
+	}
+
 	healthCheck, exists, err := r.findHealthCheck(ctx, endpoint)
 	if err != nil {
 		return err
@@ -45,11 +69,26 @@ func (r *Route53HealthCheckReconciler) reconcile(ctx context.Context, spec v1.He
 		}
 	}()
 
+	if exists && healthCheckReplaced(healthCheck, spec) {
+		// The type and request interval of a health check can't be updated
+		r.logger.Info("Replacing health check", "id", *healthCheck.Id, "endpoint", endpoint.SetID())
+		if _, err := r.client.DeleteHealthCheckWithContext(ctx, &route53.DeleteHealthCheckInput{
+			HealthCheckId: healthCheck.Id,
+		}); err != nil {
+			return err
+		}
+		endpoint.DeleteProviderSpecific(ProviderSpecificHealthCheckID)
+
+		// The caller reference of a deleted health check can't be reused
+		healthCheck, err = r.createHealthCheck(ctx, spec, endpoint, aws.String(xid.New().String()))
+		return err
+	}
+
 	if exists {
 		return r.updateHealthCheck(ctx, spec, endpoint, healthCheck)
 	}
 
-	healthCheck, err = r.createHealthCheck(ctx, spec, endpoint)
+	healthCheck, err = r.createHealthCheck(ctx, spec, endpoint, callerReference(spec.Id))
 	return err
 }
 
@@ -74,6 +113,101 @@ func (r *Route53HealthCheckReconciler) deleteHealthCheck(ctx context.Context, en
 	return err
 }
 
+func (r *Route53HealthCheckReconciler) status(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	id, hasId := getHealthCheckId(endpoint)
+	if !hasId {
+		return v1.EndpointUnknown, nil
+	}
+
+	response, err := r.client.GetHealthCheckStatusWithContext(ctx, &route53.GetHealthCheckStatusInput{
+		HealthCheckId: &id,
+	})
+	if err != nil {
+		return v1.EndpointUnknown, err
+	}
+
+	return healthFromObservations(response.HealthCheckObservations), nil
+}
+
+// managedHealthChecks returns the domain names of the health checks tagged with idTag, by health check ID. Only the
+// health checks owned by the instance are returned, i.e. tagged with its owner ID, as the GLBC instances sharing the
+// account may manage health checks of the same zones. None is returned when the instance has no owner ID.
+func (r *Route53HealthCheckReconciler) managedHealthChecks(ctx context.Context) (map[string]string, error) {
+	if r.ownerID == "" {
+		return map[string]string{}, nil
+	}
+	domainNames := map[string]string{}
+	err := r.client.ListHealthChecksPagesWithContext(ctx, &route53.ListHealthChecksInput{}, func(output *route53.ListHealthChecksOutput, _ bool) bool {
+		for _, healthCheck := range output.HealthChecks {
+			domainName := ""
+			if healthCheck.HealthCheckConfig != nil {
+				domainName = aws.StringValue(healthCheck.HealthCheckConfig.FullyQualifiedDomainName)
+			}
+			domainNames[aws.StringValue(healthCheck.Id)] = domainName
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(domainNames))
+	for id := range domainNames {
+		ids = append(ids, id)
+	}
+	managed := map[string]string{}
+	// The tags of at most maxTaggedResources health checks can be listed per request
+	for start := 0; start < len(ids); start += maxTaggedResources {
+		end := start + maxTaggedResources
+		if end > len(ids) {
+			end = len(ids)
+		}
+		output, err := r.client.ListTagsForResourcesWithContext(ctx, &route53.ListTagsForResourcesInput{
+			ResourceIds:  aws.StringSlice(ids[start:end]),
+			ResourceType: aws.String(route53.TagResourceTypeHealthcheck),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, tagSet := range output.ResourceTagSets {
+			tagged, owner := false, ""
+			for _, tag := range tagSet.Tags {
+				switch aws.StringValue(tag.Key) {
+				case idTag:
+					tagged = true
+				case ownerTag:
+					owner = aws.StringValue(tag.Value)
+				}
+			}
+			if tagged && owner == r.ownerID {
+				id := aws.StringValue(tagSet.ResourceId)
+				managed[id] = domainNames[id]
+			}
+		}
+	}
+	return managed, nil
+}
+
+// healthFromObservations evaluates the health of an endpoint the way Route53
+// does, i.e. healthy when more than 18% of the health checkers report it healthy
+func healthFromObservations(observations []*route53.HealthCheckObservation) v1.EndpointHealth {
+	if len(observations) == 0 {
+		return v1.EndpointUnknown
+	}
+
+	healthy := 0
+	for _, observation := range observations {
+		if observation.StatusReport != nil && strings.HasPrefix(aws.StringValue(observation.StatusReport.Status), "Success") {
+			healthy++
+		}
+	}
+
+	if float64(healthy)/float64(len(observations)) > healthyCheckersRatio {
+		return v1.EndpointHealthy
+	}
+	return v1.EndpointUnhealthy
+}
+
 func (r *Route53HealthCheckReconciler) findHealthCheck(ctx context.Context, endpoint *v1.Endpoint) (*route53.HealthCheck, bool, error) {
 	id, hasId := getHealthCheckId(endpoint)
 	if !hasId {
@@ -83,6 +217,10 @@ func (r *Route53HealthCheckReconciler) findHealthCheck(ctx context.Context, endp
 	response, err := r.client.GetHealthCheckWithContext(ctx, &route53.GetHealthCheckInput{
 		HealthCheckId: &id,
 	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == route53.ErrCodeNoSuchHealthCheck {
+		// The health check has been deleted outside of the controller
+		return nil, false, nil
+	}
 	if err != nil {
 		return nil, false, err
 	}
@@ -91,39 +229,62 @@ func (r *Route53HealthCheckReconciler) findHealthCheck(ctx context.Context, endp
 
 }
 
-func (r *Route53HealthCheckReconciler) createHealthCheck(ctx context.Context, spec v1.HealthCheck, endpoint *v1.Endpoint) (*route53.HealthCheck, error) {
+func (r *Route53HealthCheckReconciler) createHealthCheck(ctx context.Context, spec v1.EndpointHealthCheck, endpoint *v1.Endpoint, reference *string) (*route53.HealthCheck, error) {
 	address, _ := endpoint.GetAddress()
-	host := endpoint.DNSName
+	host := healthCheckHost(endpoint)
+
+	config := &route53.HealthCheckConfig{
+		IPAddress:                &address,
+		FullyQualifiedDomainName: &host,
+		Port:                     spec.Port,
+		Type:                     healthCheckType(spec),
+		FailureThreshold:         spec.FailureThreshold,
+		RequestInterval:          spec.Interval,
+	}
+	if len(spec.Regions) > 0 {
+		config.Regions = aws.StringSlice(spec.Regions)
+	}
+	switch *config.Type {
+	case route53.HealthCheckTypeHttp, route53.HealthCheckTypeHttps:
+		config.ResourcePath = &spec.Path
+	case route53.HealthCheckTypeHttpStrMatch, route53.HealthCheckTypeHttpsStrMatch:
+		config.ResourcePath = &spec.Path
+		config.SearchString = &spec.SearchString
+	}
+	// The host is sent as the TLS server name, so that the endpoint presents the certificate of the host
+	if isHTTPS(config.Type) {
+		config.EnableSNI = aws.Bool(true)
+	}
 
 	// Create the health check
 	output, err := r.client.CreateHealthCheck(&route53.CreateHealthCheckInput{
-		CallerReference: callerReference(spec.Id),
-		HealthCheckConfig: &route53.HealthCheckConfig{
-			IPAddress:                &address,
-			FullyQualifiedDomainName: &host,
-			Port:                     spec.Port,
-			ResourcePath:             &spec.Path,
-			Type:                     healthCheckType(spec.Protocol),
-			FailureThreshold:         spec.FailureThreshold,
-		},
+		CallerReference:   reference,
+		HealthCheckConfig: config,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	name := spec.Name
-	// Add the tag to identify it
-	_, err = r.client.ChangeTagsForResourceWithContext(ctx, &route53.ChangeTagsForResourceInput{
-		AddTags: []*route53.Tag{
-			{
-				Key:   aws.String(idTag),
-				Value: aws.String(spec.Id),
-			},
-			{
-				Key:   aws.String("Name"),
-				Value: &name,
-			},
+	// Add the tags to identify it, and the instance owning it
+	tags := []*route53.Tag{
+		{
+			Key:   aws.String(idTag),
+			Value: aws.String(spec.Id),
+		},
+		{
+			Key:   aws.String("Name"),
+			Value: &name,
 		},
+	}
+	if r.ownerID != "" {
+		tags = append(tags, &route53.Tag{
+			Key:   aws.String(ownerTag),
+			Value: aws.String(r.ownerID),
+		})
+	}
+	_, err = r.client.ChangeTagsForResourceWithContext(ctx, &route53.ChangeTagsForResourceInput{
+		AddTags:      tags,
 		ResourceId:   output.HealthCheck.Id,
 		ResourceType: aws.String(route53.TagResourceTypeHealthcheck),
 	})
@@ -134,7 +295,7 @@ func (r *Route53HealthCheckReconciler) createHealthCheck(ctx context.Context, sp
 	return output.HealthCheck, nil
 }
 
-func (r *Route53HealthCheckReconciler) updateHealthCheck(ctx context.Context, spec v1.HealthCheck, endpoint *v1.Endpoint, healthCheck *route53.HealthCheck) error {
+func (r *Route53HealthCheckReconciler) updateHealthCheck(ctx context.Context, spec v1.EndpointHealthCheck, endpoint *v1.Endpoint, healthCheck *route53.HealthCheck) error {
 	diff := healthCheckDiff(healthCheck, spec, endpoint)
 	if diff == nil {
 		return nil
@@ -150,10 +311,18 @@ func (r *Route53HealthCheckReconciler) updateHealthCheck(ctx context.Context, sp
 	return nil
 }
 
+// healthCheckHost returns the host the endpoint is health checked with, sent as Host header and TLS server name
+func healthCheckHost(endpoint *v1.Endpoint) string {
+	if host, ok := endpoint.GetProviderSpecific(ProviderSpecificHealthCheckHost); ok {
+		return host
+	}
+	return endpoint.DNSName
+}
+
 // healthCheckDiff creates a `UpdateHealthCheckInput` object with the fields to
 // update on healthCheck based on the given spec.
 // If the health check matches the spec, returns `nil`
-func healthCheckDiff(healthCheck *route53.HealthCheck, spec v1.HealthCheck, endpoint *v1.Endpoint) *route53.UpdateHealthCheckInput {
+func healthCheckDiff(healthCheck *route53.HealthCheck, spec v1.EndpointHealthCheck, endpoint *v1.Endpoint) *route53.UpdateHealthCheckInput {
 	var result *route53.UpdateHealthCheckInput
 
 	diff := func() *route53.UpdateHealthCheckInput {
@@ -166,17 +335,25 @@ func healthCheckDiff(healthCheck *route53.HealthCheck, spec v1.HealthCheck, endp
 		return result
 	}
 
-	if !strValuesEqual(&endpoint.DNSName, healthCheck.HealthCheckConfig.FullyQualifiedDomainName) {
-		diff().FullyQualifiedDomainName = &endpoint.DNSName
+	host := healthCheckHost(endpoint)
+	if !strValuesEqual(&host, healthCheck.HealthCheckConfig.FullyQualifiedDomainName) {
+		diff().FullyQualifiedDomainName = &host
 	}
 
 	address, _ := endpoint.GetAddress()
 	if !strValuesEqual(&address, healthCheck.HealthCheckConfig.IPAddress) {
 		diff().IPAddress = &address
 	}
-	if !strValuesEqual(&spec.Path, healthCheck.HealthCheckConfig.ResourcePath) {
+	healthCheckType := aws.StringValue(healthCheck.HealthCheckConfig.Type)
+	if healthCheckType != route53.HealthCheckTypeTcp && !strValuesEqual(&spec.Path, healthCheck.HealthCheckConfig.ResourcePath) {
 		diff().ResourcePath = &spec.Path
 	}
+	if isStrMatch(healthCheckType) && !strValuesEqual(&spec.SearchString, healthCheck.HealthCheckConfig.SearchString) {
+		diff().SearchString = &spec.SearchString
+	}
+	if isHTTPS(&healthCheckType) && !aws.BoolValue(healthCheck.HealthCheckConfig.EnableSNI) {
+		diff().EnableSNI = aws.Bool(true)
+	}
 
 	if !intValuesEqual(spec.Port, healthCheck.HealthCheckConfig.Port) {
 		diff().Port = spec.Port
@@ -185,9 +362,38 @@ func healthCheckDiff(healthCheck *route53.HealthCheck, spec v1.HealthCheck, endp
 		diff().FailureThreshold = spec.FailureThreshold
 	}
 
+	// The health checkers of all the regions are used when no region is set
+	regions, current := spec.Regions, aws.StringValueSlice(healthCheck.HealthCheckConfig.Regions)
+	if len(regions) == 0 {
+		regions = route53.HealthCheckRegion_Values()
+	}
+	if len(current) == 0 {
+		current = route53.HealthCheckRegion_Values()
+	}
+	if !regionsEqual(regions, current) {
+		if len(spec.Regions) == 0 {
+			diff().ResetElements = aws.StringSlice([]string{route53.ResettableElementNameRegions})
+		} else {
+			diff().Regions = aws.StringSlice(spec.Regions)
+		}
+	}
+
 	return result
 }
 
+// healthCheckReplaced returns whether the health check must be replaced to match the spec, as its type or request
+// interval differ
+func healthCheckReplaced(healthCheck *route53.HealthCheck, spec v1.EndpointHealthCheck) bool {
+	if aws.StringValue(healthCheck.HealthCheckConfig.Type) != aws.StringValue(healthCheckType(spec)) {
+		return true
+	}
+	interval := aws.Int64Value(spec.Interval)
+	if interval == 0 {
+		interval = defaultRequestInterval
+	}
+	return aws.Int64Value(healthCheck.HealthCheckConfig.RequestInterval) != interval
+}
+
 func init() {
 	sid := xid.New()
 	callerReference = func(s string) *string {
@@ -195,20 +401,49 @@ func init() {
 	}
 }
 
-func healthCheckType(protocol *v1.HealthCheckProtocol) *string {
-	if protocol == nil {
-		return nil
+// healthCheckType returns the type of the health check of the spec, HTTP by default. The HTTP and HTTPS health
+// checks with a search string are string matching health checks.
+func healthCheckType(spec v1.EndpointHealthCheck) *string {
+	protocol := v1.HealthCheckProtocolHTTP
+	if spec.Protocol != nil {
+		protocol = *spec.Protocol
 	}
 
-	switch *protocol {
-	case v1.HealthCheckProtocolHTTP:
-		return aws.String(route53.HealthCheckTypeHttp)
-
+	switch protocol {
 	case v1.HealthCheckProtocolHTTPS:
+		if spec.SearchString != "" {
+			return aws.String(route53.HealthCheckTypeHttpsStrMatch)
+		}
 		return aws.String(route53.HealthCheckTypeHttps)
+
+	case v1.HealthCheckProtocolTCP:
+		return aws.String(route53.HealthCheckTypeTcp)
 	}
 
-	return nil
+	if spec.SearchString != "" {
+		return aws.String(route53.HealthCheckTypeHttpStrMatch)
+	}
+	return aws.String(route53.HealthCheckTypeHttp)
+}
+
+func isHTTPS(healthCheckType *string) bool {
+	return aws.StringValue(healthCheckType) == route53.HealthCheckTypeHttps || aws.StringValue(healthCheckType) == route53.HealthCheckTypeHttpsStrMatch
+}
+
+func isStrMatch(healthCheckType string) bool {
+	return healthCheckType == route53.HealthCheckTypeHttpStrMatch || healthCheckType == route53.HealthCheckTypeHttpsStrMatch
+}
+
+func regionsEqual(regions1, regions2 []string) bool {
+	if len(regions1) != len(regions2) {
+		return false
+	}
+	for _, region := range regions1 {
+		if !slice.ContainsString(regions2, region) {
+			return false
+		}
+	}
+	return true
 }
 
 func strValuesEqual(str1, str2 *string) bool {
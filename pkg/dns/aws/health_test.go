@@ -0,0 +1,338 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+func TestHealthFromObservations(t *testing.T) {
+	observations := func(statuses ...string) []*route53.HealthCheckObservation {
+		var result []*route53.HealthCheckObservation
+		for _, status := range statuses {
+			result = append(result, &route53.HealthCheckObservation{
+				StatusReport: &route53.StatusReport{Status: aws.String(status)},
+			})
+		}
+		return result
+	}
+
+	success := "Success: HTTP Status Code 200, OK"
+	failure := "Failure: Connection timed out."
+
+	testCases := []struct {
+		name         string
+		observations []*route53.HealthCheckObservation
+		expected     v1.EndpointHealth
+	}{
+		{
+			name:     "no observations",
+			expected: v1.EndpointUnknown,
+		},
+		{
+			name:         "all checkers succeed",
+			observations: observations(success, success, success),
+			expected:     v1.EndpointHealthy,
+		},
+		{
+			name:         "all checkers fail",
+			observations: observations(failure, failure, failure),
+			expected:     v1.EndpointUnhealthy,
+		},
+		{
+			name:         "more than 18% of the checkers succeed",
+			observations: observations(success, failure, failure, failure),
+			expected:     v1.EndpointHealthy,
+		},
+		{
+			name:         "less than 18% of the checkers succeed",
+			observations: observations(success, failure, failure, failure, failure, failure),
+			expected:     v1.EndpointUnhealthy,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if health := healthFromObservations(testCase.observations); health != testCase.expected {
+				t.Fatalf("expected %s, got %s", testCase.expected, health)
+			}
+		})
+	}
+}
+
+func TestHealthCheckType(t *testing.T) {
+	protocol := func(p v1.HealthCheckProtocol) *v1.HealthCheckProtocol { return &p }
+
+	testCases := []struct {
+		name     string
+		spec     v1.HealthCheckSpec
+		expected string
+	}{
+		{
+			name:     "default protocol",
+			spec:     v1.HealthCheckSpec{Path: "/healthz"},
+			expected: route53.HealthCheckTypeHttp,
+		},
+		{
+			name:     "HTTPS",
+			spec:     v1.HealthCheckSpec{Path: "/healthz", Protocol: protocol(v1.HealthCheckProtocolHTTPS)},
+			expected: route53.HealthCheckTypeHttps,
+		},
+		{
+			name:     "TCP",
+			spec:     v1.HealthCheckSpec{Protocol: protocol(v1.HealthCheckProtocolTCP)},
+			expected: route53.HealthCheckTypeTcp,
+		},
+		{
+			name:     "HTTP with search string",
+			spec:     v1.HealthCheckSpec{Path: "/healthz", Protocol: protocol(v1.HealthCheckProtocolHTTP), SearchString: "ok"},
+			expected: route53.HealthCheckTypeHttpStrMatch,
+		},
+		{
+			name:     "HTTPS with search string",
+			spec:     v1.HealthCheckSpec{Path: "/healthz", Protocol: protocol(v1.HealthCheckProtocolHTTPS), SearchString: "ok"},
+			expected: route53.HealthCheckTypeHttpsStrMatch,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if healthCheckType := aws.StringValue(healthCheckType(v1.EndpointHealthCheck{HealthCheckSpec: testCase.spec})); healthCheckType != testCase.expected {
+				t.Fatalf("expected %s, got %s", testCase.expected, healthCheckType)
+			}
+		})
+	}
+}
+
+func TestHealthCheckDiff(t *testing.T) {
+	https := v1.HealthCheckProtocolHTTPS
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "192.168.0.1", Targets: v1.Targets{"192.168.0.1"}}
+	healthCheck := &route53.HealthCheck{
+		Id: aws.String("abc"),
+		HealthCheckConfig: &route53.HealthCheckConfig{
+			FullyQualifiedDomainName: aws.String("echo.example.com"),
+			IPAddress:                aws.String("192.168.0.1"),
+			Port:                     aws.Int64(443),
+			ResourcePath:             aws.String("/healthz"),
+			Type:                     aws.String(route53.HealthCheckTypeHttpsStrMatch),
+			SearchString:             aws.String("ok"),
+			EnableSNI:                aws.Bool(true),
+			FailureThreshold:         aws.Int64(3),
+			RequestInterval:          aws.Int64(30),
+			Regions:                  aws.StringSlice(route53.HealthCheckRegion_Values()),
+		},
+	}
+	spec := v1.EndpointHealthCheck{HealthCheckSpec: v1.HealthCheckSpec{
+		Path:             "/healthz",
+		Port:             aws.Int64(443),
+		Protocol:         &https,
+		FailureThreshold: aws.Int64(3),
+		SearchString:     "ok",
+	}}
+
+	if diff := healthCheckDiff(healthCheck, spec, endpoint); diff != nil {
+		t.Fatalf("expected no diff, got %v", diff)
+	}
+	if healthCheckReplaced(healthCheck, spec) {
+		t.Fatalf("expected the health check not to be replaced")
+	}
+
+	updated := spec
+	updated.SearchString = "ready"
+	updated.Regions = []string{route53.HealthCheckRegionUsEast1, route53.HealthCheckRegionEuWest1, route53.HealthCheckRegionApSoutheast1}
+	diff := healthCheckDiff(healthCheck, updated, endpoint)
+	if diff == nil || aws.StringValue(diff.SearchString) != "ready" || len(diff.Regions) != 3 {
+		t.Fatalf("expected the search string and the regions to be updated, got %v", diff)
+	}
+
+	// The regions are reset when none is set
+	healthCheck.HealthCheckConfig.Regions = aws.StringSlice(updated.Regions)
+	diff = healthCheckDiff(healthCheck, spec, endpoint)
+	if diff == nil || len(diff.ResetElements) != 1 || aws.StringValue(diff.ResetElements[0]) != route53.ResettableElementNameRegions {
+		t.Fatalf("expected the regions to be reset, got %v", diff)
+	}
+
+	// SNI is enabled for the HTTPS health checks
+	healthCheck.HealthCheckConfig.Regions = nil
+	healthCheck.HealthCheckConfig.EnableSNI = nil
+	diff = healthCheckDiff(healthCheck, spec, endpoint)
+	if diff == nil || !aws.BoolValue(diff.EnableSNI) || len(diff.ResetElements) != 0 {
+		t.Fatalf("expected SNI to be enabled only, got %v", diff)
+	}
+
+	// The type and request interval can't be updated
+	interval := int64(10)
+	updated = spec
+	updated.Interval = &interval
+	if !healthCheckReplaced(healthCheck, updated) {
+		t.Fatalf("expected the health check to be replaced for a new request interval")
+	}
+	updated = spec
+	updated.SearchString = ""
+	if !healthCheckReplaced(healthCheck, updated) {
+		t.Fatalf("expected the health check to be replaced for a new type")
+	}
+}
+
+// fakeHealthCheckRoute53 serves the health checks, one page at a time, with their tags
+type fakeHealthCheckRoute53 struct {
+	route53iface.Route53API
+	healthChecks []*route53.HealthCheck
+	tags         map[string][]*route53.Tag
+	pageSize     int
+}
+
+func (f *fakeHealthCheckRoute53) ListHealthChecksPagesWithContext(_ aws.Context, _ *route53.ListHealthChecksInput, fn func(*route53.ListHealthChecksOutput, bool) bool, _ ...request.Option) error {
+	for start := 0; start < len(f.healthChecks); start += f.pageSize {
+		end := start + f.pageSize
+		if end > len(f.healthChecks) {
+			end = len(f.healthChecks)
+		}
+		if !fn(&route53.ListHealthChecksOutput{HealthChecks: f.healthChecks[start:end]}, end == len(f.healthChecks)) {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeHealthCheckRoute53) ListTagsForResourcesWithContext(_ aws.Context, input *route53.ListTagsForResourcesInput, _ ...request.Option) (*route53.ListTagsForResourcesOutput, error) {
+	if len(input.ResourceIds) > maxTaggedResources {
+		return nil, fmt.Errorf("too many resources: %d", len(input.ResourceIds))
+	}
+	output := &route53.ListTagsForResourcesOutput{}
+	for _, id := range input.ResourceIds {
+		output.ResourceTagSets = append(output.ResourceTagSets, &route53.ResourceTagSet{
+			ResourceId:   id,
+			ResourceType: input.ResourceType,
+			Tags:         f.tags[*id],
+		})
+	}
+	return output, nil
+}
+
+func TestManagedHealthChecks(t *testing.T) {
+	fake := &fakeHealthCheckRoute53{tags: map[string][]*route53.Tag{}, pageSize: 5}
+	for i := 0; i < 12; i++ {
+		id := fmt.Sprintf("hc-%d", i)
+		fake.healthChecks = append(fake.healthChecks, &route53.HealthCheck{
+			Id:                aws.String(id),
+			HealthCheckConfig: &route53.HealthCheckConfig{FullyQualifiedDomainName: aws.String(fmt.Sprintf("echo%d.example.com", i))},
+		})
+		// The health checks not created by the controller aren't tagged with idTag
+		tag := idTag
+		if i == 3 {
+			tag = "Name"
+		}
+		fake.tags[id] = []*route53.Tag{{Key: aws.String(tag), Value: aws.String(id)}}
+		// The health checks created by another instance sharing the account are tagged with its owner ID
+		owner := "glbc-1"
+		if i == 5 {
+			owner = "glbc-2"
+		}
+		if i != 7 {
+			fake.tags[id] = append(fake.tags[id], &route53.Tag{Key: aws.String(ownerTag), Value: aws.String(owner)})
+		}
+	}
+	reconciler := newRoute53HealthCheckReconciler(&InstrumentedRoute53{route53: fake}, "glbc-1", logr.Discard())
+
+	managed, err := reconciler.managedHealthChecks(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(managed) != 9 {
+		t.Fatalf("expected 9 managed health checks, got %v", managed)
+	}
+	if _, ok := managed["hc-3"]; ok {
+		t.Errorf("expected the untagged health check not to be managed")
+	}
+	if _, ok := managed["hc-5"]; ok {
+		t.Errorf("expected the health check of another owner not to be managed")
+	}
+	if _, ok := managed["hc-7"]; ok {
+		t.Errorf("expected the health check without owner not to be managed")
+	}
+	if managed["hc-11"] != "echo11.example.com" {
+		t.Errorf("expected the health check domain name to be returned, got %q", managed["hc-11"])
+	}
+
+	// The instances without owner ID can't tell their health checks apart
+	reconciler = newRoute53HealthCheckReconciler(&InstrumentedRoute53{route53: fake}, "", logr.Discard())
+	managed, err = reconciler.managedHealthChecks(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(managed) != 0 {
+		t.Errorf("expected no managed health check without owner ID, got %v", managed)
+	}
+}
+
+func (f *fakeHealthCheckRoute53) CreateHealthCheck(input *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error) {
+	id := fmt.Sprintf("hc-%d", len(f.healthChecks))
+	healthCheck := &route53.HealthCheck{Id: aws.String(id), CallerReference: input.CallerReference, HealthCheckConfig: input.HealthCheckConfig}
+	f.healthChecks = append(f.healthChecks, healthCheck)
+	return &route53.CreateHealthCheckOutput{HealthCheck: healthCheck}, nil
+}
+
+func (f *fakeHealthCheckRoute53) ChangeTagsForResourceWithContext(_ aws.Context, input *route53.ChangeTagsForResourceInput, _ ...request.Option) (*route53.ChangeTagsForResourceOutput, error) {
+	f.tags[aws.StringValue(input.ResourceId)] = append(f.tags[aws.StringValue(input.ResourceId)], input.AddTags...)
+	return &route53.ChangeTagsForResourceOutput{}, nil
+}
+
+func TestCreateHealthCheckOwnerTag(t *testing.T) {
+	fake := &fakeHealthCheckRoute53{tags: map[string][]*route53.Tag{}, pageSize: 5}
+	reconciler := newRoute53HealthCheckReconciler(&InstrumentedRoute53{route53: fake}, "glbc-1", logr.Discard())
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1", Targets: v1.Targets{"192.168.0.1"}}
+	spec := v1.EndpointHealthCheck{Id: "abc", Name: "echo.example.com-c1", HealthCheckSpec: v1.HealthCheckSpec{Path: "/healthz", Port: aws.Int64(80)}}
+
+	if _, err := reconciler.createHealthCheck(context.TODO(), spec, endpoint, aws.String("abc")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The health checks created by the instance are managed by it
+	managed, err := reconciler.managedHealthChecks(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := managed["hc-0"]; !ok {
+		t.Fatalf("expected the health check to be tagged with the owner ID, got tags %v", fake.tags["hc-0"])
+	}
+}
+
+func TestHealthCheckGeoEndpoint(t *testing.T) {
+	fake := &fakeHealthCheckRoute53{tags: map[string][]*route53.Tag{}, pageSize: 5}
+	reconciler := newRoute53HealthCheckReconciler(&InstrumentedRoute53{route53: fake}, "glbc-1", logr.Discard())
+	https := v1.HealthCheckProtocolHTTPS
+	spec := v1.EndpointHealthCheck{Id: "abc", Name: "echo.na.example.com-c1", HealthCheckSpec: v1.HealthCheckSpec{Path: "/healthz", Protocol: &https}}
+
+	// The A record of the continent host of the cluster, set by geo aware DNS
+	endpoint := &v1.Endpoint{DNSName: "echo.na.example.com", RecordType: "A", SetIdentifier: "192.168.0.1", Targets: v1.Targets{"192.168.0.1"}}
+	endpoint.SetProviderSpecific(ProviderSpecificWeight, "120")
+	endpoint.SetProviderSpecific(ProviderSpecificHealthCheckHost, "echo.example.com")
+
+	healthCheck, err := reconciler.createHealthCheck(context.TODO(), spec, endpoint, aws.String("abc"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := healthCheck.HealthCheckConfig
+	if aws.StringValue(config.FullyQualifiedDomainName) != "echo.example.com" || !aws.BoolValue(config.EnableSNI) {
+		t.Fatalf("expected the managed host to be health checked with SNI, got %v", config)
+	}
+	if diff := healthCheckDiff(healthCheck, spec, endpoint); diff != nil {
+		t.Fatalf("expected no diff, got %v", diff)
+	}
+
+	// The health checks created with the continent host are updated
+	config.FullyQualifiedDomainName = aws.String("echo.na.example.com")
+	diff := healthCheckDiff(healthCheck, spec, endpoint)
+	if diff == nil || aws.StringValue(diff.FullyQualifiedDomainName) != "echo.example.com" {
+		t.Fatalf("expected the host to be updated to the managed host, got %v", diff)
+	}
+}
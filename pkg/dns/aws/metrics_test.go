@@ -24,11 +24,16 @@ func TestRegister(t *testing.T) {
 	g := gomega.NewWithT(t)
 	g.Expect(operationLabelValues).To(gomega.ConsistOf(
 		"ListHostedZones",
+		"ListResourceRecordSetsPages",
 		"ChangeResourceRecordSets",
+		"GetChange",
 		"CreateHealthCheck",
 		"GetHealthCheckWithContext",
 		"UpdateHealthCheckWithContext",
 		"DeleteHealthCheckWithContext",
 		"ChangeTagsForResourceWithContext",
+		"GetHealthCheckStatusWithContext",
+		"ListHealthChecksPagesWithContext",
+		"ListTagsForResourcesWithContext",
 	))
 }
@@ -0,0 +1,177 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/kcp-dev/logicalcluster/v2"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+const (
+	// OwnerIDEnvVar is the ID of the GLBC instance recorded in the ownership records. The ownership registry is
+	// disabled when it is not set.
+	OwnerIDEnvVar = "GLBC_DNS_OWNER_ID"
+
+	// OwnershipConflictConditionType is reported when the ownership registry is enabled. It is true when some
+	// names of the record are owned by another GLBC instance, or hold records not managed by GLBC.
+	OwnershipConflictConditionType = "OwnershipConflict"
+
+	ownershipHeritage = "kcp-glbc"
+	ownershipPrefix   = "_glbc-owner."
+	// ownershipWildcardPrefix replaces the leading wildcard label of a name, as the wildcard can only be the
+	// leftmost label
+	ownershipWildcardPrefix = "_glbc-owner-wildcard."
+	ownershipTTL            = 300
+)
+
+// OwnershipConflictError is returned when a record can't be changed because some of its names are not owned by
+// the GLBC instance
+type OwnershipConflictError struct {
+	Conflicts []string
+}
+
+func (e *OwnershipConflictError) Error() string {
+	return fmt.Sprintf("ownership conflict: %s", strings.Join(e.Conflicts, ", "))
+}
+
+// ZoneConditions returns the condition reporting the conflicts
+func (e *OwnershipConflictError) ZoneConditions() []v1.DNSZoneCondition {
+	return []v1.DNSZoneCondition{{
+		Type:    OwnershipConflictConditionType,
+		Status:  "True",
+		Reason:  "NotOwned",
+		Message: e.Error(),
+	}}
+}
+
+// ownershipChanges checks that the names of the changes are owned by the GLBC instance, and returns the changes of
+// their ownership records. A name is owned when its ownership TXT record holds the owner ID of the instance, or
+// when it has no ownership record yet but the record has been published to it, so that the names published before
+// the registry was enabled are adopted.
+func (p *Provider) ownershipChanges(record *v1.DNSRecord, zoneID string, changes []*route53.Change, published []*v1.Endpoint) ([]*route53.Change, error) {
+	publishedNames := map[string]struct{}{}
+	for _, endpoint := range published {
+		publishedNames[normalizeRecordSetName(endpoint.DNSName)] = struct{}{}
+	}
+	// The names are kept as long as a change upserts one of their record sets
+	names := map[string]bool{}
+	for _, change := range changes {
+		name := normalizeRecordSetName(aws.StringValue(change.ResourceRecordSet.Name))
+		names[name] = names[name] || aws.StringValue(change.Action) != string(deleteAction)
+	}
+
+	var conflicts []string
+	var ownershipChanges []*route53.Change
+	for _, name := range sortedNames(names) {
+		ownershipRecordSet, err := p.ownershipRecordSet(zoneID, name)
+		if err != nil {
+			return nil, err
+		}
+		if ownershipRecordSet != nil {
+			if owner, ok := parseOwner(ownershipRecordSet); !ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s is owned by an unknown owner", name))
+				continue
+			} else if owner != p.config.OwnerID {
+				conflicts = append(conflicts, fmt.Sprintf("%s is owned by %s", name, owner))
+				continue
+			}
+		} else if _, ok := publishedNames[name]; !ok {
+			recordSets, err := p.recordSets(zoneID, name)
+			if err != nil {
+				return nil, err
+			}
+			if len(recordSets) > 0 {
+				conflicts = append(conflicts, fmt.Sprintf("%s has records not managed by GLBC", name))
+				continue
+			}
+		}
+
+		if names[name] {
+			ownershipChanges = append(ownershipChanges, &route53.Change{
+				Action:            aws.String(string(upsertAction)),
+				ResourceRecordSet: p.ownershipRecordSetForName(record, name),
+			})
+		} else if ownershipRecordSet != nil {
+			// The deletion must match the existing record set
+			ownershipChanges = append(ownershipChanges, &route53.Change{
+				Action:            aws.String(string(deleteAction)),
+				ResourceRecordSet: ownershipRecordSet,
+			})
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, &OwnershipConflictError{Conflicts: conflicts}
+	}
+	return ownershipChanges, nil
+}
+
+// ownershipRecordSet returns the ownership TXT record set of the name, or nil if there is none
+func (p *Provider) ownershipRecordSet(zoneID, name string) (*route53.ResourceRecordSet, error) {
+	recordSets, err := p.recordSets(zoneID, ownershipName(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, recordSet := range recordSets {
+		if aws.StringValue(recordSet.Type) == route53.RRTypeTxt {
+			return recordSet, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *Provider) ownershipRecordSetForName(record *v1.DNSRecord, name string) *route53.ResourceRecordSet {
+	return &route53.ResourceRecordSet{
+		Name:            aws.String(ownershipName(name)),
+		Type:            aws.String(route53.RRTypeTxt),
+		TTL:             aws.Int64(ownershipTTL),
+		ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(quoteTXT(ownershipValue(p.config.OwnerID, record)))}},
+	}
+}
+
+// ownershipName returns the name of the ownership record of a name. It differs from the name, so that the
+// ownership record doesn't conflict with a CNAME record.
+func ownershipName(name string) string {
+	name = normalizeRecordSetName(name)
+	if strings.HasPrefix(name, "*.") {
+		return ownershipWildcardPrefix + strings.TrimPrefix(name, "*.")
+	}
+	return ownershipPrefix + name
+}
+
+// ownershipValue returns the value of the ownership record, e.g.
+// heritage=kcp-glbc,kcp-glbc/owner=glbc-1,kcp-glbc/resource=dnsrecord/root:kuadrant/default/echo
+func ownershipValue(owner string, record *v1.DNSRecord) string {
+	return fmt.Sprintf("heritage=%s,%s/owner=%s,%s/resource=dnsrecord/%s/%s/%s",
+		ownershipHeritage, ownershipHeritage, owner, ownershipHeritage, logicalcluster.From(record), record.Namespace, record.Name)
+}
+
+// parseOwner returns the owner of an ownership record set, or false if it isn't one
+func parseOwner(recordSet *route53.ResourceRecordSet) (string, bool) {
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		value := strings.ReplaceAll(strings.Trim(aws.StringValue(resourceRecord.Value), `"`), `\"`, `"`)
+		labels := map[string]string{}
+		for _, label := range strings.Split(value, ",") {
+			if kv := strings.SplitN(label, "=", 2); len(kv) == 2 {
+				labels[kv[0]] = kv[1]
+			}
+		}
+		if labels["heritage"] == ownershipHeritage {
+			return labels[ownershipHeritage+"/owner"], true
+		}
+	}
+	return "", false
+}
+
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
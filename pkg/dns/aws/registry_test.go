@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/go-logr/logr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// fakeRoute53 serves the record sets of a single hosted zone, ordered by name
+type fakeRoute53 struct {
+	route53iface.Route53API
+	recordSets []*route53.ResourceRecordSet
+	batches    []*route53.ChangeBatch
+}
+
+func (f *fakeRoute53) ListResourceRecordSetsPages(input *route53.ListResourceRecordSetsInput, fn func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+	sort.Slice(f.recordSets, func(i, j int) bool {
+		return aws.StringValue(f.recordSets[i].Name) < aws.StringValue(f.recordSets[j].Name)
+	})
+	output := &route53.ListResourceRecordSetsOutput{}
+	for _, recordSet := range f.recordSets {
+		if normalizeRecordSetName(aws.StringValue(recordSet.Name)) >= aws.StringValue(input.StartRecordName) {
+			output.ResourceRecordSets = append(output.ResourceRecordSets, recordSet)
+		}
+	}
+	fn(output, true)
+	return nil
+}
+
+func (f *fakeRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	f.batches = append(f.batches, input.ChangeBatch)
+	return &route53.ChangeResourceRecordSetsOutput{ChangeInfo: &route53.ChangeInfo{Id: aws.String("C1")}}, nil
+}
+
+func ownershipRecordSetFor(name, owner string) *route53.ResourceRecordSet {
+	return &route53.ResourceRecordSet{
+		Name:            aws.String(ownershipName(name) + "."),
+		Type:            aws.String("TXT"),
+		TTL:             aws.Int64(ownershipTTL),
+		ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(quoteTXT("heritage=kcp-glbc,kcp-glbc/owner=" + owner))}},
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	zone := v1.DNSZone{ID: "Z1"}
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 60}
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec:       v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{endpoint}},
+	}
+	published := record.DeepCopy()
+	published.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: []*v1.Endpoint{endpoint}}}
+	unmanaged := &route53.ResourceRecordSet{Name: aws.String("echo.example.com."), Type: aws.String("A")}
+
+	cases := []struct {
+		Name               string
+		Record             *v1.DNSRecord
+		Action             action
+		RecordSets         []*route53.ResourceRecordSet
+		ExpectConflict     bool
+		ExpectedOwnerships []string
+	}{
+		{Name: "new name", Record: record, Action: upsertAction, ExpectedOwnerships: []string{"UPSERT"}},
+		{Name: "owned name", Record: record, Action: upsertAction, RecordSets: []*route53.ResourceRecordSet{ownershipRecordSetFor("echo.example.com", "glbc-1")}, ExpectedOwnerships: []string{"UPSERT"}},
+		{Name: "name owned by another instance", Record: record, Action: upsertAction, RecordSets: []*route53.ResourceRecordSet{ownershipRecordSetFor("echo.example.com", "glbc-2")}, ExpectConflict: true},
+		{Name: "unmanaged records", Record: record, Action: upsertAction, RecordSets: []*route53.ResourceRecordSet{unmanaged}, ExpectConflict: true},
+		{Name: "published records are adopted", Record: published, Action: upsertAction, RecordSets: []*route53.ResourceRecordSet{unmanaged}, ExpectedOwnerships: []string{"UPSERT"}},
+		{Name: "deleted name", Record: published, Action: deleteAction, RecordSets: []*route53.ResourceRecordSet{unmanaged, ownershipRecordSetFor("echo.example.com", "glbc-1")}, ExpectedOwnerships: []string{"DELETE"}},
+		{Name: "deleted name without ownership record", Record: published, Action: deleteAction, RecordSets: []*route53.ResourceRecordSet{unmanaged}},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			fake := &fakeRoute53{recordSets: testCase.RecordSets}
+			p := &Provider{
+				route53: &InstrumentedRoute53{fake},
+				zoneIDs: newZoneIDs(nil),
+				config:  Config{OwnerID: "glbc-1"},
+				logger:  logr.Discard(),
+			}
+			p.changeBatcher = newChangeBatcher(p.route53, 0, p.logger)
+			p.changeIDs = newChangeIDs()
+
+			err := p.change(testCase.Record, zone, testCase.Action)
+			if testCase.ExpectConflict {
+				var conflictErr *OwnershipConflictError
+				if !errors.As(err, &conflictErr) {
+					t.Fatalf("expected an ownership conflict, got %v", err)
+				}
+				if len(fake.batches) != 0 {
+					t.Fatalf("expected no change to be submitted, got %v", fake.batches)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var ownerships []string
+			for _, change := range fake.batches[0].Changes {
+				if aws.StringValue(change.ResourceRecordSet.Name) == ownershipName("echo.example.com") || aws.StringValue(change.ResourceRecordSet.Name) == ownershipName("echo.example.com")+"." {
+					ownerships = append(ownerships, aws.StringValue(change.Action))
+				}
+			}
+			if !reflect.DeepEqual(ownerships, testCase.ExpectedOwnerships) {
+				t.Fatalf("expected ownership changes %v, got %v", testCase.ExpectedOwnerships, ownerships)
+			}
+		})
+	}
+}
+
+func TestOwnershipName(t *testing.T) {
+	if name := ownershipName("Echo.example.com."); name != "_glbc-owner.echo.example.com" {
+		t.Errorf("unexpected ownership name %s", name)
+	}
+	if name := ownershipName(`\052.example.com.`); name != "_glbc-owner-wildcard.example.com" {
+		t.Errorf("unexpected ownership name %s", name)
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	record := &v1.DNSRecord{ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"}}
+	recordSet := &route53.ResourceRecordSet{ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(quoteTXT(ownershipValue("glbc-1", record)))}}}
+	if owner, ok := parseOwner(recordSet); !ok || owner != "glbc-1" {
+		t.Errorf("expected owner glbc-1, got %s", owner)
+	}
+	recordSet = &route53.ResourceRecordSet{ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(`"heritage=external-dns,external-dns/owner=default"`)}}}
+	if _, ok := parseOwner(recordSet); ok {
+		t.Errorf("expected an external-dns record not to be parsed")
+	}
+}
@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// invalidChangeRecordSetPatterns match the record sets named by the messages of a Route53 InvalidChangeBatch error,
+// capturing their name, and their type and set identifier when present, e.g.
+//   - Tried to create resource record set [name='echo.example.com.', type='A', set-identifier='c1'] but it already exists
+//   - RRSet of type CNAME with DNS name echo.example.com. is not permitted as it conflicts with other records with the same DNS name in zone example.com.
+//   - RRSet with DNS name echo.example.com. is not permitted in zone example.org.
+//   - Invalid request: Expected exactly one of [AliasTarget, all of [TTL, and ResourceRecords]], but found none in Change with [Action=UPSERT, Name=echo.example.com., Type=A, SetIdentifier=c1]
+var invalidChangeRecordSetPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`name='(?P<name>[^']+)'(?:, type='(?P<type>[^']+)')?(?:, set-identifier='(?P<setIdentifier>[^']+)')?`),
+	regexp.MustCompile(`RRSet of type (?P<type>\S+) with DNS name (?P<name>\S+)`),
+	regexp.MustCompile(`RRSet with DNS name (?P<name>\S+)`),
+	regexp.MustCompile(`Name=(?P<name>[^,\]]+), Type=(?P<type>[^,\]]+)(?:, SetIdentifier=(?P<setIdentifier>[^,\]]+))?`),
+}
+
+// invalidChangeBatchError returns the endpoints rejected by the Route53 InvalidChangeBatch error, or false if err
+// is not an InvalidChangeBatch error, or any of its messages can't be mapped back to the endpoints, in which case
+// err is returned as is.
+func invalidChangeBatchError(endpoints []*v1.Endpoint, err error) (*RejectedEndpointsError, bool) {
+	var batchErr awserr.BatchedErrors
+	if !errors.As(err, &batchErr) || batchErr.Code() != route53.ErrCodeInvalidChangeBatch {
+		return nil, false
+	}
+	messages := batchErr.OrigErrs()
+	if len(messages) == 0 {
+		return nil, false
+	}
+
+	rejected := &RejectedEndpointsError{}
+	for _, message := range messages {
+		text := message.Error()
+		if awsErr, ok := message.(awserr.Error); ok {
+			text = awsErr.Message()
+		}
+		matched := rejectedEndpoints(endpoints, text)
+		if len(matched) == 0 {
+			return nil, false
+		}
+		for _, endpoint := range matched {
+			rejected.Endpoints = append(rejected.Endpoints, RejectedEndpoint{Endpoint: endpoint, Err: errors.New(text)})
+		}
+	}
+	return rejected, true
+}
+
+// rejectedEndpoints returns the endpoints of the record set named by the InvalidChangeBatch message
+func rejectedEndpoints(endpoints []*v1.Endpoint, message string) []*v1.Endpoint {
+	for _, pattern := range invalidChangeRecordSetPatterns {
+		match := pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		key := recordSetKey{}
+		for i, group := range pattern.SubexpNames() {
+			switch group {
+			case "name":
+				key.name = normalizeRecordSetName(match[i])
+			case "type":
+				key.recordType = match[i]
+			case "setIdentifier":
+				// Route53 reports the absence of set identifier as null
+				if match[i] != "null" {
+					key.setIdentifier = match[i]
+				}
+			}
+		}
+		var matched []*v1.Endpoint
+		for _, endpoint := range endpoints {
+			endpointKey := recordSetKeyForEndpoint(endpoint)
+			if endpointKey.name != key.name {
+				continue
+			}
+			if key.recordType != "" && !strings.EqualFold(endpointKey.recordType, key.recordType) {
+				continue
+			}
+			if key.setIdentifier != "" && endpointKey.setIdentifier != key.setIdentifier {
+				continue
+			}
+			matched = append(matched, endpoint)
+		}
+		return matched
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/go-logr/logr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// invalidChangeBatch returns the error Route53 returns for an invalid change batch
+func invalidChangeBatch(messages ...string) error {
+	var errs []error
+	for _, message := range messages {
+		errs = append(errs, awserr.New(route53.ErrCodeInvalidChangeBatch, message, nil))
+	}
+	return awserr.NewRequestFailure(awserr.NewBatchError(route53.ErrCodeInvalidChangeBatch, "ChangeBatch errors occurred", errs), 400, "R1")
+}
+
+// rejectingRoute53 rejects the change batches with err
+type rejectingRoute53 struct {
+	fakeRoute53
+	err error
+}
+
+func (f *rejectingRoute53) ChangeResourceRecordSets(_ *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	return nil, f.err
+}
+
+func TestInvalidChangeBatchError(t *testing.T) {
+	c1 := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1", Targets: v1.Targets{"192.168.0.1"}}
+	c2 := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c2", Targets: v1.Targets{"192.168.0.2"}}
+	wildcard := &v1.Endpoint{DNSName: "*.example.com", RecordType: "CNAME", Targets: v1.Targets{"echo.example.com"}}
+	endpoints := []*v1.Endpoint{c1, c2, wildcard}
+
+	cases := []struct {
+		name     string
+		err      error
+		expected []*v1.Endpoint
+	}{
+		{
+			name:     "existing record set",
+			err:      invalidChangeBatch("Tried to create resource record set [name='echo.example.com.', type='A', set-identifier='c2'] but it already exists"),
+			expected: []*v1.Endpoint{c2},
+		},
+		{
+			name:     "conflicting record set",
+			err:      invalidChangeBatch(`RRSet of type CNAME with DNS name \052.example.com. is not permitted as it conflicts with other records with the same DNS name in zone example.com.`),
+			expected: []*v1.Endpoint{wildcard},
+		},
+		{
+			name:     "record set outside of the zone",
+			err:      invalidChangeBatch("RRSet with DNS name echo.example.com. is not permitted in zone example.org."),
+			expected: []*v1.Endpoint{c1, c2},
+		},
+		{
+			name:     "invalid change",
+			err:      invalidChangeBatch("Invalid request: Expected exactly one of [AliasTarget, all of [TTL, and ResourceRecords]], but found none in Change with [Action=UPSERT, Name=echo.example.com., Type=A, SetIdentifier=c1]"),
+			expected: []*v1.Endpoint{c1},
+		},
+		{
+			name:     "several messages",
+			err:      invalidChangeBatch("Tried to create resource record set [name='echo.example.com.', type='A', set-identifier='c1'] but it already exists", "Tried to create resource record set [name='echo.example.com.', type='A', set-identifier='c2'] but it already exists"),
+			expected: []*v1.Endpoint{c1, c2},
+		},
+		{
+			name: "message not naming an endpoint",
+			err:  invalidChangeBatch("Tried to create resource record set [name='other.example.com.', type='A'] but it already exists"),
+		},
+		{
+			name: "other error",
+			err:  awserr.NewRequestFailure(awserr.New(route53.ErrCodeThrottlingException, "Rate exceeded", nil), 400, "R1"),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rejected, ok := invalidChangeBatchError(endpoints, tc.err)
+			if ok != (tc.expected != nil) {
+				t.Fatalf("expected the error to be mapped to endpoints %v, got %v", tc.expected, rejected)
+			}
+			if !ok {
+				return
+			}
+			if len(rejected.Endpoints) != len(tc.expected) {
+				t.Fatalf("expected endpoints %v to be rejected, got %v", tc.expected, rejected)
+			}
+			for i, endpoint := range tc.expected {
+				if rejected.Endpoints[i].Endpoint != endpoint || rejected.Endpoints[i].Err == nil {
+					t.Errorf("expected endpoint %v to be rejected, got %v", endpoint, rejected.Endpoints[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEnsureInvalidChangeBatch(t *testing.T) {
+	valid := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 60}
+	existing := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c2", Targets: v1.Targets{"192.168.0.2"}, RecordTTL: 60}
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec:       v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{valid, existing}},
+	}
+
+	fake := &rejectingRoute53{err: invalidChangeBatch("Tried to create resource record set [name='echo.example.com.', type='A', set-identifier='c2'] but it already exists")}
+	p := &Provider{
+		route53: &InstrumentedRoute53{fake},
+		zoneIDs: newZoneIDs(nil),
+		logger:  logr.Discard(),
+	}
+	p.changeBatcher = newChangeBatcher(p.route53, 0, p.logger)
+	p.changeIDs = newChangeIDs()
+
+	err := p.Ensure(record, v1.DNSZone{ID: "Z1"})
+	var rejectedErr *RejectedEndpointsError
+	if !errors.As(err, &rejectedErr) {
+		t.Fatalf("expected rejected endpoints, got %v", err)
+	}
+	rejected := rejectedErr.RejectedEndpoints()
+	if _, ok := rejected[existing]; !ok || len(rejected) != 1 {
+		t.Errorf("expected endpoint %v to be rejected, got %v", existing, rejected)
+	}
+}
@@ -0,0 +1,156 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// ZoneLookupConditionType is reported for the zones referenced by tags. It is true when the hosted zone is found,
+// and false when the lookup fails.
+const ZoneLookupConditionType = "ZoneLookup"
+
+const hostedZoneResourceType = "route53:hostedzone"
+
+// ZoneLookupError is returned when the hosted zone of a zone referenced by tags can't be found
+type ZoneLookupError struct {
+	Zone v1.DNSZone
+	Err  error
+}
+
+func (e *ZoneLookupError) Error() string {
+	return fmt.Sprintf("failed to find hosted zone with tags %s: %v", tagsKey(e.Zone.Tags), e.Err)
+}
+
+func (e *ZoneLookupError) Unwrap() error {
+	return e.Err
+}
+
+// ZoneConditions returns the condition reporting the failed lookup
+func (e *ZoneLookupError) ZoneConditions() []v1.DNSZoneCondition {
+	return []v1.DNSZoneCondition{{
+		Type:    ZoneLookupConditionType,
+		Status:  "False",
+		Reason:  "LookupFailed",
+		Message: e.Error(),
+	}}
+}
+
+// zoneIDs resolves the hosted zone IDs of the zones, looking up the zones referenced by tags with the Resource
+// Groups Tagging API. The IDs of the zones found are cached.
+type zoneIDs struct {
+	tagging resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+
+	lock  sync.Mutex
+	cache map[string]string
+}
+
+func newZoneIDs(tagging resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI) *zoneIDs {
+	return &zoneIDs{
+		tagging: tagging,
+		cache:   map[string]string{},
+	}
+}
+
+// get returns the hosted zone ID of zone, its ID if it is set, or a *ZoneLookupError if it can't be found
+func (z *zoneIDs) get(zone v1.DNSZone) (string, error) {
+	if zone.ID != "" {
+		return zone.ID, nil
+	}
+	if len(zone.Tags) == 0 {
+		return "", &ZoneLookupError{Zone: zone, Err: fmt.Errorf("the zone has neither an ID nor tags")}
+	}
+
+	key := tagsKey(zone.Tags)
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if id, ok := z.cache[key]; ok {
+		return id, nil
+	}
+
+	id, err := z.lookup(zone.Tags)
+	if err != nil {
+		return "", &ZoneLookupError{Zone: zone, Err: err}
+	}
+	z.cache[key] = id
+	return id, nil
+}
+
+func (z *zoneIDs) lookup(tags map[string]string) (string, error) {
+	input := &resourcegroupstaggingapi.GetResourcesInput{
+		ResourceTypeFilters: []*string{aws.String(hostedZoneResourceType)},
+	}
+	for _, key := range sortedKeys(tags) {
+		input.TagFilters = append(input.TagFilters, &resourcegroupstaggingapi.TagFilter{
+			Key:    aws.String(key),
+			Values: []*string{aws.String(tags[key])},
+		})
+	}
+
+	var ids []string
+	var err error
+	observe("GetResources", func() error {
+		err = z.tagging.GetResourcesPages(input, func(output *resourcegroupstaggingapi.GetResourcesOutput, _ bool) bool {
+			for _, mapping := range output.ResourceTagMappingList {
+				id, parseErr := hostedZoneIDFromARN(aws.StringValue(mapping.ResourceARN))
+				if parseErr != nil {
+					err = parseErr
+					return false
+				}
+				ids = append(ids, id)
+			}
+			return true
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no hosted zone found")
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("found %d hosted zones %s, expected a single one", len(ids), strings.Join(ids, ", "))
+	}
+}
+
+// hostedZoneIDFromARN returns the ID of a hosted zone from its ARN, e.g. arn:aws:route53:::hostedzone/Z08652651232L9P84LRSB
+func hostedZoneIDFromARN(value string) (string, error) {
+	parsed, err := arn.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse hosted zone ARN %s: %v", value, err)
+	}
+	id := strings.TrimPrefix(parsed.Resource, "hostedzone/")
+	if id == parsed.Resource || id == "" {
+		return "", fmt.Errorf("unexpected hosted zone ARN %s", value)
+	}
+	return id, nil
+}
+
+func tagsKey(tags map[string]string) string {
+	var pairs []string
+	for _, key := range sortedKeys(tags) {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return strings.Join(pairs, ";")
+}
+
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
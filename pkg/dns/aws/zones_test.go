@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+type fakeTagging struct {
+	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	arns  map[string][]string
+	err   error
+	calls int
+}
+
+func (f *fakeTagging) GetResourcesPages(input *resourcegroupstaggingapi.GetResourcesInput, fn func(*resourcegroupstaggingapi.GetResourcesOutput, bool) bool) error {
+	f.calls++
+	if f.err != nil {
+		return f.err
+	}
+	if len(input.ResourceTypeFilters) != 1 || aws.StringValue(input.ResourceTypeFilters[0]) != hostedZoneResourceType {
+		return errors.New("expected hosted zones to be filtered")
+	}
+	tags := map[string]string{}
+	for _, filter := range input.TagFilters {
+		tags[aws.StringValue(filter.Key)] = aws.StringValue(filter.Values[0])
+	}
+	output := &resourcegroupstaggingapi.GetResourcesOutput{}
+	for _, value := range f.arns[tagsKey(tags)] {
+		output.ResourceTagMappingList = append(output.ResourceTagMappingList, &resourcegroupstaggingapi.ResourceTagMapping{ResourceARN: aws.String(value)})
+	}
+	fn(output, true)
+	return nil
+}
+
+func TestZoneIDs(t *testing.T) {
+	tagging := &fakeTagging{arns: map[string][]string{
+		"env=prod;team=dns": {"arn:aws:route53:::hostedzone/Z08652651232L9P84LRSB"},
+		"env=dev":           {"arn:aws:route53:::hostedzone/Z1", "arn:aws:route53:::hostedzone/Z2"},
+	}}
+	zoneIDs := newZoneIDs(tagging)
+
+	if id, err := zoneIDs.get(v1.DNSZone{ID: "Z0"}); err != nil || id != "Z0" || tagging.calls != 0 {
+		t.Fatalf("expected the zone ID to be returned without lookup, got %s, %v", id, err)
+	}
+
+	zone := v1.DNSZone{Tags: map[string]string{"team": "dns", "env": "prod"}}
+	for i := 0; i < 2; i++ {
+		id, err := zoneIDs.get(zone)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if id != "Z08652651232L9P84LRSB" {
+			t.Fatalf("expected hosted zone Z08652651232L9P84LRSB, got %s", id)
+		}
+	}
+	if tagging.calls != 1 {
+		t.Fatalf("expected the hosted zone ID to be cached, got %d lookups", tagging.calls)
+	}
+
+	for _, tags := range []map[string]string{{"env": "dev"}, {"env": "test"}} {
+		_, err := zoneIDs.get(v1.DNSZone{Tags: tags})
+		var lookupErr *ZoneLookupError
+		if !errors.As(err, &lookupErr) {
+			t.Fatalf("expected a zone lookup error for tags %v, got %v", tags, err)
+		}
+		conditions := lookupErr.ZoneConditions()
+		if len(conditions) != 1 || conditions[0].Type != ZoneLookupConditionType || conditions[0].Status != "False" {
+			t.Fatalf("expected a false %s condition, got %v", ZoneLookupConditionType, conditions)
+		}
+	}
+
+	tagging.err = errors.New("AccessDeniedException")
+	if _, err := zoneIDs.get(v1.DNSZone{Tags: map[string]string{"env": "staging"}}); err == nil {
+		t.Fatalf("expected the lookup error to be returned")
+	}
+}
+
+func TestHostedZoneIDFromARN(t *testing.T) {
+	if id, err := hostedZoneIDFromARN("arn:aws:route53:::hostedzone/Z08652651232L9P84LRSB"); err != nil || id != "Z08652651232L9P84LRSB" {
+		t.Fatalf("unexpected hosted zone ID %s, %v", id, err)
+	}
+	for _, value := range []string{"Z08652651232L9P84LRSB", "arn:aws:route53:::healthcheck/abc"} {
+		if _, err := hostedZoneIDFromARN(value); err == nil {
+			t.Errorf("expected an error for ARN %s", value)
+		}
+	}
+}
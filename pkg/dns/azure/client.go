@@ -0,0 +1,208 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// defaultEndpoint is the Azure Resource Manager endpoint of the public cloud.
+	defaultEndpoint = "https://management.azure.com/"
+
+	dnsAPIVersion            = "2018-05-01"
+	trafficManagerAPIVersion = "2018-08-01"
+)
+
+// recordSet is the ARM representation of an Azure DNS record set.
+// See https://learn.microsoft.com/en-us/rest/api/dns/record-sets
+type recordSet struct {
+	Name       string              `json:"name,omitempty"`
+	Type       string              `json:"type,omitempty"`
+	Properties recordSetProperties `json:"properties"`
+}
+
+type recordSetProperties struct {
+	TTL         int64             `json:"TTL"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ARecords    []aRecord         `json:"ARecords,omitempty"`
+	AAAARecords []aaaaRecord      `json:"AAAARecords,omitempty"`
+	CNAMERecord *cnameRecord      `json:"CNAMERecord,omitempty"`
+}
+
+type aRecord struct {
+	IPv4Address string `json:"ipv4Address"`
+}
+
+type aaaaRecord struct {
+	IPv6Address string `json:"ipv6Address"`
+}
+
+type cnameRecord struct {
+	CNAME string `json:"cname"`
+}
+
+// trafficManagerProfile is the ARM representation of a Traffic Manager profile.
+// See https://learn.microsoft.com/en-us/rest/api/trafficmanager/profiles
+type trafficManagerProfile struct {
+	Name       string                          `json:"name,omitempty"`
+	Location   string                          `json:"location"`
+	Tags       map[string]string               `json:"tags,omitempty"`
+	Properties trafficManagerProfileProperties `json:"properties"`
+}
+
+type trafficManagerProfileProperties struct {
+	ProfileStatus        string                   `json:"profileStatus"`
+	TrafficRoutingMethod string                   `json:"trafficRoutingMethod"`
+	DNSConfig            trafficManagerDNSConfig  `json:"dnsConfig"`
+	MonitorConfig        trafficManagerMonitor    `json:"monitorConfig"`
+	Endpoints            []trafficManagerEndpoint `json:"endpoints"`
+}
+
+type trafficManagerDNSConfig struct {
+	RelativeName string `json:"relativeName"`
+	FQDN         string `json:"fqdn,omitempty"`
+	TTL          int64  `json:"ttl"`
+}
+
+type trafficManagerMonitor struct {
+	Protocol      string                       `json:"protocol"`
+	Port          int64                        `json:"port"`
+	Path          string                       `json:"path,omitempty"`
+	CustomHeaders []trafficManagerCustomHeader `json:"customHeaders,omitempty"`
+}
+
+type trafficManagerCustomHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type trafficManagerEndpoint struct {
+	Name       string                           `json:"name"`
+	Type       string                           `json:"type"`
+	Properties trafficManagerEndpointProperties `json:"properties"`
+}
+
+type trafficManagerEndpointProperties struct {
+	Target         string `json:"target"`
+	Weight         int64  `json:"weight"`
+	EndpointStatus string `json:"endpointStatus"`
+	AlwaysServe    string `json:"alwaysServe,omitempty"`
+}
+
+type zonesListResponse struct {
+	Value []struct {
+		Name string `json:"name"`
+	} `json:"value"`
+}
+
+// cloudError is the error body returned by Azure Resource Manager.
+type cloudError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// armClient is a minimal Azure Resource Manager client for the Azure DNS and
+// Traffic Manager resources of a single resource group.
+type armClient struct {
+	httpClient     *http.Client
+	endpoint       string
+	subscriptionID string
+	resourceGroup  string
+}
+
+func (c *armClient) resourceURL(apiVersion string, elem ...string) string {
+	parts := []string{"subscriptions", url.PathEscape(c.subscriptionID), "resourceGroups", url.PathEscape(c.resourceGroup), "providers", "Microsoft.Network"}
+	for _, e := range elem {
+		parts = append(parts, url.PathEscape(e))
+	}
+	return fmt.Sprintf("%s/%s?api-version=%s", strings.TrimSuffix(c.endpoint, "/"), strings.Join(parts, "/"), apiVersion)
+}
+
+// listZones lists up to top DNS zones in the resource group.
+func (c *armClient) listZones(ctx context.Context, top int) (*zonesListResponse, error) {
+	u := fmt.Sprintf("%s&$top=%d", c.resourceURL(dnsAPIVersion, "dnsZones"), top)
+	result := &zonesListResponse{}
+	if _, err := c.do(ctx, http.MethodGet, u, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *armClient) createOrUpdateRecordSet(ctx context.Context, zone, recordType, relativeName string, rs *recordSet) error {
+	_, err := c.do(ctx, http.MethodPut, c.resourceURL(dnsAPIVersion, "dnsZones", zone, recordType, relativeName), rs, nil)
+	return err
+}
+
+// deleteRecordSet deletes the record set, ignoring record sets that do not exist.
+func (c *armClient) deleteRecordSet(ctx context.Context, zone, recordType, relativeName string) error {
+	_, err := c.do(ctx, http.MethodDelete, c.resourceURL(dnsAPIVersion, "dnsZones", zone, recordType, relativeName), nil, nil)
+	return err
+}
+
+func (c *armClient) createOrUpdateTrafficManagerProfile(ctx context.Context, name string, profile *trafficManagerProfile) (*trafficManagerProfile, error) {
+	result := &trafficManagerProfile{}
+	if _, err := c.do(ctx, http.MethodPut, c.resourceURL(trafficManagerAPIVersion, "trafficmanagerprofiles", name), profile, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// deleteTrafficManagerProfile deletes the profile, ignoring profiles that do not exist.
+func (c *armClient) deleteTrafficManagerProfile(ctx context.Context, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, c.resourceURL(trafficManagerAPIVersion, "trafficmanagerprofiles", name), nil, nil)
+	return err
+}
+
+func (c *armClient) do(ctx context.Context, method, u string, in, out interface{}) (int, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return 0, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		armErr := &cloudError{}
+		if err := json.Unmarshal(b, armErr); err == nil && armErr.Error.Message != "" {
+			return resp.StatusCode, fmt.Errorf("azure request %s %s failed with status %d (%s): %s", method, u, resp.StatusCode, armErr.Error.Code, armErr.Error.Message)
+		}
+		return resp.StatusCode, fmt.Errorf("azure request %s %s failed with status %d", method, u, resp.StatusCode)
+	}
+
+	if out == nil || len(b) == 0 {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.Unmarshal(b, out)
+}
@@ -0,0 +1,542 @@
+package azure
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/oauth2/clientcredentials"
+
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/prober"
+)
+
+const (
+	defaultAuthority = "https://login.microsoftonline.com/"
+	managementScope  = "https://management.azure.com/.default"
+
+	TenantIDEnvVar       = "AZURE_TENANT_ID"
+	ClientIDEnvVar       = "AZURE_CLIENT_ID"
+	ClientSecretEnvVar   = "AZURE_CLIENT_SECRET"
+	SubscriptionIDEnvVar = "AZURE_SUBSCRIPTION_ID"
+	ResourceGroupEnvVar  = "AZURE_RESOURCE_GROUP"
+
+	// WeightedRoutingConditionType is reported on the zone status when weighted
+	// endpoints are published through Traffic Manager profiles.
+	WeightedRoutingConditionType = "WeightedRouting"
+
+	// ProviderSpecificMonitorProtocol, ProviderSpecificMonitorPort and ProviderSpecificMonitorPath are set from the
+	// health check of the endpoint, and configure the endpoint monitoring of its Traffic Manager profile.
+	ProviderSpecificMonitorProtocol = "azure/monitor-protocol"
+	ProviderSpecificMonitorPort     = "azure/monitor-port"
+	ProviderSpecificMonitorPath     = "azure/monitor-path"
+
+	trafficManagerDomain       = "trafficmanager.net"
+	trafficManagerEndpointType = "Microsoft.Network/trafficManagerProfiles/externalEndpoints"
+	// Traffic Manager weights must be in the range 1-1000, endpoints with a weight
+	// of 0 are disabled instead.
+	maxTrafficManagerWeight = 1000
+)
+
+// Provider manages records in Azure DNS zones.
+//
+// The DNSZone.ID is the name of the DNS zone in the configured resource group.
+// Azure DNS has no weighted record sets, so weighted endpoints sharing the same
+// name and type are published as a CNAME to a Traffic Manager profile using
+// the weighted routing method, with one external endpoint per target.
+type Provider struct {
+	client *armClient
+	config Config
+	logger logr.Logger
+	prober *prober.Prober
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	SubscriptionID string
+	ResourceGroup  string
+	// TenantID, ClientID and ClientSecret are the service principal
+	// credentials. They are ignored when HTTPClient is set.
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// Endpoint overrides the Azure Resource Manager endpoint. Defaults to the public cloud.
+	Endpoint string
+	// HTTPClient is the client used to talk to the API. When nil, a client
+	// authenticating with the service principal credentials is created.
+	HTTPClient *http.Client
+	// Prober probes the health of the endpoints, as the provider has no
+	// health checks. The endpoints are not health checked when nil.
+	Prober *prober.Prober
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.SubscriptionID == "" || config.ResourceGroup == "" {
+		return nil, fmt.Errorf("an Azure subscription and resource group are required (%s, %s)", SubscriptionIDEnvVar, ResourceGroupEnvVar)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		if config.TenantID == "" || config.ClientID == "" || config.ClientSecret == "" {
+			return nil, fmt.Errorf("azure service principal credentials are required (%s, %s, %s)", TenantIDEnvVar, ClientIDEnvVar, ClientSecretEnvVar)
+		}
+		credentials := clientcredentials.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			TokenURL:     fmt.Sprintf("%s%s/oauth2/v2.0/token", defaultAuthority, config.TenantID),
+			Scopes:       []string{managementScope},
+		}
+		httpClient = credentials.Client(context.Background())
+	}
+
+	p := &Provider{
+		client: &armClient{
+			httpClient:     httpClient,
+			endpoint:       endpoint,
+			subscriptionID: config.SubscriptionID,
+			resourceGroup:  config.ResourceGroup,
+		},
+		prober: config.Prober,
+		config: config,
+		logger: log.Logger.WithName("azure-dns").WithValues("resourceGroup", config.ResourceGroup),
+	}
+	if err := validateServiceEndpoints(p); err != nil {
+		return nil, fmt.Errorf("failed to validate Azure provider service endpoints: %v", err)
+	}
+
+	return p, nil
+}
+
+// validateServiceEndpoints validates that the provider client can communicate
+// with Azure Resource Manager by listing the DNS zones of the resource group.
+func validateServiceEndpoints(provider *Provider) error {
+	if _, err := provider.client.listZones(context.Background(), 1); err != nil {
+		return fmt.Errorf("failed to list azure DNS zones: %v", err)
+	}
+	return nil
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx := context.Background()
+
+	desired, err := recordSetsForEndpoints(record.Spec.Endpoints, zone.ID)
+	if err != nil {
+		return err
+	}
+
+	// Delete any previously published record sets that are no longer present in record.Spec.Endpoints. The stale
+	// record sets are deleted first, as Azure rejects a CNAME record set alongside a record set of another type
+	// for the same name, e.g. when the A record set of a single target is replaced by a traffic manager CNAME.
+	published, err := recordSetsForEndpoints(record.Status.ZoneEndpoints(zone), zone.ID)
+	if err != nil {
+		p.logger.Error(err, "Failed to compute previously published record sets, skipping clean up", "record", record.Name, "zone", zone.ID)
+		published = nil
+	}
+	profiles := desiredProfiles(desired)
+	var errs []error
+	for _, key := range sortedKeys(published) {
+		if _, found := desired[key]; !found {
+			if err := p.client.deleteRecordSet(ctx, zone.ID, key.recordType, key.relativeName); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if rs := published[key]; rs.profile != nil && !profiles[rs.profileName] {
+			if err := p.client.deleteTrafficManagerProfile(ctx, rs.profileName); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := kerrors.NewAggregate(errs); err != nil {
+		return fmt.Errorf("failed to delete stale records of DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+
+	for _, key := range sortedKeys(desired) {
+		rs := desired[key]
+		if rs.profile != nil {
+			profile, err := p.client.createOrUpdateTrafficManagerProfile(ctx, rs.profileName, rs.profile)
+			if err != nil {
+				return fmt.Errorf("couldn't update traffic manager profile %s for DNS record %s: %v", rs.profileName, record.Name, err)
+			}
+			if profile.Properties.DNSConfig.FQDN != "" {
+				rs.set.Properties.CNAMERecord.CNAME = profile.Properties.DNSConfig.FQDN
+			}
+		}
+		if err := p.client.createOrUpdateRecordSet(ctx, zone.ID, key.recordType, key.relativeName, rs.set); err != nil {
+			return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+		}
+	}
+
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx := context.Background()
+
+	published, err := recordSetsForEndpoints(record.Spec.Endpoints, zone.ID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, key := range sortedKeys(published) {
+		if err := p.client.deleteRecordSet(ctx, zone.ID, key.recordType, key.relativeName); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if rs := published[key]; rs.profile != nil {
+			if err := p.client.deleteTrafficManagerProfile(ctx, rs.profileName); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := kerrors.NewAggregate(errs); err != nil {
+		return fmt.Errorf("failed to delete DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// ZoneConditions reports whether the record's weighted endpoints are routed
+// through Traffic Manager profiles in the given zone.
+func (p *Provider) ZoneConditions(record *v1.DNSRecord, zone v1.DNSZone) []v1.DNSZoneCondition {
+	desired, err := recordSetsForEndpoints(record.Spec.Endpoints, zone.ID)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for name := range desiredProfiles(desired) {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return []v1.DNSZoneCondition{{
+		Type:    WeightedRoutingConditionType,
+		Status:  "True",
+		Reason:  "TrafficManager",
+		Message: fmt.Sprintf("Weighted endpoints are routed through Traffic Manager profiles %s", strings.Join(names, ", ")),
+	}}
+}
+
+// ReconcileHealthCheck configures the endpoint monitoring of the Traffic Manager
+// profile of the endpoint, and probes the endpoint with the prober, if any.
+func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
+	p.SetEndpointMonitor(endpoint, &hc.HealthCheckSpec)
+	if p.prober == nil {
+		p.logger.V(3).Info("Health checks are not supported by the Azure provider, skipping", "endpoint", endpoint.SetID())
+		return nil
+	}
+	return p.prober.ReconcileHealthCheck(ctx, hc, endpoint)
+}
+
+func (p *Provider) DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error {
+	p.SetEndpointMonitor(endpoint, nil)
+	return p.prober.DeleteHealthCheck(ctx, endpoint)
+}
+
+// SetEndpointMonitor sets the endpoint monitoring of the Traffic Manager
+// profile of the endpoint from its health check, or removes it when spec is
+// nil, in which case the endpoint is always served.
+func (p *Provider) SetEndpointMonitor(endpoint *v1.Endpoint, spec *v1.HealthCheckSpec) {
+	endpoint.DeleteProviderSpecific(ProviderSpecificMonitorProtocol)
+	endpoint.DeleteProviderSpecific(ProviderSpecificMonitorPort)
+	endpoint.DeleteProviderSpecific(ProviderSpecificMonitorPath)
+	if spec == nil {
+		return
+	}
+
+	protocol := v1.HealthCheckProtocolHTTP
+	if spec.Protocol != nil {
+		protocol = *spec.Protocol
+	}
+	port := int64(80)
+	if protocol == v1.HealthCheckProtocolHTTPS {
+		port = 443
+	}
+	if spec.Port != nil {
+		port = *spec.Port
+	}
+	endpoint.SetProviderSpecific(ProviderSpecificMonitorProtocol, string(protocol))
+	endpoint.SetProviderSpecific(ProviderSpecificMonitorPort, strconv.FormatInt(port, 10))
+	if protocol != v1.HealthCheckProtocolTCP {
+		path := spec.Path
+		if path == "" {
+			path = "/"
+		}
+		endpoint.SetProviderSpecific(ProviderSpecificMonitorPath, path)
+	}
+}
+
+func (p *Provider) HealthCheckStatus(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	return p.prober.HealthCheckStatus(ctx, endpoint)
+}
+
+func (p *Provider) EndpointHealth(endpoint *v1.Endpoint) v1.EndpointHealth {
+	return p.prober.EndpointHealth(endpoint)
+}
+
+func (p *Provider) OnHealthChange(listener func(id string)) {
+	p.prober.OnHealthChange(listener)
+}
+
+type recordSetKey struct {
+	relativeName string
+	recordType   string
+}
+
+type desiredRecordSet struct {
+	set         *recordSet
+	profileName string
+	profile     *trafficManagerProfile
+}
+
+// recordSetsForEndpoints groups endpoints by name and type into Azure DNS
+// record sets, backed by a Traffic Manager profile for weighted groups.
+func recordSetsForEndpoints(endpoints []*v1.Endpoint, zoneName string) (map[recordSetKey]*desiredRecordSet, error) {
+	grouped := map[recordSetKey][]*v1.Endpoint{}
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return nil, fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required")
+		}
+		switch endpoint.RecordType {
+		case string(v1.ARecordType), string(v1.AAAARecordType), string(v1.CNAMERecordType):
+		default:
+			return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+		}
+		relativeName, err := relativeRecordName(endpoint.DNSName, zoneName)
+		if err != nil {
+			return nil, err
+		}
+		key := recordSetKey{relativeName: relativeName, recordType: endpoint.RecordType}
+		grouped[key] = append(grouped[key], endpoint)
+	}
+
+	result := make(map[recordSetKey]*desiredRecordSet, len(grouped))
+	// The weighted A and AAAA endpoints of a name are served by the same Traffic Manager profile
+	weighted := map[string][]*v1.Endpoint{}
+	for key, group := range grouped {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].SetID() < group[j].SetID()
+		})
+		ttl := int64(group[0].RecordTTL)
+
+		if len(group) > 1 && isWeighted(group) {
+			weighted[key.relativeName] = append(weighted[key.relativeName], group...)
+			continue
+		}
+
+		rs := &recordSet{Properties: recordSetProperties{TTL: ttl}}
+		switch key.recordType {
+		case string(v1.ARecordType):
+			for _, endpoint := range group {
+				for _, target := range endpoint.Targets {
+					rs.Properties.ARecords = append(rs.Properties.ARecords, aRecord{IPv4Address: target})
+				}
+			}
+		case string(v1.AAAARecordType):
+			for _, endpoint := range group {
+				for _, target := range endpoint.Targets {
+					rs.Properties.AAAARecords = append(rs.Properties.AAAARecords, aaaaRecord{IPv6Address: target})
+				}
+			}
+		case string(v1.CNAMERecordType):
+			if len(group) > 1 || len(group[0].Targets) > 1 {
+				return nil, fmt.Errorf("a CNAME record set can only have a single target: %s", group[0].DNSName)
+			}
+			rs.Properties.CNAMERecord = &cnameRecord{CNAME: group[0].Targets[0]}
+		}
+		result[key] = &desiredRecordSet{set: rs}
+	}
+
+	for relativeName, group := range weighted {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].SetID() < group[j].SetID()
+		})
+		ttl := int64(group[0].RecordTTL)
+		profileName := trafficManagerProfileName(group[0].DNSName)
+		profile, err := trafficManagerProfileForEndpoints(profileName, ttl, group)
+		if err != nil {
+			return nil, err
+		}
+		cnameKey := recordSetKey{relativeName: relativeName, recordType: string(v1.CNAMERecordType)}
+		result[cnameKey] = &desiredRecordSet{
+			set: &recordSet{Properties: recordSetProperties{
+				TTL:         ttl,
+				CNAMERecord: &cnameRecord{CNAME: fmt.Sprintf("%s.%s", profileName, trafficManagerDomain)},
+			}},
+			profileName: profileName,
+			profile:     profile,
+		}
+	}
+
+	return result, nil
+}
+
+func trafficManagerProfileForEndpoints(name string, ttl int64, endpoints []*v1.Endpoint) (*trafficManagerProfile, error) {
+	profile := &trafficManagerProfile{
+		Location: "global",
+		Properties: trafficManagerProfileProperties{
+			ProfileStatus:        "Enabled",
+			TrafficRoutingMethod: "Weighted",
+			DNSConfig: trafficManagerDNSConfig{
+				RelativeName: name,
+				TTL:          ttl,
+			},
+		},
+	}
+
+	// The endpoints are monitored with their health check, and always served without. A monitor is required by the
+	// profile nonetheless.
+	profile.Properties.MonitorConfig = trafficManagerMonitor{
+		Protocol: string(v1.HealthCheckProtocolHTTP),
+		Port:     80,
+		Path:     "/",
+	}
+	for _, endpoint := range endpoints {
+		if monitor, ok, err := monitorForEndpoint(endpoint); err != nil {
+			return nil, err
+		} else if ok {
+			profile.Properties.MonitorConfig = monitor
+			break
+		}
+	}
+
+	for _, endpoint := range endpoints {
+		weight := int64(0)
+		if prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight); ok {
+			value, err := strconv.ParseInt(prop.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight %q for endpoint %s: %v", prop.Value, endpoint.SetID(), err)
+			}
+			weight = value
+		}
+		status := "Enabled"
+		if weight <= 0 {
+			weight = 1
+			status = "Disabled"
+		}
+		if weight > maxTrafficManagerWeight {
+			weight = maxTrafficManagerWeight
+		}
+		alwaysServe := ""
+		if _, ok := endpoint.GetProviderSpecific(ProviderSpecificMonitorProtocol); !ok {
+			alwaysServe = "Enabled"
+		}
+		for i, target := range endpoint.Targets {
+			// Traffic Manager endpoint names can't contain the colons of the IPv6 set identifiers
+			name := strings.ReplaceAll(endpoint.SetID(), ":", "-")
+			if len(endpoint.Targets) > 1 {
+				name = fmt.Sprintf("%s-%d", name, i)
+			}
+			profile.Properties.Endpoints = append(profile.Properties.Endpoints, trafficManagerEndpoint{
+				Name: name,
+				Type: trafficManagerEndpointType,
+				Properties: trafficManagerEndpointProperties{
+					Target:         target,
+					Weight:         weight,
+					EndpointStatus: status,
+					AlwaysServe:    alwaysServe,
+				},
+			})
+		}
+	}
+
+	return profile, nil
+}
+
+// monitorForEndpoint returns the endpoint monitoring set from the health check of the endpoint, if any. The HTTP and
+// HTTPS requests are sent with the DNS name of the endpoint as Host header, as Traffic Manager sets it to the
+// target of the endpoint otherwise.
+func monitorForEndpoint(endpoint *v1.Endpoint) (trafficManagerMonitor, bool, error) {
+	protocol, ok := endpoint.GetProviderSpecific(ProviderSpecificMonitorProtocol)
+	if !ok {
+		return trafficManagerMonitor{}, false, nil
+	}
+	monitor := trafficManagerMonitor{Protocol: protocol}
+	if value, ok := endpoint.GetProviderSpecific(ProviderSpecificMonitorPort); ok {
+		port, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return trafficManagerMonitor{}, false, fmt.Errorf("invalid monitor port %q for endpoint %s: %v", value, endpoint.SetID(), err)
+		}
+		monitor.Port = port
+	}
+	if protocol != string(v1.HealthCheckProtocolTCP) {
+		monitor.Path, _ = endpoint.GetProviderSpecific(ProviderSpecificMonitorPath)
+		monitor.CustomHeaders = []trafficManagerCustomHeader{{Name: "Host", Value: strings.TrimSuffix(endpoint.DNSName, ".")}}
+	}
+	return monitor, true, nil
+}
+
+func isWeighted(endpoints []*v1.Endpoint) bool {
+	for _, endpoint := range endpoints {
+		if _, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// trafficManagerProfileName returns a name for the profile of a DNS name.
+// The name is also the profile's relative DNS name, which must be globally
+// unique within trafficmanager.net and at most 63 characters.
+func trafficManagerProfileName(dnsName string) string {
+	return fmt.Sprintf("glbc-%x", sha1.Sum([]byte(strings.TrimSuffix(dnsName, "."))))
+}
+
+// relativeRecordName returns the name of the record relative to the zone apex,
+// as expected by the Azure DNS API.
+func relativeRecordName(dnsName, zoneName string) (string, error) {
+	dnsName = strings.TrimSuffix(dnsName, ".")
+	zoneName = strings.TrimSuffix(zoneName, ".")
+	if dnsName == zoneName {
+		return "@", nil
+	}
+	if !strings.HasSuffix(dnsName, "."+zoneName) {
+		return "", fmt.Errorf("%s is not in zone %s", dnsName, zoneName)
+	}
+	return strings.TrimSuffix(dnsName, "."+zoneName), nil
+}
+
+func desiredProfiles(recordSets map[recordSetKey]*desiredRecordSet) map[string]bool {
+	profiles := map[string]bool{}
+	for _, rs := range recordSets {
+		if rs.profile != nil {
+			profiles[rs.profileName] = true
+		}
+	}
+	return profiles
+}
+
+func sortedKeys(recordSets map[recordSetKey]*desiredRecordSet) []recordSetKey {
+	keys := make([]recordSetKey, 0, len(recordSets))
+	for key := range recordSets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].relativeName == keys[j].relativeName {
+			return keys[i].recordType < keys[j].recordType
+		}
+		return keys[i].relativeName < keys[j].relativeName
+	})
+	return keys
+}
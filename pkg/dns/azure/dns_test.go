@@ -0,0 +1,317 @@
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/dnstest"
+)
+
+// fakeARM is a local HTTP stand-in of the Azure Resource Manager API, storing
+// DNS record sets and Traffic Manager profiles in memory.
+type fakeARM struct {
+	mu         sync.Mutex
+	recordSets map[string]*recordSet
+	profiles   map[string]*trafficManagerProfile
+}
+
+func newFakeARM() *fakeARM {
+	return &fakeARM{
+		recordSets: map[string]*recordSet{},
+		profiles:   map[string]*trafficManagerProfile{},
+	}
+}
+
+// conflicts returns whether a record set of the type can't be created for the name of the zone, as a CNAME record set
+// exists for the name, or a record set of another type when creating a CNAME record set
+func (f *fakeARM) conflicts(zone, recordType, name string) bool {
+	for id := range f.recordSets {
+		parts := strings.Split(id, "/")
+		if parts[0] != zone || parts[2] != name || parts[1] == recordType {
+			continue
+		}
+		if recordType == "CNAME" || parts[1] == "CNAME" {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeARM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// /subscriptions/{sub}/resourceGroups/{rg}/providers/Microsoft.Network/{resource...}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 7 {
+		http.NotFound(w, r)
+		return
+	}
+	resource := parts[6:]
+
+	switch {
+	case len(resource) == 1 && resource[0] == "dnsZones":
+		_ = json.NewEncoder(w).Encode(&zonesListResponse{})
+	case len(resource) == 4 && resource[0] == "dnsZones":
+		id := strings.Join(resource[1:], "/")
+		switch r.Method {
+		case http.MethodPut:
+			rs := &recordSet{}
+			if err := json.NewDecoder(r.Body).Decode(rs); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// A CNAME record set can't coexist with a record set of another type for the same name
+			if f.conflicts(resource[1], resource[2], resource[3]) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			f.recordSets[id] = rs
+			_ = json.NewEncoder(w).Encode(rs)
+		case http.MethodDelete:
+			delete(f.recordSets, id)
+		}
+	case len(resource) == 2 && resource[0] == "trafficmanagerprofiles":
+		switch r.Method {
+		case http.MethodPut:
+			profile := &trafficManagerProfile{}
+			if err := json.NewDecoder(r.Body).Decode(profile); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			profile.Properties.DNSConfig.FQDN = profile.Properties.DNSConfig.RelativeName + "." + trafficManagerDomain
+			f.profiles[resource[1]] = profile
+			_ = json.NewEncoder(w).Encode(profile)
+		case http.MethodDelete:
+			if _, ok := f.profiles[resource[1]]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(f.profiles, resource[1])
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestProvider(t *testing.T) {
+	fake := newFakeARM()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider, err := NewProvider(Config{
+		SubscriptionID: "sub",
+		ResourceGroup:  "rg",
+		Endpoint:       server.URL,
+		HTTPClient:     server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+
+	zone := v1.DNSZone{ID: "example.com"}
+	profileName := trafficManagerProfileName("test.example.com")
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				dnstest.WeightedEndpoint("192.168.0.1", "120"),
+				dnstest.WeightedEndpoint("192.168.0.2", "0"),
+			},
+		},
+	}
+
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	cname, ok := fake.recordSets["example.com/CNAME/test"]
+	if !ok || cname.Properties.CNAMERecord == nil || cname.Properties.CNAMERecord.CNAME != profileName+".trafficmanager.net" {
+		t.Fatalf("expected a CNAME to the traffic manager profile, got %+v", cname)
+	}
+	profile, ok := fake.profiles[profileName]
+	if !ok {
+		t.Fatalf("expected traffic manager profile %s to be created", profileName)
+	}
+	if len(profile.Properties.Endpoints) != 2 {
+		t.Fatalf("expected 2 traffic manager endpoints, got %d", len(profile.Properties.Endpoints))
+	}
+	for _, endpoint := range profile.Properties.Endpoints {
+		if endpoint.Properties.Target == "192.168.0.2" && endpoint.Properties.EndpointStatus != "Disabled" {
+			t.Fatalf("expected endpoint with weight 0 to be disabled, got %+v", endpoint)
+		}
+	}
+	conditions := provider.ZoneConditions(record, zone)
+	if len(conditions) != 1 || conditions[0].Type != WeightedRoutingConditionType {
+		t.Fatalf("expected a %s condition, got %+v", WeightedRoutingConditionType, conditions)
+	}
+
+	// Moving down to a single endpoint replaces the traffic manager CNAME with an A record
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = record.Spec.Endpoints[:1]
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if _, ok := fake.recordSets["example.com/CNAME/test"]; ok {
+		t.Fatalf("expected stale CNAME record set to be deleted")
+	}
+	if _, ok := fake.profiles[profileName]; ok {
+		t.Fatalf("expected stale traffic manager profile to be deleted")
+	}
+	a, ok := fake.recordSets["example.com/A/test"]
+	if !ok || len(a.Properties.ARecords) != 1 || a.Properties.ARecords[0].IPv4Address != "192.168.0.1" {
+		t.Fatalf("unexpected A record set %+v", a)
+	}
+	if conditions := provider.ZoneConditions(record, zone); len(conditions) != 0 {
+		t.Fatalf("expected no conditions, got %+v", conditions)
+	}
+
+	// Moving back to two endpoints replaces the A record with a traffic manager CNAME
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = []*v1.Endpoint{
+		dnstest.WeightedEndpoint("192.168.0.1", "120"),
+		dnstest.WeightedEndpoint("192.168.0.2", "120"),
+	}
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if _, ok := fake.recordSets["example.com/A/test"]; ok {
+		t.Fatalf("expected stale A record set to be deleted")
+	}
+	if _, ok := fake.recordSets["example.com/CNAME/test"]; !ok {
+		t.Fatalf("expected a CNAME to the traffic manager profile")
+	}
+
+	if err := provider.Delete(record, zone); err != nil {
+		t.Fatalf("unexpected error deleting record: %v", err)
+	}
+	if len(fake.recordSets) != 0 {
+		t.Fatalf("expected no record sets, got %v", fake.recordSets)
+	}
+}
+
+func TestRecordSetsForEndpointsAAAA(t *testing.T) {
+	endpoints := []*v1.Endpoint{{DNSName: "test.example.com", RecordType: "AAAA", Targets: v1.Targets{"2001:db8::1"}, RecordTTL: 60}}
+	recordSets, err := recordSetsForEndpoints(endpoints, "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	aaaa, ok := recordSets[recordSetKey{relativeName: "test", recordType: "AAAA"}]
+	if !ok || len(aaaa.set.Properties.AAAARecords) != 1 || aaaa.set.Properties.AAAARecords[0].IPv6Address != "2001:db8::1" {
+		t.Fatalf("unexpected AAAA record sets %+v", recordSets)
+	}
+
+	// The weighted A and AAAA endpoints of a name share a traffic manager profile
+	endpoints = []*v1.Endpoint{
+		dnstest.WeightedEndpoint("192.168.0.1", "120"),
+		dnstest.WeightedEndpoint("192.168.0.2", "120"),
+		dnstest.WeightedEndpoint("2001:db8::1", "120"),
+		dnstest.WeightedEndpoint("2001:db8::2", "120"),
+	}
+	endpoints[2].RecordType = "AAAA"
+	endpoints[3].RecordType = "AAAA"
+	recordSets, err = recordSetsForEndpoints(endpoints, "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recordSets) != 1 {
+		t.Fatalf("expected a single CNAME record set, got %+v", recordSets)
+	}
+	cname, ok := recordSets[recordSetKey{relativeName: "test", recordType: "CNAME"}]
+	if !ok || cname.profile == nil || len(cname.profile.Properties.Endpoints) != 4 {
+		t.Fatalf("expected a traffic manager profile with 4 endpoints, got %+v", cname)
+	}
+	for _, endpoint := range cname.profile.Properties.Endpoints {
+		if strings.Contains(endpoint.Name, ":") {
+			t.Errorf("unexpected colon in traffic manager endpoint name %s", endpoint.Name)
+		}
+	}
+}
+
+func TestTrafficManagerProfileMonitor(t *testing.T) {
+	provider := &Provider{}
+	endpoints := []*v1.Endpoint{
+		dnstest.WeightedEndpoint("192.168.0.1", "120"),
+		dnstest.WeightedEndpoint("192.168.0.2", "120"),
+	}
+
+	// The endpoints without health check are always served
+	profile, err := trafficManagerProfileForEndpoints("test", 60, endpoints)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, endpoint := range profile.Properties.Endpoints {
+		if endpoint.Properties.AlwaysServe != "Enabled" {
+			t.Errorf("expected endpoint %s to be always served, got %+v", endpoint.Name, endpoint.Properties)
+		}
+	}
+
+	// The endpoints are monitored with their health check
+	protocol := v1.HealthCheckProtocolHTTPS
+	port := int64(8443)
+	for _, endpoint := range endpoints {
+		provider.SetEndpointMonitor(endpoint, &v1.HealthCheckSpec{Path: "/healthz", Protocol: &protocol, Port: &port})
+	}
+	profile, err = trafficManagerProfileForEndpoints("test", 60, endpoints)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	monitor := profile.Properties.MonitorConfig
+	if monitor.Protocol != "HTTPS" || monitor.Port != 8443 || monitor.Path != "/healthz" {
+		t.Fatalf("expected the monitor of the health check, got %+v", monitor)
+	}
+	if len(monitor.CustomHeaders) != 1 || monitor.CustomHeaders[0].Name != "Host" || monitor.CustomHeaders[0].Value != "test.example.com" {
+		t.Fatalf("expected the DNS name of the endpoints as Host header, got %+v", monitor.CustomHeaders)
+	}
+	for _, endpoint := range profile.Properties.Endpoints {
+		if endpoint.Properties.AlwaysServe != "" {
+			t.Errorf("expected endpoint %s to be monitored, got %+v", endpoint.Name, endpoint.Properties)
+		}
+	}
+
+	// The TCP health checks have no path
+	protocol = v1.HealthCheckProtocolTCP
+	provider.SetEndpointMonitor(endpoints[0], &v1.HealthCheckSpec{Protocol: &protocol})
+	monitor, ok, err := monitorForEndpoint(endpoints[0])
+	if err != nil || !ok {
+		t.Fatalf("expected a monitor, got %v, %v", ok, err)
+	}
+	if monitor.Protocol != "TCP" || monitor.Port != 80 || monitor.Path != "" || len(monitor.CustomHeaders) != 0 {
+		t.Fatalf("unexpected TCP monitor %+v", monitor)
+	}
+
+	// The monitoring is removed with the health check
+	provider.SetEndpointMonitor(endpoints[0], nil)
+	if _, ok, _ := monitorForEndpoint(endpoints[0]); ok {
+		t.Fatalf("expected the monitor to be removed")
+	}
+}
+
+func TestRelativeRecordName(t *testing.T) {
+	cases := []struct {
+		DNSName   string
+		Zone      string
+		Expected  string
+		ExpectErr bool
+	}{
+		{DNSName: "example.com", Zone: "example.com", Expected: "@"},
+		{DNSName: "a.b.example.com.", Zone: "example.com", Expected: "a.b"},
+		{DNSName: "a.example.org", Zone: "example.com", ExpectErr: true},
+		{DNSName: "aexample.com", Zone: "example.com", ExpectErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.DNSName, func(t *testing.T) {
+			name, err := relativeRecordName(tc.DNSName, tc.Zone)
+			if tc.ExpectErr != (err != nil) {
+				t.Fatalf("unexpected error value %v", err)
+			}
+			if name != tc.Expected {
+				t.Fatalf("expected %s, got %s", tc.Expected, name)
+			}
+		})
+	}
+}
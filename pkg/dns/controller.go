@@ -3,6 +3,8 @@ package dns
 import (
 	"context"
 	"os"
+	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -10,6 +12,7 @@ import (
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/kcp-dev/logicalcluster/v2"
+	"github.com/prometheus/client_golang/prometheus"
 
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 	kuadrantv1 "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/clientset/versioned"
@@ -37,18 +40,21 @@ func NewController(config *ControllerConfig) (*Controller, error) {
 	}
 	c.dnsProvider = dnsProvider
 
-	var dnsZones []v1.DNSZone
-	zoneID, zoneIDSet := os.LookupEnv("AWS_DNS_PUBLIC_ZONE_ID")
-	if zoneIDSet {
-		dnsZone := &v1.DNSZone{
-			ID: zoneID,
-		}
-		dnsZones = append(dnsZones, *dnsZone)
-		c.Logger.Info("Using AWS DNS zone", "id", zoneID)
-	} else {
-		c.Logger.Info("No AWS DNS zone id set (AWS_DNS_PUBLIC_ZONE_ID), no DNS records will be created!")
+	c.dnsZones = config.DNSZones
+	c.driftCheckInterval = config.DriftCheckInterval
+	c.driftCorrection = config.DriftCorrection
+	c.driftChecks = map[string]time.Time{}
+	c.recordVerifier = config.RecordVerifier
+	c.verifyAttempts = map[string]int{}
+	c.healthStatusInterval = config.HealthStatusInterval
+	c.healthStatusChecks = map[string]time.Time{}
+	c.healthMetrics = map[string][]prometheus.Labels{}
+	if len(c.dnsZones) == 0 {
+		c.Logger.Info("No DNS zone set, no DNS records will be created!")
+	}
+	for _, zone := range c.dnsZones {
+		c.Logger.Info("Using DNS zone", "domain", zone.Domain, "id", zone.ID, "tags", zone.Tags)
 	}
-	c.dnsZones = dnsZones
 
 	//Logging state of AWS credentials
 	awsIdKey := os.Getenv("AWS_ACCESS_KEY_ID")
@@ -75,8 +81,25 @@ func NewController(config *ControllerConfig) (*Controller, error) {
 		DeleteFunc: func(obj interface{}) { c.Enqueue(obj) },
 	})
 
+	// The records referencing a HealthCheck are published with the health checks it reconciles
+	c.sharedInformerFactory.Kuadrant().V1().HealthChecks().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueueReferencingRecords(obj) },
+		UpdateFunc: func(old, obj interface{}) {
+			if !equality.Semantic.DeepEqual(old.(*v1.HealthCheck).Status, obj.(*v1.HealthCheck).Status) {
+				c.enqueueReferencingRecords(obj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.enqueueReferencingRecords(obj) },
+	})
+
 	c.indexer = c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Informer().GetIndexer()
 	c.lister = c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Lister()
+	c.healthCheckLister = c.sharedInformerFactory.Kuadrant().V1().HealthChecks().Lister()
+
+	// The unhealthy endpoints probed by the provider are withdrawn from the zones, or published back once healthy
+	if healthProber, ok := c.dnsProvider.(EndpointHealthProber); ok {
+		healthProber.OnHealthChange(c.enqueueProbedRecords)
+	}
 
 	return c, nil
 }
@@ -86,6 +109,19 @@ type ControllerConfig struct {
 	DnsRecordClient       kuadrantv1.ClusterInterface
 	SharedInformerFactory externalversions.SharedInformerFactory
 	DNSProvider           string
+	// DNSZones are the zones the records are published to, by the domain of their DNS names
+	DNSZones Zones
+	// DriftCheckInterval is the interval the published records are compared with the record endpoints at, when
+	// supported by the DNS provider. The drift check is disabled when zero.
+	DriftCheckInterval time.Duration
+	// DriftCorrection re-applies the records that have drifted
+	DriftCorrection bool
+	// RecordVerifier verifies the records once propagated, reporting the Verified condition. The records are not
+	// verified when nil.
+	RecordVerifier RecordVerifier
+	// HealthStatusInterval is the interval the health of the health checked endpoints is polled from the DNS
+	// provider at. The health is not polled when zero.
+	HealthStatusInterval time.Duration
 }
 
 type Controller struct {
@@ -94,8 +130,21 @@ type Controller struct {
 	dnsRecordClient       kuadrantv1.ClusterInterface
 	indexer               cache.Indexer
 	lister                kuadrantv1lister.DNSRecordLister
+	healthCheckLister     kuadrantv1lister.HealthCheckLister
 	dnsProvider           Provider
-	dnsZones              []v1.DNSZone
+	dnsZones              Zones
+	driftCheckInterval    time.Duration
+	driftCorrection       bool
+	driftChecksLock       sync.Mutex
+	driftChecks           map[string]time.Time
+	recordVerifier        RecordVerifier
+	verifyAttemptsLock    sync.Mutex
+	verifyAttempts        map[string]int
+	healthStatusInterval  time.Duration
+	healthStatusLock      sync.Mutex
+	healthStatusChecks    map[string]time.Time
+	healthMetricsLock     sync.Mutex
+	healthMetrics         map[string][]prometheus.Labels
 }
 
 func (c *Controller) process(ctx context.Context, key string) error {
@@ -105,6 +154,9 @@ func (c *Controller) process(ctx context.Context, key string) error {
 	}
 
 	if !exists {
+		c.forgetDriftCheck(key)
+		c.forgetVerifyAttempts(key)
+		c.forgetEndpointHealth(key)
 		return nil
 	}
 
@@ -130,5 +182,20 @@ func (c *Controller) process(ctx context.Context, key string) error {
 		}
 	}
 
+	// Check the pending changes until they are propagated, and verified when the records are verified
+	if c.hasPendingChanges(current) && current.DeletionTimestamp == nil {
+		c.Queue.AddAfter(key, c.pendingChangesCheckInterval(key, current))
+	} else {
+		c.forgetVerifyAttempts(key)
+	}
+	// Check the published records for drift periodically
+	if c.driftCheckInterval > 0 && current.DeletionTimestamp == nil {
+		c.Queue.AddAfter(key, c.driftCheckInterval)
+	}
+	// Poll the health of the health checked endpoints periodically
+	if c.healthStatusPolled(current) && current.DeletionTimestamp == nil {
+		c.Queue.AddAfter(key, c.healthStatusInterval)
+	}
+
 	return nil
 }
@@ -1,6 +1,8 @@
 package dns
 
 import (
+	"context"
+
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 )
 
@@ -23,3 +25,80 @@ type FakeProvider struct {
 
 func (_ *FakeProvider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error { return nil }
 func (_ *FakeProvider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error { return nil }
+
+// ZoneConditionsReporter is implemented by providers that report additional,
+// provider specific, conditions for a record published to a zone.
+type ZoneConditionsReporter interface {
+	ZoneConditions(record *v1.DNSRecord, zone v1.DNSZone) []v1.DNSZoneCondition
+}
+
+// DriftDetector is implemented by providers able to compare the records
+// published to a zone with the endpoints of a record.
+type DriftDetector interface {
+	// Drift returns the differences between the endpoints of the record and
+	// the records published to the zone, or none if they are in sync.
+	Drift(record *v1.DNSRecord, zone v1.DNSZone) ([]string, error)
+}
+
+// ChangeTracker is implemented by providers applying the changes
+// asynchronously, so that their propagation to the authoritative nameservers
+// of the zone can be tracked.
+type ChangeTracker interface {
+	// LastChange returns the ID of the last change of the record submitted to
+	// the zone, or an empty string if there is none.
+	LastChange(record *v1.DNSRecord, zone v1.DNSZone) string
+	// ChangePropagated returns whether the change has been propagated.
+	ChangePropagated(changeID string) (bool, error)
+}
+
+// zoneConditionsError is implemented by the provider errors reporting additional
+// conditions for a record that failed to be published to a zone, e.g. when the
+// zone can't be found.
+type zoneConditionsError interface {
+	error
+	ZoneConditions() []v1.DNSZoneCondition
+}
+
+// rejectedEndpointsError is implemented by the provider errors reporting the
+// endpoints of a record the provider can't publish, e.g. for an invalid target.
+type rejectedEndpointsError interface {
+	error
+	RejectedEndpoints() map[*v1.Endpoint]error
+}
+
+// HealthCheckStatusReporter is implemented by providers able to report the
+// health of an endpoint evaluated by the health check reconciled for it.
+type HealthCheckStatusReporter interface {
+	// HealthCheckStatus returns the health of the endpoint, as last observed by
+	// the provider health checkers.
+	HealthCheckStatus(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error)
+}
+
+// EndpointHealthProber is implemented by providers probing the health of the
+// endpoints themselves, as they can't route traffic away from the unhealthy
+// ones. The unhealthy endpoints are withdrawn from the zones instead.
+type EndpointHealthProber interface {
+	// EndpointHealth returns the last probed health of the endpoint.
+	EndpointHealth(endpoint *v1.Endpoint) v1.EndpointHealth
+	// OnHealthChange registers a function called with the ID of the health
+	// checks whose health changes.
+	OnHealthChange(listener func(id string))
+}
+
+// EndpointMonitor is implemented by providers monitoring the health of the
+// endpoints from their own configuration, e.g. Azure Traffic Manager profiles,
+// so that it is set from the HealthCheck referenced by the endpoints.
+type EndpointMonitor interface {
+	// SetEndpointMonitor sets the monitoring of the endpoint from the spec of
+	// its health check, or removes it when spec is nil.
+	SetEndpointMonitor(endpoint *v1.Endpoint, spec *v1.HealthCheckSpec)
+}
+
+// HealthCheckCollector is implemented by providers able to list the health
+// checks they created, so that the health checks no longer referenced by any
+// endpoint, e.g. after a failed deletion, can be garbage collected.
+type HealthCheckCollector interface {
+	// ManagedHealthChecks returns the DNS names of the health checks created
+	// by the provider, by health check ID.
+	ManagedHealthChecks(ctx context.Context) (map[string]string, error)
+}
@@ -2,8 +2,15 @@ package dns
 
 import (
 	"fmt"
+	"sync"
 
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/env"
 	dnsAWS "github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	dnsAzure "github.com/kuadrant/kcp-glbc/pkg/dns/azure"
+	dnsGCP "github.com/kuadrant/kcp-glbc/pkg/dns/gcp"
+	dnsInMemory "github.com/kuadrant/kcp-glbc/pkg/dns/inmemory"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/prober"
+	dnsRFC2136 "github.com/kuadrant/kcp-glbc/pkg/dns/rfc2136"
 )
 
 func DNSProvider(dnsProviderName string) (Provider, error) {
@@ -12,19 +19,126 @@ func DNSProvider(dnsProviderName string) (Provider, error) {
 	switch dnsProviderName {
 	case "aws":
 		dnsProvider, dnsError = newAWSDNSProvider()
+	case "gcp":
+		dnsProvider, dnsError = newGCPDNSProvider()
+	case "azure":
+		dnsProvider, dnsError = newAzureDNSProvider()
+	case "rfc2136":
+		dnsProvider, dnsError = newRFC2136DNSProvider()
+	case "inmemory":
+		dnsProvider, dnsError = newInMemoryDNSProvider()
 	default:
 		dnsProvider = &FakeProvider{}
 	}
 	return dnsProvider, dnsError
 }
 
+var (
+	awsProvider      *dnsAWS.Provider
+	awsProviderError error
+	awsProviderOnce  sync.Once
+)
+
+// newAWSDNSProvider returns the AWS provider. It is shared by all the
+// controllers, so that their changes are batched and rate limited together.
 func newAWSDNSProvider() (Provider, error) {
+	awsProviderOnce.Do(func() {
+		awsProvider, awsProviderError = dnsAWS.NewProvider(dnsAWS.Config{
+			OwnerID:           env.GetEnvString(dnsAWS.OwnerIDEnvVar, ""),
+			ChangeBatchWindow: env.GetEnvDuration(dnsAWS.ChangeBatchWindowEnvVar, dnsAWS.DefaultChangeBatchWindow),
+		})
+	})
+	if awsProviderError != nil {
+		return nil, fmt.Errorf("failed to create AWS DNS manager: %v", awsProviderError)
+	}
+	return awsProvider, nil
+}
+
+func newGCPDNSProvider() (Provider, error) {
+	var dnsProvider Provider
+	provider, err := dnsGCP.NewProvider(dnsGCP.Config{
+		Project: env.GetEnvString(dnsGCP.ProjectEnvVar, ""),
+		Prober:  healthProber(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCP DNS manager: %v", err)
+	}
+	dnsProvider = provider
+
+	return dnsProvider, nil
+}
+
+func newAzureDNSProvider() (Provider, error) {
+	var dnsProvider Provider
+	provider, err := dnsAzure.NewProvider(dnsAzure.Config{
+		SubscriptionID: env.GetEnvString(dnsAzure.SubscriptionIDEnvVar, ""),
+		ResourceGroup:  env.GetEnvString(dnsAzure.ResourceGroupEnvVar, ""),
+		TenantID:       env.GetEnvString(dnsAzure.TenantIDEnvVar, ""),
+		ClientID:       env.GetEnvString(dnsAzure.ClientIDEnvVar, ""),
+		ClientSecret:   env.GetEnvString(dnsAzure.ClientSecretEnvVar, ""),
+		Prober:         healthProber(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure DNS manager: %v", err)
+	}
+	dnsProvider = provider
+
+	return dnsProvider, nil
+}
+
+func newRFC2136DNSProvider() (Provider, error) {
 	var dnsProvider Provider
-	provider, err := dnsAWS.NewProvider(dnsAWS.Config{})
+	provider, err := dnsRFC2136.NewProvider(dnsRFC2136.Config{
+		Nameserver:    env.GetEnvString(dnsRFC2136.NameserverEnvVar, ""),
+		TSIGKeyName:   env.GetEnvString(dnsRFC2136.TSIGKeyNameEnvVar, ""),
+		TSIGSecret:    env.GetEnvString(dnsRFC2136.TSIGSecretEnvVar, ""),
+		TSIGAlgorithm: env.GetEnvString(dnsRFC2136.TSIGAlgorithmEnvVar, ""),
+		Prober:        healthProber(),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AWS DNS manager: %v", err)
+		return nil, fmt.Errorf("failed to create RFC 2136 DNS manager: %v", err)
 	}
 	dnsProvider = provider
 
 	return dnsProvider, nil
 }
+
+var (
+	inMemoryProvider      *dnsInMemory.Provider
+	inMemoryProviderError error
+	inMemoryProviderOnce  sync.Once
+)
+
+// newInMemoryDNSProvider returns the in-memory provider. It is shared by all
+// the controllers, so that they publish to the same zones and embedded server.
+func newInMemoryDNSProvider() (Provider, error) {
+	inMemoryProviderOnce.Do(func() {
+		inMemoryProvider, inMemoryProviderError = dnsInMemory.NewProvider(dnsInMemory.Config{
+			ListenAddress: env.GetEnvString(dnsInMemory.ListenAddressEnvVar, dnsInMemory.DefaultListenAddress),
+			Prober:        healthProber(),
+		})
+	})
+	if inMemoryProviderError != nil {
+		return nil, fmt.Errorf("failed to create in-memory DNS manager: %v", inMemoryProviderError)
+	}
+	return inMemoryProvider, nil
+}
+
+var (
+	sharedProber     *prober.Prober
+	sharedProberOnce sync.Once
+)
+
+// healthProber returns the prober of the endpoint health checks for the
+// providers that have none. It is shared by all the providers, so that each
+// endpoint is probed once. Probing is disabled when the interval is zero.
+func healthProber() *prober.Prober {
+	sharedProberOnce.Do(func() {
+		interval := env.GetEnvDuration(prober.IntervalEnvVar, prober.DefaultInterval)
+		if interval <= 0 {
+			return
+		}
+		sharedProber = prober.NewProber(interval, env.GetEnvDuration(prober.TimeoutEnvVar, prober.DefaultTimeout))
+	})
+	return sharedProber
+}
@@ -2,6 +2,7 @@ package dns
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/kcp-dev/logicalcluster/v2"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilclock "k8s.io/utils/clock"
@@ -52,30 +54,52 @@ func (c *Controller) reconcile(ctx context.Context, dnsRecord *v1.DNSRecord) err
 		dnsRecord.Finalizers = append(dnsRecord.Finalizers, DNSRecordFinalizer)
 	}
 
-	statuses := c.publishRecordToZones(c.dnsZones, dnsRecord)
-	if !dnsZoneStatusSlicesEqual(statuses, dnsRecord.Status.Zones) || dnsRecord.Status.ObservedGeneration != dnsRecord.Generation {
-		dnsRecord.Status.Zones = statuses
-		dnsRecord.Status.ObservedGeneration = dnsRecord.Generation
-	}
-
+	// The health checks are reconciled first, so that the records are published with the ID of their health check.
+	// Otherwise new endpoints are published without health check until the next reconciliation, and are served
+	// even when unhealthy in the meantime.
 	if err := c.ReconcileHealthChecks(ctx, dnsRecord); err != nil {
 		c.Logger.Error(err, "Failed to reconcile health check for DNSRecord", "record", dnsRecord)
 		return err
 	}
+	if err := c.applyHealthCheckRefs(dnsRecord); err != nil {
+		return err
+	}
+
+	statuses := c.publishRecordToZones(ctx, c.dnsZones, dnsRecord)
+	c.reportEndpointHealth(ctx, dnsRecord, statuses)
+	clusters := clusterHealth(dnsRecord.Spec.Endpoints, statuses)
+	if !dnsZoneStatusSlicesEqual(statuses, dnsRecord.Status.Zones) || !equality.Semantic.DeepEqual(clusters, dnsRecord.Status.Clusters) || dnsRecord.Status.ObservedGeneration != dnsRecord.Generation {
+		dnsRecord.Status.Zones = statuses
+		dnsRecord.Status.Clusters = clusters
+		dnsRecord.Status.ObservedGeneration = dnsRecord.Generation
+	}
+	c.reportEndpointHealthMetrics(dnsRecord)
 
 	return nil
 }
 
-func (c *Controller) publishRecordToZones(zones []v1.DNSZone, record *v1.DNSRecord) []v1.DNSZoneStatus {
+func (c *Controller) publishRecordToZones(ctx context.Context, zones Zones, record *v1.DNSRecord) []v1.DNSZoneStatus {
+	checkDrift := c.driftCheckDue(record)
 	var statuses []v1.DNSZoneStatus
 	for i := range zones {
-		zone := zones[i]
+		zone := zones[i].DNSZone
+		// Only publish the record to the zones holding its DNS names, or it has been published to, so that the
+		// records that are no longer held by the zone are removed
+		zoneRecord := c.recordForZone(record, zone)
+		if len(zoneRecord.Spec.Endpoints) == 0 && !RecordIsAlreadyPublishedToZone(record, &zone) {
+			continue
+		}
+		zoneRecord, withdrawn := c.withdrawUnhealthyEndpoints(zoneRecord)
 
 		// Only publish the record if the DNSRecord has been modified
-		// (which would mean the target could have changed) or its
-		// status does not indicate that it has already been published.
-		if record.Generation == record.Status.ObservedGeneration && RecordIsAlreadyPublishedToZone(record, &zone) {
+		// (which would mean the target could have changed), its
+		// status does not indicate that it has already been published,
+		// or the endpoints withdrawn for their health have changed.
+		if record.Generation == record.Status.ObservedGeneration && RecordIsAlreadyPublishedToZone(record, &zone) && !c.endpointsWithdrawalChanged(record, zoneRecord, zone) {
 			c.Logger.Info("Skipping zone to which the DNS record is already published", "record", record, "zone", zone)
+			if status, ok := c.publishedZoneStatus(ctx, record, zoneRecord, withdrawn, zone, checkDrift); ok {
+				statuses = append(statuses, status)
+			}
 			continue
 		}
 
@@ -85,44 +109,112 @@ func (c *Controller) publishRecordToZones(zones []v1.DNSZone, record *v1.DNSReco
 			LastTransitionTime: metav1.Now(),
 		}
 
+		var ensureErr error
 		if RecordIsAlreadyPublishedToZone(record, &zone) {
 			c.Logger.Info("replacing DNS record", "record", record, "zone", zone)
 
-			if err := c.dnsProvider.Ensure(record, zone); err != nil {
-				c.Logger.Error(err, "Failed to replace DNS record in zone", "record", record.Spec, "zone", zone)
+			if err := c.dnsProvider.Ensure(zoneRecord, zone); err != nil {
+				ensureErr = err
+				c.Logger.Error(err, "Failed to replace DNS record in zone", "record", zoneRecord.Spec, "zone", zone)
 				condition.Status = string(ConditionFalse)
 				condition.Type = v1.DNSRecordSucceededConditionType
 				condition.Reason = "ProviderError"
 				condition.Message = fmt.Sprintf("The DNS provider failed to replace the record: %v", err)
 			} else {
-				c.Logger.Info("Replaced DNS record in zone", "record", record.Spec, "zone", zone)
+				c.Logger.Info("Replaced DNS record in zone", "record", zoneRecord.Spec, "zone", zone)
 				condition.Status = string(ConditionTrue)
 				condition.Type = v1.DNSRecordSucceededConditionType
 				condition.Reason = "ProviderSuccess"
 				condition.Message = "The DNS provider succeeded in replacing the record"
 			}
 		} else {
-			if err := c.dnsProvider.Ensure(record, zone); err != nil {
-				c.Logger.Error(err, "Failed to publish DNS record to zone", "record", record.Spec, "zone", zone)
+			if err := c.dnsProvider.Ensure(zoneRecord, zone); err != nil {
+				ensureErr = err
+				c.Logger.Error(err, "Failed to publish DNS record to zone", "record", zoneRecord.Spec, "zone", zone)
 				condition.Status = string(ConditionFalse)
 				condition.Type = v1.DNSRecordSucceededConditionType
 				condition.Reason = "ProviderError"
 				condition.Message = fmt.Sprintf("The DNS provider failed to ensure the record: %v", err)
 			} else {
-				c.Logger.Info("Published DNS record to zone", "record", record.Spec, "zone", zone)
+				c.Logger.Info("Published DNS record to zone", "record", zoneRecord.Spec, "zone", zone)
 				condition.Status = string(ConditionTrue)
 				condition.Type = v1.DNSRecordSucceededConditionType
 				condition.Reason = "ProviderSuccess"
 				condition.Message = "The DNS provider succeeded in ensuring the record"
 			}
 		}
-		statuses = append(statuses, v1.DNSZoneStatus{
-			DNSZone:    zone,
-			Conditions: []v1.DNSZoneCondition{condition},
-			Endpoints:  record.Spec.Endpoints,
-		})
+		conditions := []v1.DNSZoneCondition{condition}
+		if reporter, ok := c.dnsProvider.(ZoneConditionsReporter); ok && condition.Status == string(ConditionTrue) {
+			for _, providerCondition := range reporter.ZoneConditions(zoneRecord, zone) {
+				providerCondition.LastTransitionTime = condition.LastTransitionTime
+				conditions = append(conditions, providerCondition)
+			}
+		}
+		var conditionsErr zoneConditionsError
+		if errors.As(ensureErr, &conditionsErr) {
+			for _, providerCondition := range conditionsErr.ZoneConditions() {
+				providerCondition.LastTransitionTime = condition.LastTransitionTime
+				conditions = append(conditions, providerCondition)
+			}
+		}
+		status := v1.DNSZoneStatus{
+			DNSZone:          zone,
+			Endpoints:        zoneRecord.Spec.Endpoints,
+			EndpointStatuses: append(endpointStatuses(zoneRecord.Spec.Endpoints, ensureErr), withdrawnEndpointStatuses(withdrawn)...),
+		}
+		if ensureErr == nil {
+			if publishedCondition, ok := c.publishedCondition(zoneRecord, zone, &status); ok {
+				conditions = append(conditions, publishedCondition)
+			}
+		} else if current := zoneStatus(record, zone); current != nil {
+			status.ChangeID = current.ChangeID
+		}
+		status.Conditions = conditions
+		statuses = append(statuses, status)
+	}
+	return mergeStatuses(zones.DNSZones(), record.Status.DeepCopy().Zones, statuses)
+}
+
+// publishedZoneStatus returns the status of a record already published to zone, once it has been checked for
+// drift, its pending change has been propagated, it has been verified or the statuses of its endpoints have changed,
+// or false if there is no update.
+func (c *Controller) publishedZoneStatus(ctx context.Context, record, zoneRecord *v1.DNSRecord, withdrawn []*v1.Endpoint, zone v1.DNSZone, checkDrift bool) (v1.DNSZoneStatus, bool) {
+	status := v1.DNSZoneStatus{
+		DNSZone:          zone,
+		Endpoints:        zoneRecord.Spec.Endpoints,
+		EndpointStatuses: append(endpointStatuses(zoneRecord.Spec.Endpoints, nil), withdrawnEndpointStatuses(withdrawn)...),
+	}
+	// The statuses of the endpoints published before they were reported are updated
+	updated := true
+	if current := zoneStatus(record, zone); current != nil {
+		status.ChangeID = current.ChangeID
+		updated = !reflect.DeepEqual(current.EndpointStatuses, status.EndpointStatuses)
+	}
+	if checkDrift {
+		if condition, corrected, ok := c.driftCondition(zoneRecord, zone); ok {
+			status.Conditions = append(status.Conditions, condition)
+			if corrected {
+				if publishedCondition, ok := c.publishedCondition(zoneRecord, zone, &status); ok {
+					status.Conditions = append(status.Conditions, publishedCondition)
+				}
+				return status, true
+			}
+		}
 	}
-	return mergeStatuses(zones, record.Status.DeepCopy().Zones, statuses)
+	propagated, _ := RecordIsPropagatedToZone(record, &zone)
+	if condition := zoneCondition(record, &zone, v1.DNSRecordPropagatedConditionType); condition != nil && !propagated {
+		if condition, ok := c.propagatedCondition(zone, status.ChangeID); ok {
+			status.Conditions = append(status.Conditions, condition)
+			propagated = condition.Status == string(ConditionTrue)
+		}
+	}
+	// The records are verified once propagated
+	if c.recordVerifier != nil && propagated && !recordIsVerifiedInZone(record, &zone) {
+		result := c.recordVerifier.Verify(ctx, zoneRecord.Spec.Endpoints)
+		c.Logger.Info("Verified DNS record", "record", zoneRecord.Spec, "zone", zone, "verified", result.Verified(), "result", result.String())
+		status.Conditions = append(status.Conditions, result.Condition())
+	}
+	return status, updated || len(status.Conditions) > 0
 }
 
 func (c *Controller) deleteRecord(record *v1.DNSRecord) error {
@@ -134,7 +226,7 @@ func (c *Controller) deleteRecord(record *v1.DNSRecord) error {
 		if !RecordIsAlreadyPublishedToZone(record, &zone) {
 			continue
 		}
-		err := c.dnsProvider.Delete(record, zone)
+		err := c.dnsProvider.Delete(c.recordForZone(record, zone), zone)
 		if err != nil {
 			errs = append(errs, err)
 		} else {
@@ -149,6 +241,25 @@ func (c *Controller) deleteRecord(record *v1.DNSRecord) error {
 	return utilerrors.NewAggregate(errs)
 }
 
+// recordForZone returns a copy of the record with the endpoints to publish to zone only. The endpoints last
+// published to the zone are returned when the zone is no longer configured.
+func (c *Controller) recordForZone(record *v1.DNSRecord, zone v1.DNSZone) *v1.DNSRecord {
+	zoneRecord := *record
+	zoneRecord.Spec.Endpoints = c.dnsZones.Endpoints(zone, record.Spec.Endpoints)
+	for _, configured := range c.dnsZones {
+		if reflect.DeepEqual(configured.DNSZone, zone) {
+			return &zoneRecord
+		}
+	}
+	zoneRecord.Spec.Endpoints = nil
+	for _, status := range record.Status.Zones {
+		if reflect.DeepEqual(status.DNSZone, zone) {
+			zoneRecord.Spec.Endpoints = status.Endpoints
+		}
+	}
+	return &zoneRecord
+}
+
 // RecordIsAlreadyPublishedToZone returns a Boolean value indicating whether the
 // given DNSRecord is already published to the given zone, as determined from
 // the DNSRecord's status conditions.
@@ -168,6 +279,30 @@ func RecordIsAlreadyPublishedToZone(record *v1.DNSRecord, zoneToPublish *v1.DNSZ
 	return false
 }
 
+// zoneStatus returns the status of the record in the zone, or nil if there is none
+func zoneStatus(record *v1.DNSRecord, zone v1.DNSZone) *v1.DNSZoneStatus {
+	for i := range record.Status.Zones {
+		if reflect.DeepEqual(record.Status.Zones[i].DNSZone, zone) {
+			return &record.Status.Zones[i]
+		}
+	}
+	return nil
+}
+
+// zoneCondition returns the condition of the given type of the record in the zone, or nil if there is none
+func zoneCondition(record *v1.DNSRecord, zone *v1.DNSZone, conditionType string) *v1.DNSZoneCondition {
+	status := zoneStatus(record, *zone)
+	if status == nil {
+		return nil
+	}
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
 // mergeStatuses updates or extends the provided slice of statuses with the
 // provided updates and returns the resulting slice.
 func mergeStatuses(zones []v1.DNSZone, statuses, updates []v1.DNSZoneStatus) []v1.DNSZoneStatus {
@@ -179,6 +314,8 @@ func mergeStatuses(zones []v1.DNSZone, statuses, updates []v1.DNSZoneStatus) []v
 				add = false
 				statuses[j].Conditions = mergeConditions(status.Conditions, update.Conditions)
 				statuses[j].Endpoints = update.Endpoints
+				statuses[j].ChangeID = update.ChangeID
+				statuses[j].EndpointStatuses = update.EndpointStatuses
 			}
 		}
 		if add {
@@ -0,0 +1,20 @@
+// Package dnstest provides helpers shared by the tests of the DNS providers.
+package dnstest
+
+import (
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// WeightedEndpoint returns a weighted A record endpoint of test.example.com, identified by its target
+func WeightedEndpoint(target, weight string) *v1.Endpoint {
+	endpoint := &v1.Endpoint{
+		DNSName:       "test.example.com",
+		Targets:       v1.Targets{target},
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: target,
+		RecordTTL:     60,
+	}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificWeight, weight)
+	return endpoint
+}
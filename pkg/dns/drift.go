@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// driftCheckDue returns whether the records published for record are due to be checked for drift, and records the
+// check time if so. The records are checked once per drift check interval, whatever the number of reconciliations.
+func (c *Controller) driftCheckDue(record *v1.DNSRecord) bool {
+	if c.driftCheckInterval <= 0 {
+		return false
+	}
+	if _, ok := c.dnsProvider.(DriftDetector); !ok {
+		return false
+	}
+	key, err := cache.MetaNamespaceKeyFunc(record)
+	if err != nil {
+		return false
+	}
+
+	c.driftChecksLock.Lock()
+	defer c.driftChecksLock.Unlock()
+	now := clock.Now()
+	if last, ok := c.driftChecks[key]; ok && now.Sub(last) < c.driftCheckInterval {
+		return false
+	}
+	c.driftChecks[key] = now
+	return true
+}
+
+func (c *Controller) forgetDriftCheck(key string) {
+	c.driftChecksLock.Lock()
+	defer c.driftChecksLock.Unlock()
+	delete(c.driftChecks, key)
+}
+
+// driftCondition returns the Drifted condition of the records published to zone, re-applying them if they have
+// drifted and the drift correction is enabled, in which case the second value is true. It returns false as last
+// value if the drift can't be checked.
+func (c *Controller) driftCondition(zoneRecord *v1.DNSRecord, zone v1.DNSZone) (v1.DNSZoneCondition, bool, bool) {
+	detector, ok := c.dnsProvider.(DriftDetector)
+	if !ok {
+		return v1.DNSZoneCondition{}, false, false
+	}
+
+	drift, err := detector.Drift(zoneRecord, zone)
+	if err != nil {
+		c.Logger.Error(err, "Failed to check DNS record for drift", "record", zoneRecord.Spec, "zone", zone)
+		return v1.DNSZoneCondition{}, false, false
+	}
+
+	condition := v1.DNSZoneCondition{
+		Type:               v1.DNSRecordDriftedConditionType,
+		Status:             string(ConditionFalse),
+		Reason:             "InSync",
+		Message:            "The records published to the zone match the record endpoints",
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(drift) == 0 {
+		return condition, false, true
+	}
+
+	c.Logger.Info("DNS record has drifted", "record", zoneRecord.Spec, "zone", zone, "drift", drift)
+	condition.Status = string(ConditionTrue)
+	condition.Reason = "RecordsDrifted"
+	condition.Message = fmt.Sprintf("The records published to the zone differ from the record endpoints: %s", strings.Join(drift, ", "))
+	if !c.driftCorrection {
+		return condition, false, true
+	}
+	if err := c.dnsProvider.Ensure(zoneRecord, zone); err != nil {
+		c.Logger.Error(err, "Failed to correct drifted DNS record", "record", zoneRecord.Spec, "zone", zone)
+		condition.Reason = "CorrectionFailed"
+		condition.Message = fmt.Sprintf("%s. The DNS provider failed to re-apply the record: %v", condition.Message, err)
+		return condition, false, true
+	}
+	c.Logger.Info("Corrected drifted DNS record", "record", zoneRecord.Spec, "zone", zone)
+	condition.Status = string(ConditionFalse)
+	condition.Reason = "DriftCorrected"
+	condition.Message = fmt.Sprintf("The drifted records have been re-applied: %s", strings.Join(drift, ", "))
+	return condition, true, true
+}
@@ -0,0 +1,287 @@
+package dns
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// withdrawUnhealthyEndpoints returns a copy of the zone record without the endpoints the provider has probed
+// unhealthy, along with the withdrawn endpoints, when the provider probes the health of the endpoints itself. The
+// endpoints of a name and type are all kept when they are all unhealthy, so that the name keeps resolving, as
+// Route53 does when all the records of a name fail their health checks.
+func (c *Controller) withdrawUnhealthyEndpoints(zoneRecord *v1.DNSRecord) (*v1.DNSRecord, []*v1.Endpoint) {
+	healthProber, ok := c.dnsProvider.(EndpointHealthProber)
+	if !ok {
+		return zoneRecord, nil
+	}
+
+	type nameKey struct {
+		dnsName    string
+		recordType string
+	}
+	unhealthy := map[*v1.Endpoint]bool{}
+	healthy := map[nameKey]bool{}
+	for _, endpoint := range zoneRecord.Spec.Endpoints {
+		key := nameKey{dnsName: normalizeDNSName(endpoint.DNSName), recordType: endpoint.RecordType}
+		if healthProber.EndpointHealth(endpoint) == v1.EndpointUnhealthy {
+			unhealthy[endpoint] = true
+		} else {
+			healthy[key] = true
+		}
+	}
+	if len(unhealthy) == 0 {
+		return zoneRecord, nil
+	}
+
+	filtered := *zoneRecord
+	filtered.Spec.Endpoints = nil
+	var withdrawn []*v1.Endpoint
+	for _, endpoint := range zoneRecord.Spec.Endpoints {
+		key := nameKey{dnsName: normalizeDNSName(endpoint.DNSName), recordType: endpoint.RecordType}
+		if unhealthy[endpoint] && healthy[key] {
+			withdrawn = append(withdrawn, endpoint)
+			continue
+		}
+		filtered.Spec.Endpoints = append(filtered.Spec.Endpoints, endpoint)
+	}
+	if len(withdrawn) > 0 {
+		c.Logger.Info("Withdrawing unhealthy endpoints", "record", zoneRecord.Name, "endpoints", len(withdrawn))
+	}
+	return &filtered, withdrawn
+}
+
+// endpointsWithdrawalChanged returns whether the endpoints to publish to the zone differ from the endpoints published
+// to it, as the health of the endpoints the provider probes changes without the record being modified.
+func (c *Controller) endpointsWithdrawalChanged(record, zoneRecord *v1.DNSRecord, zone v1.DNSZone) bool {
+	if _, ok := c.dnsProvider.(EndpointHealthProber); !ok {
+		return false
+	}
+	current := zoneStatus(record, zone)
+	if current == nil {
+		return false
+	}
+
+	published := map[endpointKey]bool{}
+	for _, endpoint := range current.Endpoints {
+		published[endpointKeyFor(endpoint)] = true
+	}
+	if len(published) != len(zoneRecord.Spec.Endpoints) {
+		return true
+	}
+	for _, endpoint := range zoneRecord.Spec.Endpoints {
+		if !published[endpointKeyFor(endpoint)] {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueProbedRecords enqueues the DNSRecords with an endpoint probed by the health check
+func (c *Controller) enqueueProbedRecords(id string) {
+	records, err := c.lister.List(labels.Everything())
+	if err != nil {
+		c.Logger.Error(err, "Failed to list DNSRecords probed by health check", "id", id)
+		return
+	}
+	for _, record := range records {
+		if recordHasHealthCheck(record, id) {
+			c.Enqueue(record)
+		}
+	}
+}
+
+// recordHasHealthCheck returns whether an endpoint of the record, or of its zone statuses, has the health check
+func recordHasHealthCheck(record *v1.DNSRecord, id string) bool {
+	for _, endpoint := range record.Spec.Endpoints {
+		if endpointID, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok && endpointID == id {
+			return true
+		}
+	}
+	for _, zone := range record.Status.Zones {
+		for _, status := range zone.EndpointStatuses {
+			if status.HealthCheckID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// healthStatusPolled returns whether the health of the record endpoints is polled from the provider periodically, i.e.
+// when the provider reports the status of its health checks, rather than probing the endpoints itself, and the record
+// has health checked endpoints.
+func (c *Controller) healthStatusPolled(record *v1.DNSRecord) bool {
+	if c.healthStatusInterval <= 0 {
+		return false
+	}
+	if _, ok := c.dnsProvider.(HealthCheckStatusReporter); !ok {
+		return false
+	}
+	if _, ok := c.dnsProvider.(EndpointHealthProber); ok {
+		return false
+	}
+	for _, endpoint := range record.Spec.Endpoints {
+		if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Controller) healthStatusDue(record *v1.DNSRecord) bool {
+	if c.healthStatusInterval <= 0 {
+		return false
+	}
+	key, err := cache.MetaNamespaceKeyFunc(record)
+	if err != nil {
+		return false
+	}
+
+	c.healthStatusLock.Lock()
+	defer c.healthStatusLock.Unlock()
+	now := clock.Now()
+	if last, ok := c.healthStatusChecks[key]; ok && now.Sub(last) < c.healthStatusInterval {
+		return false
+	}
+	c.healthStatusChecks[key] = now
+	return true
+}
+
+// reportEndpointHealth sets the health of the health checked endpoints in the zone statuses. The health is read from
+// the provider when it probes the endpoints itself, and polled from it when the health status check is due. The
+// previously reported health is kept otherwise, or when the provider fails to report it.
+func (c *Controller) reportEndpointHealth(ctx context.Context, record *v1.DNSRecord, statuses []v1.DNSZoneStatus) {
+	healthProber, probed := c.dnsProvider.(EndpointHealthProber)
+	reporter, reported := c.dnsProvider.(HealthCheckStatusReporter)
+	poll := probed || (reported && c.healthStatusDue(record))
+
+	health := map[string]v1.EndpointHealth{}
+	for i := range statuses {
+		for j := range statuses[i].EndpointStatuses {
+			status := &statuses[i].EndpointStatuses[j]
+			if status.HealthCheckID == "" || status.State == v1.EndpointWithdrawn {
+				continue
+			}
+			previous := previousEndpointHealth(record, statuses[i].DNSZone, status)
+			if !poll {
+				status.Health = previous
+				continue
+			}
+			if h, ok := health[status.HealthCheckID]; ok {
+				status.Health = h
+				continue
+			}
+
+			endpoint := &v1.Endpoint{DNSName: status.DNSName, RecordType: status.RecordType, SetIdentifier: status.SetIdentifier}
+			endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, status.HealthCheckID)
+			if probed {
+				status.Health = healthProber.EndpointHealth(endpoint)
+			} else if h, err := reporter.HealthCheckStatus(ctx, endpoint); err != nil {
+				c.Logger.Error(err, "Failed to get health check status", "record", record.Name, "id", status.HealthCheckID)
+				status.Health = previous
+			} else {
+				status.Health = h
+			}
+			health[status.HealthCheckID] = status.Health
+		}
+	}
+}
+
+// previousEndpointHealth returns the health last reported for the endpoint in the zone status of the record
+func previousEndpointHealth(record *v1.DNSRecord, zone v1.DNSZone, status *v1.EndpointStatus) v1.EndpointHealth {
+	if current := zoneStatus(record, zone); current != nil {
+		for _, previous := range current.EndpointStatuses {
+			if previous.HealthCheckID == status.HealthCheckID && previous.Health != "" &&
+				normalizeDNSName(previous.DNSName) == normalizeDNSName(status.DNSName) &&
+				previous.RecordType == status.RecordType && previous.SetIdentifier == status.SetIdentifier {
+				return previous.Health
+			}
+		}
+	}
+	return v1.EndpointUnknown
+}
+
+type healthCheckedEndpoint struct {
+	endpointKey
+	cluster string
+	health  v1.EndpointHealth
+}
+
+// healthCheckedEndpoints returns the health of the health checked endpoints of the zone statuses, along with the
+// cluster their endpoint is labelled with. The endpoints published to several zones are only returned once, with
+// the first known health reported for them.
+func healthCheckedEndpoints(endpoints []*v1.Endpoint, statuses []v1.DNSZoneStatus) []healthCheckedEndpoint {
+	clusters := map[endpointKey]string{}
+	for _, endpoint := range endpoints {
+		clusters[endpointKeyFor(endpoint)] = endpoint.Labels[v1.ClusterLabel]
+	}
+
+	var healths []healthCheckedEndpoint
+	indexes := map[endpointKey]int{}
+	for _, zone := range statuses {
+		for _, status := range zone.EndpointStatuses {
+			if status.HealthCheckID == "" {
+				continue
+			}
+			key := endpointKey{dnsName: normalizeDNSName(status.DNSName), recordType: status.RecordType, setIdentifier: status.SetIdentifier}
+			health := status.Health
+			if health == "" {
+				health = v1.EndpointUnknown
+			}
+			if i, ok := indexes[key]; ok {
+				if healths[i].health == v1.EndpointUnknown {
+					healths[i].health = health
+				}
+				continue
+			}
+			indexes[key] = len(healths)
+			healths = append(healths, healthCheckedEndpoint{endpointKey: key, cluster: clusters[key], health: health})
+		}
+	}
+	return healths
+}
+
+// clusterHealth summarizes the health of the health checked endpoints per cluster. The endpoints that aren't
+// labelled with the cluster they target are not accounted for.
+func clusterHealth(endpoints []*v1.Endpoint, statuses []v1.DNSZoneStatus) []v1.ClusterHealth {
+	summaries := map[string]*v1.ClusterHealth{}
+	for _, endpoint := range healthCheckedEndpoints(endpoints, statuses) {
+		if endpoint.cluster == "" {
+			continue
+		}
+		summary, ok := summaries[endpoint.cluster]
+		if !ok {
+			summary = &v1.ClusterHealth{Cluster: endpoint.cluster}
+			summaries[endpoint.cluster] = summary
+		}
+		summary.Endpoints++
+		switch endpoint.health {
+		case v1.EndpointHealthy:
+			summary.HealthyEndpoints++
+		case v1.EndpointUnhealthy:
+			summary.Health = v1.EndpointUnhealthy
+		}
+	}
+
+	var clusters []v1.ClusterHealth
+	for _, summary := range summaries {
+		if summary.Health != v1.EndpointUnhealthy {
+			if summary.HealthyEndpoints == summary.Endpoints {
+				summary.Health = v1.EndpointHealthy
+			} else {
+				summary.Health = v1.EndpointUnknown
+			}
+		}
+		clusters = append(clusters, *summary)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Cluster < clusters[j].Cluster
+	})
+	return clusters
+}
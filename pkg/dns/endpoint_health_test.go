@@ -0,0 +1,252 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
+)
+
+// fakeHealthProber stores the endpoints it is ensured with, and reports the health of the endpoints by health check
+type fakeHealthProber struct {
+	FakeProvider
+	endpoints []*v1.Endpoint
+	health    map[string]v1.EndpointHealth
+}
+
+func (f *fakeHealthProber) Ensure(record *v1.DNSRecord, _ v1.DNSZone) error {
+	f.endpoints = record.Spec.Endpoints
+	return nil
+}
+
+func (f *fakeHealthProber) EndpointHealth(endpoint *v1.Endpoint) v1.EndpointHealth {
+	id, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	if health, ok := f.health[id]; ok {
+		return health
+	}
+	return v1.EndpointUnknown
+}
+
+func (f *fakeHealthProber) OnHealthChange(_ func(id string)) {}
+
+// fakeHealthReporter reports the health of the endpoints by health check, counting the health check status requests
+type fakeHealthReporter struct {
+	FakeProvider
+	health   map[string]v1.EndpointHealth
+	err      error
+	requests int
+}
+
+func (f *fakeHealthReporter) HealthCheckStatus(_ context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	f.requests++
+	if f.err != nil {
+		return v1.EndpointUnknown, f.err
+	}
+	id, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	return f.health[id], nil
+}
+
+func TestWithdrawUnhealthyEndpoints(t *testing.T) {
+	zone := v1.DNSZone{ID: "Z1"}
+	provider := &fakeHealthProber{health: map[string]v1.EndpointHealth{"hc-1": v1.EndpointHealthy, "hc-2": v1.EndpointHealthy}}
+	c := &Controller{
+		Controller:  &reconciler.Controller{Logger: logr.Discard()},
+		dnsProvider: provider,
+		dnsZones:    Zones{{DNSZone: zone}},
+	}
+
+	first := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1", Targets: v1.Targets{"192.168.0.1"}}
+	first.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-1")
+	second := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c2", Targets: v1.Targets{"192.168.0.2"}}
+	second.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-2")
+	record := &v1.DNSRecord{Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{first, second}}}
+	record.Generation = 1
+
+	reconcile := func() {
+		record.Status.Zones = c.publishRecordToZones(context.TODO(), c.dnsZones, record)
+		record.Status.ObservedGeneration = record.Generation
+	}
+	states := func() []v1.EndpointState {
+		var states []v1.EndpointState
+		for _, status := range record.Status.Zones[0].EndpointStatuses {
+			states = append(states, status.State)
+		}
+		return states
+	}
+
+	reconcile()
+	if len(provider.endpoints) != 2 {
+		t.Fatalf("expected the healthy endpoints to be published, got %v", provider.endpoints)
+	}
+
+	// The unhealthy endpoint is withdrawn, without the record being modified
+	provider.health["hc-2"] = v1.EndpointUnhealthy
+	reconcile()
+	if len(provider.endpoints) != 1 || provider.endpoints[0].SetIdentifier != "c1" {
+		t.Fatalf("expected the unhealthy endpoint to be withdrawn, got %v", provider.endpoints)
+	}
+	if s := states(); len(s) != 2 || s[0] != v1.EndpointPublished || s[1] != v1.EndpointWithdrawn {
+		t.Fatalf("expected the unhealthy endpoint to be reported withdrawn, got %v", s)
+	}
+	if status := record.Status.Zones[0].EndpointStatuses[1]; status.Health != v1.EndpointUnhealthy || status.HealthCheckID != "hc-2" {
+		t.Fatalf("unexpected withdrawn endpoint status %v", status)
+	}
+
+	// All the endpoints are published when they are all unhealthy
+	provider.health["hc-1"] = v1.EndpointUnhealthy
+	reconcile()
+	if len(provider.endpoints) != 2 {
+		t.Fatalf("expected all the endpoints to be published when all are unhealthy, got %v", provider.endpoints)
+	}
+
+	// The endpoint is published back once healthy
+	provider.health["hc-1"] = v1.EndpointHealthy
+	reconcile()
+	provider.health["hc-2"] = v1.EndpointHealthy
+	reconcile()
+	if len(provider.endpoints) != 2 {
+		t.Fatalf("expected the endpoint to be published back, got %v", provider.endpoints)
+	}
+	if s := states(); len(s) != 2 || s[0] != v1.EndpointPublished || s[1] != v1.EndpointPublished {
+		t.Fatalf("expected the endpoints to be reported published, got %v", s)
+	}
+}
+
+func TestRecordHasHealthCheck(t *testing.T) {
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1"}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-1")
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{endpoint}},
+		Status: v1.DNSRecordStatus{Zones: []v1.DNSZoneStatus{{
+			EndpointStatuses: []v1.EndpointStatus{{DNSName: "echo.example.com", HealthCheckID: "hc-2", State: v1.EndpointWithdrawn}},
+		}}},
+	}
+
+	for id, expected := range map[string]bool{"hc-1": true, "hc-2": true, "hc-3": false} {
+		if recordHasHealthCheck(record, id) != expected {
+			t.Errorf("expected the record to have health check %s: %t", id, expected)
+		}
+	}
+}
+
+func TestReportEndpointHealth(t *testing.T) {
+	zone := v1.DNSZone{ID: "Z1"}
+	provider := &fakeHealthReporter{health: map[string]v1.EndpointHealth{"hc-1": v1.EndpointHealthy, "hc-2": v1.EndpointUnhealthy}}
+	c := &Controller{
+		Controller:           &reconciler.Controller{Logger: logr.Discard()},
+		dnsProvider:          provider,
+		dnsZones:             Zones{{DNSZone: zone}},
+		healthStatusInterval: time.Minute,
+		healthStatusChecks:   map[string]time.Time{},
+		healthMetrics:        map[string][]prometheus.Labels{},
+	}
+
+	first := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "192.168.0.1", Targets: v1.Targets{"192.168.0.1"}, Labels: v1.Labels{v1.ClusterLabel: "c1"}}
+	first.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-1")
+	second := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "192.168.0.2", Targets: v1.Targets{"192.168.0.2"}, Labels: v1.Labels{v1.ClusterLabel: "c2"}}
+	second.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-2")
+	third := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "192.168.0.3", Targets: v1.Targets{"192.168.0.3"}, Labels: v1.Labels{v1.ClusterLabel: "c2"}}
+	third.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-1")
+	record := &v1.DNSRecord{Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{first, second, third}}}
+	record.Name = "echo"
+	record.Namespace = "default"
+
+	reconcile := func() {
+		statuses := c.publishRecordToZones(context.TODO(), c.dnsZones, record)
+		c.reportEndpointHealth(context.TODO(), record, statuses)
+		record.Status.Zones = statuses
+		record.Status.Clusters = clusterHealth(record.Spec.Endpoints, statuses)
+		c.reportEndpointHealthMetrics(record)
+	}
+	healths := func() []v1.EndpointHealth {
+		var healths []v1.EndpointHealth
+		for _, status := range record.Status.Zones[0].EndpointStatuses {
+			healths = append(healths, status.Health)
+		}
+		return healths
+	}
+
+	reconcile()
+	if provider.requests != 2 {
+		t.Fatalf("expected the status of each health check to be requested once, got %d requests", provider.requests)
+	}
+	if h := healths(); h[0] != v1.EndpointHealthy || h[1] != v1.EndpointUnhealthy || h[2] != v1.EndpointHealthy {
+		t.Fatalf("unexpected endpoint health %v", h)
+	}
+	expected := []v1.ClusterHealth{
+		{Cluster: "c1", Health: v1.EndpointHealthy, Endpoints: 1, HealthyEndpoints: 1},
+		{Cluster: "c2", Health: v1.EndpointUnhealthy, Endpoints: 2, HealthyEndpoints: 1},
+	}
+	if len(record.Status.Clusters) != 2 || record.Status.Clusters[0] != expected[0] || record.Status.Clusters[1] != expected[1] {
+		t.Fatalf("expected cluster health %v, got %v", expected, record.Status.Clusters)
+	}
+	if count := testutil.CollectAndCount(endpointHealthGauge); count != 3 {
+		t.Fatalf("expected the health of 3 endpoints to be reported, got %d", count)
+	}
+
+	// The health is kept until the health status check is due
+	provider.health["hc-2"] = v1.EndpointHealthy
+	reconcile()
+	if provider.requests != 2 || healths()[1] != v1.EndpointUnhealthy {
+		t.Fatalf("expected the health not to be polled before the interval, got %d requests and health %v", provider.requests, healths())
+	}
+
+	// The health is kept when the provider fails to report it
+	provider.err = errors.New("throttled")
+	c.healthStatusChecks = map[string]time.Time{}
+	reconcile()
+	if h := healths(); h[0] != v1.EndpointHealthy || h[1] != v1.EndpointUnhealthy {
+		t.Fatalf("expected the health to be kept on error, got %v", h)
+	}
+
+	provider.err = nil
+	c.healthStatusChecks = map[string]time.Time{}
+	reconcile()
+	if record.Status.Clusters[1].Health != v1.EndpointHealthy {
+		t.Fatalf("expected cluster c2 to be healthy, got %v", record.Status.Clusters[1])
+	}
+
+	c.forgetEndpointHealth("default/echo")
+	if count := testutil.CollectAndCount(endpointHealthGauge); count != 0 {
+		t.Fatalf("expected the health metrics to be deleted, got %d", count)
+	}
+}
+
+func TestClusterHealth(t *testing.T) {
+	endpoints := []*v1.Endpoint{
+		{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "a", Labels: v1.Labels{v1.ClusterLabel: "c1"}},
+		{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "b", Labels: v1.Labels{v1.ClusterLabel: "c1"}},
+		{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c"},
+	}
+	statuses := []v1.DNSZoneStatus{
+		{EndpointStatuses: []v1.EndpointStatus{
+			{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "a", HealthCheckID: "hc-1", Health: v1.EndpointUnknown},
+			{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "b", HealthCheckID: "hc-2", Health: v1.EndpointHealthy},
+			{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c", HealthCheckID: "hc-3", Health: v1.EndpointUnhealthy},
+		}},
+		// The endpoints published to several zones are accounted for once
+		{EndpointStatuses: []v1.EndpointStatus{
+			{DNSName: "echo.example.com.", RecordType: "A", SetIdentifier: "a", HealthCheckID: "hc-1", Health: v1.EndpointHealthy},
+		}},
+	}
+
+	clusters := clusterHealth(endpoints, statuses)
+	expected := v1.ClusterHealth{Cluster: "c1", Health: v1.EndpointHealthy, Endpoints: 2, HealthyEndpoints: 2}
+	if len(clusters) != 1 || clusters[0] != expected {
+		t.Fatalf("expected cluster health %v, got %v", expected, clusters)
+	}
+
+	statuses[1].EndpointStatuses[0].Health = v1.EndpointUnknown
+	expected = v1.ClusterHealth{Cluster: "c1", Health: v1.EndpointUnknown, Endpoints: 2, HealthyEndpoints: 1}
+	if clusters := clusterHealth(endpoints, statuses); len(clusters) != 1 || clusters[0] != expected {
+		t.Fatalf("expected cluster health %v, got %v", expected, clusters)
+	}
+}
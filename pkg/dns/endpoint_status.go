@@ -0,0 +1,82 @@
+package dns
+
+import (
+	"errors"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+type endpointKey struct {
+	dnsName       string
+	recordType    string
+	setIdentifier string
+}
+
+func endpointKeyFor(endpoint *v1.Endpoint) endpointKey {
+	return endpointKey{
+		dnsName:       normalizeDNSName(endpoint.DNSName),
+		recordType:    endpoint.RecordType,
+		setIdentifier: endpoint.SetIdentifier,
+	}
+}
+
+// endpointStatuses returns the statuses of the endpoints published to a zone, given the error the DNS provider
+// failed to publish them with, if any. The endpoints the provider reports as rejected are set as rejected, and the
+// others as pending, since the endpoints of a record are published together.
+func endpointStatuses(endpoints []*v1.Endpoint, publishErr error) []v1.EndpointStatus {
+	rejected := map[endpointKey]error{}
+	var rejectedErr rejectedEndpointsError
+	if errors.As(publishErr, &rejectedErr) {
+		for endpoint, err := range rejectedErr.RejectedEndpoints() {
+			rejected[endpointKeyFor(endpoint)] = err
+		}
+	}
+
+	var statuses []v1.EndpointStatus
+	for _, endpoint := range endpoints {
+		status := v1.EndpointStatus{
+			DNSName:       endpoint.DNSName,
+			RecordType:    endpoint.RecordType,
+			SetIdentifier: endpoint.SetIdentifier,
+		}
+		if id, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok {
+			status.HealthCheckID = id
+			status.Health = v1.EndpointUnknown
+		}
+		switch err, ok := rejected[endpointKeyFor(endpoint)]; {
+		case publishErr == nil:
+			status.State = v1.EndpointPublished
+		case ok:
+			status.State = v1.EndpointRejected
+			status.Message = err.Error()
+		case len(rejected) > 0:
+			status.State = v1.EndpointPending
+			status.Message = "Other endpoints of the record were rejected by the DNS provider"
+		default:
+			status.State = v1.EndpointPending
+			status.Message = "The DNS provider failed to publish the record"
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// withdrawnEndpointStatuses returns the statuses of the endpoints withdrawn from a zone, as their health checks
+// report them unhealthy.
+func withdrawnEndpointStatuses(endpoints []*v1.Endpoint) []v1.EndpointStatus {
+	var statuses []v1.EndpointStatus
+	for _, endpoint := range endpoints {
+		healthCheckID, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+		statuses = append(statuses, v1.EndpointStatus{
+			DNSName:       endpoint.DNSName,
+			RecordType:    endpoint.RecordType,
+			SetIdentifier: endpoint.SetIdentifier,
+			State:         v1.EndpointWithdrawn,
+			HealthCheckID: healthCheckID,
+			Health:        v1.EndpointUnhealthy,
+			Message:       "The endpoint is withdrawn from the zone as its health check reports it unhealthy",
+		})
+	}
+	return statuses
+}
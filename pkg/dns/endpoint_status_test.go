@@ -0,0 +1,63 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+func TestEndpointStatuses(t *testing.T) {
+	healthChecked := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1", Targets: v1.Targets{"192.168.0.1"}}
+	healthChecked.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-1")
+	invalid := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c2", Targets: v1.Targets{"lb.example.com"}}
+	endpoints := []*v1.Endpoint{healthChecked, invalid}
+
+	cases := []struct {
+		Name           string
+		Err            error
+		ExpectedStates []v1.EndpointState
+	}{
+		{
+			Name:           "published endpoints",
+			ExpectedStates: []v1.EndpointState{v1.EndpointPublished, v1.EndpointPublished},
+		},
+		{
+			Name: "rejected endpoint",
+			Err: fmt.Errorf("failed to update record: %w", &aws.RejectedEndpointsError{
+				Endpoints: []aws.RejectedEndpoint{{Endpoint: invalid.DeepCopy(), Err: errors.New("invalid A record target lb.example.com")}},
+			}),
+			ExpectedStates: []v1.EndpointState{v1.EndpointPending, v1.EndpointRejected},
+		},
+		{
+			Name:           "provider error",
+			Err:            errors.New("throttled"),
+			ExpectedStates: []v1.EndpointState{v1.EndpointPending, v1.EndpointPending},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			statuses := endpointStatuses(endpoints, testCase.Err)
+			if len(statuses) != len(endpoints) {
+				t.Fatalf("expected %d endpoint statuses, got %v", len(endpoints), statuses)
+			}
+			for i, status := range statuses {
+				if status.State != testCase.ExpectedStates[i] {
+					t.Errorf("expected endpoint %s to be %s, got %s", status.SetIdentifier, testCase.ExpectedStates[i], status.State)
+				}
+			}
+			if statuses[0].HealthCheckID != "hc-1" || statuses[0].Health != v1.EndpointUnknown {
+				t.Errorf("expected the health check of endpoint c1 to be reported, got %v", statuses[0])
+			}
+			if statuses[1].HealthCheckID != "" || statuses[1].Health != "" {
+				t.Errorf("expected endpoint c2 not to be health checked, got %v", statuses[1])
+			}
+			if statuses[1].State == v1.EndpointRejected && statuses[1].Message != "invalid A record target lb.example.com" {
+				t.Errorf("expected the reason endpoint c2 was rejected, got %q", statuses[1].Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// defaultEndpoint is the base URL of the Cloud DNS v1 REST API.
+	defaultEndpoint = "https://dns.googleapis.com/dns/v1/"
+)
+
+// resourceRecordSet is the Cloud DNS representation of a record set.
+// See https://cloud.google.com/dns/docs/reference/v1/resourceRecordSets
+type resourceRecordSet struct {
+	Kind          string         `json:"kind,omitempty"`
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	TTL           int64          `json:"ttl,omitempty"`
+	Rrdatas       []string       `json:"rrdatas,omitempty"`
+	RoutingPolicy *routingPolicy `json:"routingPolicy,omitempty"`
+}
+
+type routingPolicy struct {
+	Wrr *wrrPolicy `json:"wrr,omitempty"`
+}
+
+type wrrPolicy struct {
+	Items []wrrPolicyItem `json:"items"`
+}
+
+type wrrPolicyItem struct {
+	Weight  float64  `json:"weight"`
+	Rrdatas []string `json:"rrdatas"`
+}
+
+// change is the Cloud DNS representation of an atomic update to a managed zone.
+// See https://cloud.google.com/dns/docs/reference/v1/changes
+type change struct {
+	Kind      string               `json:"kind,omitempty"`
+	ID        string               `json:"id,omitempty"`
+	Status    string               `json:"status,omitempty"`
+	Additions []*resourceRecordSet `json:"additions,omitempty"`
+	Deletions []*resourceRecordSet `json:"deletions,omitempty"`
+}
+
+type resourceRecordSetsListResponse struct {
+	Rrsets        []*resourceRecordSet `json:"rrsets"`
+	NextPageToken string               `json:"nextPageToken,omitempty"`
+}
+
+type managedZonesListResponse struct {
+	ManagedZones []struct {
+		Name    string `json:"name"`
+		DNSName string `json:"dnsName"`
+	} `json:"managedZones"`
+}
+
+// apiError is the error body returned by Google APIs.
+type apiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// cloudDNSClient is a minimal client for the Cloud DNS REST API, covering only
+// the calls needed to manage record sets in existing managed zones.
+type cloudDNSClient struct {
+	httpClient *http.Client
+	endpoint   string
+	project    string
+}
+
+func (c *cloudDNSClient) zoneURL(zone string, elem ...string) string {
+	parts := append([]string{"projects", url.PathEscape(c.project), "managedZones", url.PathEscape(zone)}, elem...)
+	return strings.TrimSuffix(c.endpoint, "/") + "/" + strings.Join(parts, "/")
+}
+
+// listManagedZones lists up to maxResults managed zones in the project.
+func (c *cloudDNSClient) listManagedZones(ctx context.Context, maxResults int) (*managedZonesListResponse, error) {
+	u := fmt.Sprintf("%s/projects/%s/managedZones?maxResults=%d", strings.TrimSuffix(c.endpoint, "/"), url.PathEscape(c.project), maxResults)
+	result := &managedZonesListResponse{}
+	if err := c.do(ctx, http.MethodGet, u, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// getResourceRecordSet returns the record set with the given name and type,
+// or nil if it does not exist in the zone.
+func (c *cloudDNSClient) getResourceRecordSet(ctx context.Context, zone, name, recordType string) (*resourceRecordSet, error) {
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("type", recordType)
+	u := c.zoneURL(zone, "rrsets") + "?" + query.Encode()
+
+	result := &resourceRecordSetsListResponse{}
+	if err := c.do(ctx, http.MethodGet, u, nil, result); err != nil {
+		return nil, err
+	}
+	for _, rrset := range result.Rrsets {
+		if rrset.Name == name && rrset.Type == recordType {
+			return rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+// createChange submits an atomic change to the zone.
+func (c *cloudDNSClient) createChange(ctx context.Context, zone string, ch *change) (*change, error) {
+	result := &change{}
+	if err := c.do(ctx, http.MethodPost, c.zoneURL(zone, "changes"), ch, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *cloudDNSClient) do(ctx context.Context, method, u string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &apiError{}
+		if err := json.Unmarshal(b, apiErr); err == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("cloud DNS request %s %s failed with status %d: %s", method, u, resp.StatusCode, apiErr.Error.Message)
+		}
+		return fmt.Errorf("cloud DNS request %s %s failed with status %d", method, u, resp.StatusCode)
+	}
+
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,328 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/oauth2/google"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/prober"
+)
+
+const (
+	// cloudDNSScope is the OAuth2 scope required to manage Cloud DNS record sets.
+	cloudDNSScope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+
+	ProjectEnvVar = "GOOGLE_CLOUD_PROJECT"
+)
+
+// Provider manages records in Google Cloud DNS managed zones.
+//
+// The DNSZone.ID is the name of the managed zone, as documented on v1.DNSZone.
+// Weighted endpoints sharing the same name and type are published as a single
+// record set with a weighted round robin routing policy.
+type Provider struct {
+	client *cloudDNSClient
+	config Config
+	logger logr.Logger
+	prober *prober.Prober
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	// Project is the GCP project hosting the managed zones.
+	Project string
+	// Endpoint overrides the Cloud DNS API base URL. Defaults to the public endpoint.
+	Endpoint string
+	// HTTPClient is the client used to talk to the API. When nil, a client
+	// using the Google application default credentials is created.
+	HTTPClient *http.Client
+	// Prober probes the health of the endpoints, as the provider has no
+	// health checks. The endpoints are not health checked when nil.
+	Prober *prober.Prober
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.Project == "" {
+		return nil, fmt.Errorf("a GCP project is required (%s)", ProjectEnvVar)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		var err error
+		httpClient, err = google.DefaultClient(context.Background(), cloudDNSScope)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create GCP client: %v", err)
+		}
+	}
+
+	p := &Provider{
+		client: &cloudDNSClient{
+			httpClient: httpClient,
+			endpoint:   endpoint,
+			project:    config.Project,
+		},
+		prober: config.Prober,
+		config: config,
+		logger: log.Logger.WithName("gcp-clouddns").WithValues("project", config.Project),
+	}
+	if err := validateServiceEndpoints(p); err != nil {
+		return nil, fmt.Errorf("failed to validate GCP provider service endpoints: %v", err)
+	}
+
+	return p, nil
+}
+
+// validateServiceEndpoints validates that the provider client can communicate
+// with the Cloud DNS API by listing the managed zones of the project.
+func validateServiceEndpoints(provider *Provider) error {
+	if _, err := provider.client.listManagedZones(context.Background(), 1); err != nil {
+		return fmt.Errorf("failed to list cloud DNS managed zones: %v", err)
+	}
+	return nil
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx := context.Background()
+
+	desired, err := recordSetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	// Delete any previously published record sets that are no longer present in record.Spec.Endpoints
+	stale, err := recordSetsForEndpoints(record.Status.ZoneEndpoints(zone))
+	if err != nil {
+		return err
+	}
+	for key := range desired {
+		delete(stale, key)
+	}
+
+	ch := &change{Kind: "dns#change"}
+	for _, key := range sortedKeys(desired) {
+		existing, err := p.client.getResourceRecordSet(ctx, zone.ID, key.name, key.recordType)
+		if err != nil {
+			return fmt.Errorf("failed to get record set %s %s in zone %s: %v", key.name, key.recordType, zone.ID, err)
+		}
+		if existing != nil {
+			if recordSetsEqual(existing, desired[key]) {
+				continue
+			}
+			ch.Deletions = append(ch.Deletions, existing)
+		}
+		ch.Additions = append(ch.Additions, desired[key])
+	}
+	for _, key := range sortedKeys(stale) {
+		existing, err := p.client.getResourceRecordSet(ctx, zone.ID, key.name, key.recordType)
+		if err != nil {
+			return fmt.Errorf("failed to get record set %s %s in zone %s: %v", key.name, key.recordType, zone.ID, err)
+		}
+		if existing != nil {
+			ch.Deletions = append(ch.Deletions, existing)
+		}
+	}
+
+	if err := p.applyChange(ctx, record, zone, ch); err != nil {
+		return err
+	}
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	ctx := context.Background()
+
+	published, err := recordSetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	ch := &change{Kind: "dns#change"}
+	for _, key := range sortedKeys(published) {
+		existing, err := p.client.getResourceRecordSet(ctx, zone.ID, key.name, key.recordType)
+		if err != nil {
+			return fmt.Errorf("failed to get record set %s %s in zone %s: %v", key.name, key.recordType, zone.ID, err)
+		}
+		if existing != nil {
+			ch.Deletions = append(ch.Deletions, existing)
+		}
+	}
+
+	if err := p.applyChange(ctx, record, zone, ch); err != nil {
+		return err
+	}
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// ReconcileHealthCheck probes the endpoint with the prober, if any: Cloud DNS
+// health checks are only available for internal load balancers, which GLBC
+// does not manage.
+func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
+	if p.prober == nil {
+		p.logger.V(3).Info("Health checks are not supported by the GCP provider, skipping", "endpoint", endpoint.SetID())
+		return nil
+	}
+	return p.prober.ReconcileHealthCheck(ctx, hc, endpoint)
+}
+
+func (p *Provider) DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error {
+	return p.prober.DeleteHealthCheck(ctx, endpoint)
+}
+
+func (p *Provider) HealthCheckStatus(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	return p.prober.HealthCheckStatus(ctx, endpoint)
+}
+
+func (p *Provider) EndpointHealth(endpoint *v1.Endpoint) v1.EndpointHealth {
+	return p.prober.EndpointHealth(endpoint)
+}
+
+func (p *Provider) OnHealthChange(listener func(id string)) {
+	p.prober.OnHealthChange(listener)
+}
+
+func (p *Provider) applyChange(ctx context.Context, record *v1.DNSRecord, zone v1.DNSZone, ch *change) error {
+	if len(ch.Additions) == 0 && len(ch.Deletions) == 0 {
+		return nil
+	}
+	resp, err := p.client.createChange(ctx, zone.ID, ch)
+	if err != nil {
+		return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	p.logger.Info("Updated DNS record", "record", record, "zone", zone.ID, "change", resp.ID, "status", resp.Status)
+	return nil
+}
+
+type recordSetKey struct {
+	name       string
+	recordType string
+}
+
+// recordSetsForEndpoints groups endpoints by name and type into Cloud DNS
+// record sets. Groups containing weighted endpoints are converted into a
+// weighted round robin policy with one item per endpoint.
+func recordSetsForEndpoints(endpoints []*v1.Endpoint) (map[recordSetKey]*resourceRecordSet, error) {
+	grouped := map[recordSetKey][]*v1.Endpoint{}
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return nil, fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required")
+		}
+		switch endpoint.RecordType {
+		case string(v1.ARecordType), string(v1.AAAARecordType), string(v1.CNAMERecordType):
+		default:
+			return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+		}
+		key := recordSetKey{name: ensureTrailingDot(endpoint.DNSName), recordType: endpoint.RecordType}
+		grouped[key] = append(grouped[key], endpoint)
+	}
+
+	result := make(map[recordSetKey]*resourceRecordSet, len(grouped))
+	for key, group := range grouped {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].SetID() < group[j].SetID()
+		})
+
+		rrset := &resourceRecordSet{
+			Kind: "dns#resourceRecordSet",
+			Name: key.name,
+			Type: key.recordType,
+			TTL:  int64(group[0].RecordTTL),
+		}
+
+		weighted := false
+		for _, endpoint := range group {
+			if _, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight); ok {
+				weighted = true
+				break
+			}
+		}
+
+		if !weighted {
+			for _, endpoint := range group {
+				rrset.Rrdatas = append(rrset.Rrdatas, targetsToRrdatas(key.recordType, endpoint.Targets)...)
+			}
+			result[key] = rrset
+			continue
+		}
+
+		policy := &wrrPolicy{}
+		for _, endpoint := range group {
+			weight := 0.0
+			if prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight); ok {
+				value, err := strconv.ParseFloat(prop.Value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid weight %q for endpoint %s: %v", prop.Value, endpoint.SetID(), err)
+				}
+				weight = value
+			}
+			policy.Items = append(policy.Items, wrrPolicyItem{
+				Weight:  weight,
+				Rrdatas: targetsToRrdatas(key.recordType, endpoint.Targets),
+			})
+		}
+		rrset.RoutingPolicy = &routingPolicy{Wrr: policy}
+		result[key] = rrset
+	}
+
+	return result, nil
+}
+
+func targetsToRrdatas(recordType string, targets v1.Targets) []string {
+	rrdatas := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if recordType == string(v1.CNAMERecordType) {
+			target = ensureTrailingDot(target)
+		}
+		rrdatas = append(rrdatas, target)
+	}
+	return rrdatas
+}
+
+func recordSetsEqual(a, b *resourceRecordSet) bool {
+	return a.Name == b.Name &&
+		a.Type == b.Type &&
+		a.TTL == b.TTL &&
+		equality.Semantic.DeepEqual(a.Rrdatas, b.Rrdatas) &&
+		equality.Semantic.DeepEqual(a.RoutingPolicy, b.RoutingPolicy)
+}
+
+func sortedKeys(rrsets map[recordSetKey]*resourceRecordSet) []recordSetKey {
+	keys := make([]recordSetKey, 0, len(rrsets))
+	for key := range rrsets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name == keys[j].name {
+			return keys[i].recordType < keys[j].recordType
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
+
+func ensureTrailingDot(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,222 @@
+package gcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/dnstest"
+)
+
+// fakeCloudDNS is a local HTTP stand-in of the Cloud DNS API, storing record
+// sets in memory per managed zone.
+type fakeCloudDNS struct {
+	mu      sync.Mutex
+	zones   map[string]map[recordSetKey]*resourceRecordSet
+	changes int
+}
+
+func newFakeCloudDNS(zones ...string) *fakeCloudDNS {
+	f := &fakeCloudDNS{zones: map[string]map[recordSetKey]*resourceRecordSet{}}
+	for _, zone := range zones {
+		f.zones[zone] = map[recordSetKey]*resourceRecordSet{}
+	}
+	return f
+}
+
+func (f *fakeCloudDNS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// /projects/{project}/managedZones[/{zone}/{collection}]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 3 && parts[2] == "managedZones" {
+		_ = json.NewEncoder(w).Encode(&managedZonesListResponse{})
+		return
+	}
+	if len(parts) != 5 {
+		http.NotFound(w, r)
+		return
+	}
+	rrsets, ok := f.zones[parts[3]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":404,"message":"zone not found"}}`))
+		return
+	}
+
+	switch {
+	case parts[4] == "rrsets" && r.Method == http.MethodGet:
+		result := &resourceRecordSetsListResponse{}
+		if rrset, ok := rrsets[recordSetKey{name: r.URL.Query().Get("name"), recordType: r.URL.Query().Get("type")}]; ok {
+			result.Rrsets = append(result.Rrsets, rrset)
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	case parts[4] == "changes" && r.Method == http.MethodPost:
+		ch := &change{}
+		if err := json.NewDecoder(r.Body).Decode(ch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, deletion := range ch.Deletions {
+			key := recordSetKey{name: deletion.Name, recordType: deletion.Type}
+			if existing, ok := rrsets[key]; !ok || !recordSetsEqual(existing, deletion) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				_, _ = w.Write([]byte(`{"error":{"code":412,"message":"deletion does not match existing record set"}}`))
+				return
+			}
+			delete(rrsets, key)
+		}
+		for _, addition := range ch.Additions {
+			key := recordSetKey{name: addition.Name, recordType: addition.Type}
+			if _, ok := rrsets[key]; ok {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":{"code":409,"message":"record set already exists"}}`))
+				return
+			}
+			rrsets[key] = addition
+		}
+		f.changes++
+		ch.ID = "1"
+		ch.Status = "pending"
+		_ = json.NewEncoder(w).Encode(ch)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestProvider(t *testing.T) {
+	fake := newFakeCloudDNS("example-zone")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	provider, err := NewProvider(Config{
+		Project:    "test-project",
+		Endpoint:   server.URL,
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+
+	zone := v1.DNSZone{ID: "example-zone"}
+	key := recordSetKey{name: "test.example.com.", recordType: "A"}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				dnstest.WeightedEndpoint("192.168.0.2", "60"),
+				dnstest.WeightedEndpoint("192.168.0.1", "120"),
+			},
+		},
+	}
+
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	expected := &resourceRecordSet{
+		Kind: "dns#resourceRecordSet",
+		Name: "test.example.com.",
+		Type: "A",
+		TTL:  60,
+		RoutingPolicy: &routingPolicy{Wrr: &wrrPolicy{Items: []wrrPolicyItem{
+			{Weight: 120, Rrdatas: []string{"192.168.0.1"}},
+			{Weight: 60, Rrdatas: []string{"192.168.0.2"}},
+		}}},
+	}
+	if got := fake.zones["example-zone"][key]; !equality.Semantic.DeepEqual(got, expected) {
+		t.Fatalf("unexpected record set, got %+v, want %+v", got, expected)
+	}
+
+	// Ensuring the same record again must not submit a change
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if fake.changes != 1 {
+		t.Fatalf("expected 1 change, got %d", fake.changes)
+	}
+
+	// Publishing the record under a new name removes the stale record set
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = []*v1.Endpoint{
+		{DNSName: "other.example.com", Targets: v1.Targets{"192.168.0.3"}, RecordType: "A", RecordTTL: 30},
+	}
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if _, ok := fake.zones["example-zone"][key]; ok {
+		t.Fatalf("expected stale record set %v to be deleted", key)
+	}
+	other := fake.zones["example-zone"][recordSetKey{name: "other.example.com.", recordType: "A"}]
+	if other == nil || other.RoutingPolicy != nil || len(other.Rrdatas) != 1 || other.Rrdatas[0] != "192.168.0.3" {
+		t.Fatalf("unexpected record set %+v", other)
+	}
+
+	if err := provider.Delete(record, zone); err != nil {
+		t.Fatalf("unexpected error deleting record: %v", err)
+	}
+	if len(fake.zones["example-zone"]) != 0 {
+		t.Fatalf("expected zone to be empty, got %v", fake.zones["example-zone"])
+	}
+
+	if err := provider.Ensure(record, v1.DNSZone{ID: "missing-zone"}); err == nil {
+		t.Fatalf("expected an error ensuring a record in a missing zone")
+	}
+}
+
+func TestRecordSetsForEndpoints(t *testing.T) {
+	cases := []struct {
+		Name      string
+		Endpoints []*v1.Endpoint
+		ExpectErr bool
+	}{
+		{
+			Name:      "missing targets",
+			Endpoints: []*v1.Endpoint{{DNSName: "test.example.com", RecordType: "A"}},
+			ExpectErr: true,
+		},
+		{
+			Name:      "unsupported type",
+			Endpoints: []*v1.Endpoint{{DNSName: "test.example.com", RecordType: "SRV", Targets: v1.Targets{"foo"}}},
+			ExpectErr: true,
+		},
+		{
+			Name:      "invalid weight",
+			Endpoints: []*v1.Endpoint{dnstest.WeightedEndpoint("192.168.0.1", "heavy")},
+			ExpectErr: true,
+		},
+		{
+			Name:      "cname",
+			Endpoints: []*v1.Endpoint{{DNSName: "test.example.com", RecordType: "CNAME", Targets: v1.Targets{"lb.example.com"}}},
+		},
+		{
+			Name:      "aaaa",
+			Endpoints: []*v1.Endpoint{{DNSName: "test.example.com", RecordType: "AAAA", Targets: v1.Targets{"2001:db8::1"}}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			rrsets, err := recordSetsForEndpoints(tc.Endpoints)
+			if tc.ExpectErr {
+				if err == nil {
+					t.Fatalf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			for _, rrset := range rrsets {
+				if !strings.HasSuffix(rrset.Name, ".") {
+					t.Fatalf("expected fully qualified name, got %s", rrset.Name)
+				}
+			}
+		})
+	}
+}
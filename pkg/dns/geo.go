@@ -0,0 +1,77 @@
+package dns
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	workloadlisters "github.com/kcp-dev/kcp/pkg/client/listers/workload/v1alpha1"
+)
+
+const (
+	// LABEL_GEO_CONTINENT_CODE is set on SyncTargets to the code of the continent the cluster is located in
+	LABEL_GEO_CONTINENT_CODE = "kuadrant.dev/geo-continent-code"
+	// LABEL_GEO_REGION is set on SyncTargets to the cloud region the cluster is located in, e.g. us-east-1
+	LABEL_GEO_REGION = "kuadrant.dev/geo-region"
+)
+
+// ContinentCodes are the continent codes supported by geo aware DNS.
+// See https://docs.aws.amazon.com/Route53/latest/DeveloperGuide/resource-record-sets-values-geo.html
+var ContinentCodes = []string{"AF", "AN", "AS", "EU", "NA", "OC", "SA"}
+
+// IsContinentCode returns whether code is one of ContinentCodes.
+func IsContinentCode(code string) bool {
+	for _, c := range ContinentCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Geo holds the geographical information of a cluster. Each field is empty when unknown.
+type Geo struct {
+	ContinentCode string `json:"continent_code"`
+	Region        string `json:"region"`
+}
+
+// GeoLocator knows how to locate the clusters traffic is sent to
+type GeoLocator interface {
+	// Geo returns the location of the cluster, or nil if it is unknown
+	Geo(ctx context.Context, cluster string) (*Geo, error)
+}
+
+var _ GeoLocator = &SyncTargetGeoLocator{}
+
+// SyncTargetGeoLocator locates clusters from the labels of their SyncTarget. A cluster is either the
+// SyncTarget key, as used in the TMC annotations and labels of the traffic objects, or the SyncTarget name.
+type SyncTargetGeoLocator struct {
+	Lister workloadlisters.SyncTargetLister
+}
+
+func NewSyncTargetGeoLocator(lister workloadlisters.SyncTargetLister) *SyncTargetGeoLocator {
+	return &SyncTargetGeoLocator{Lister: lister}
+}
+
+func (l *SyncTargetGeoLocator) Geo(_ context.Context, cluster string) (*Geo, error) {
+	syncTargets, err := l.Lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, syncTarget := range syncTargets {
+		if syncTarget.Labels[workload.InternalSyncTargetKeyLabel] != cluster && syncTarget.Name != cluster {
+			continue
+		}
+		geo := &Geo{Region: syncTarget.Labels[LABEL_GEO_REGION]}
+		if code := strings.ToUpper(syncTarget.Labels[LABEL_GEO_CONTINENT_CODE]); IsContinentCode(code) {
+			geo.ContinentCode = code
+		}
+		if *geo == (Geo{}) {
+			return nil, nil
+		}
+		return geo, nil
+	}
+	return nil, nil
+}
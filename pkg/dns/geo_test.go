@@ -0,0 +1,68 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	workload "github.com/kcp-dev/kcp/pkg/apis/workload/v1alpha1"
+	workloadlisters "github.com/kcp-dev/kcp/pkg/client/listers/workload/v1alpha1"
+)
+
+func TestSyncTargetGeoLocator(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, syncTarget := range []*workload.SyncTarget{
+		{ObjectMeta: metav1.ObjectMeta{Name: "us-east", Labels: map[string]string{
+			workload.InternalSyncTargetKeyLabel: "key1",
+			LABEL_GEO_CONTINENT_CODE:            "na",
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "eu-west", Labels: map[string]string{
+			LABEL_GEO_CONTINENT_CODE: "EU",
+			LABEL_GEO_REGION:         "eu-west-1",
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "region-only", Labels: map[string]string{
+			LABEL_GEO_REGION: "us-west-2",
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unknown", Labels: map[string]string{
+			LABEL_GEO_CONTINENT_CODE: "XX",
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "unlabelled"}},
+	} {
+		if err := indexer.Add(syncTarget); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	locator := NewSyncTargetGeoLocator(workloadlisters.NewSyncTargetLister(indexer))
+
+	cases := []struct {
+		Cluster               string
+		ExpectedContinentCode string
+		ExpectedRegion        string
+	}{
+		{Cluster: "key1", ExpectedContinentCode: "NA"},
+		{Cluster: "eu-west", ExpectedContinentCode: "EU", ExpectedRegion: "eu-west-1"},
+		{Cluster: "region-only", ExpectedRegion: "us-west-2"},
+		{Cluster: "unknown"},
+		{Cluster: "unlabelled"},
+		{Cluster: "missing"},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.Cluster, func(t *testing.T) {
+			geo, err := locator.Geo(context.TODO(), testCase.Cluster)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if testCase.ExpectedContinentCode == "" && testCase.ExpectedRegion == "" {
+				if geo != nil {
+					t.Fatalf("expected cluster not to be located, got %v", geo)
+				}
+				return
+			}
+			if geo == nil || geo.ContinentCode != testCase.ExpectedContinentCode || geo.Region != testCase.ExpectedRegion {
+				t.Fatalf("expected continent code %q and region %q, got %v", testCase.ExpectedContinentCode, testCase.ExpectedRegion, geo)
+			}
+		})
+	}
+}
@@ -6,16 +6,18 @@ import (
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 )
 
-// TODO once we have a specific Health Check API this should have its own controller rather than piggy backing on the DNSRecord
+// HealthCheckReconciler reconciles the provider health checks of endpoints. The health checks of the HealthCheck API
+// are reconciled by the healthcheck controller, while the ones configured with the DNSRecord annotations are reconciled
+// by the DNSRecord controller.
 type HealthCheckReconciler interface {
-	ReconcileHealthCheck(ctx context.Context, hc v1.HealthCheck, endpoint *v1.Endpoint) error
+	ReconcileHealthCheck(ctx context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error
 
 	DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error
 }
 
 type fakeHealthCheckReconciler struct{}
 
-func (*fakeHealthCheckReconciler) ReconcileHealthCheck(ctx context.Context, _ v1.HealthCheck, _ *v1.Endpoint) error {
+func (*fakeHealthCheckReconciler) ReconcileHealthCheck(ctx context.Context, _ v1.EndpointHealthCheck, _ *v1.Endpoint) error {
 	return nil
 }
 
@@ -10,7 +10,9 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
 
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/slice"
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 )
 
@@ -22,6 +24,9 @@ type healthChecksConfig struct {
 	Port             *int64
 	FailureThreshold *int64
 	Protocol         *v1.HealthCheckProtocol
+	SearchString     string
+	Interval         *int64
+	Regions          []string
 }
 
 // annotationsConfigMap contains the logic to map an annotation-based configuration
@@ -37,13 +42,12 @@ var annotationsConfigMap = map[string]func(string, *healthChecksConfig) error{
 	"protocol": notNilConfig(func(protocol string, c *healthChecksConfig) error {
 		var value v1.HealthCheckProtocol
 		switch protocol {
-		case string(v1.HealthCheckProtocolHTTP):
-		case string(v1.HealthCheckProtocolHTTPS):
+		case string(v1.HealthCheckProtocolHTTP), string(v1.HealthCheckProtocolHTTPS), string(v1.HealthCheckProtocolTCP):
 			value = v1.HealthCheckProtocol(protocol)
 		}
 
 		if value == "" {
-			return fmt.Errorf("invalid protocol %s. Only supported values are HTTP and HTTPS", protocol)
+			return fmt.Errorf("invalid protocol %s. Only supported values are HTTP, HTTPS and TCP", protocol)
 		}
 
 		c.Protocol = &value
@@ -52,6 +56,42 @@ var annotationsConfigMap = map[string]func(string, *healthChecksConfig) error{
 	"failure-threshold": notNilConfig(configInt64(func(v int64, c *healthChecksConfig) {
 		c.FailureThreshold = &v
 	})),
+	"search-string": notNilConfig(func(searchString string, c *healthChecksConfig) error {
+		if len(searchString) > 255 {
+			return fmt.Errorf("search string %s is longer than 255 characters", searchString)
+		}
+
+		c.SearchString = searchString
+		return nil
+	}),
+	"interval": notNilConfig(func(interval string, c *healthChecksConfig) error {
+		value, err := strconv.ParseInt(interval, 10, 64)
+		if err != nil {
+			return err
+		}
+		if value != 10 && value != 30 {
+			return fmt.Errorf("invalid interval %s. Only supported values are 10 and 30", interval)
+		}
+
+		c.Interval = &value
+		return nil
+	}),
+	"regions": notNilConfig(func(regions string, c *healthChecksConfig) error {
+		var values []string
+		for _, region := range strings.Split(regions, ",") {
+			region = strings.TrimSpace(region)
+			if !slice.ContainsString(route53.HealthCheckRegion_Values(), region) {
+				return fmt.Errorf("invalid region %s. Only supported values are %s", region, strings.Join(route53.HealthCheckRegion_Values(), ", "))
+			}
+			values = append(values, region)
+		}
+		if len(values) < 3 {
+			return fmt.Errorf("at least 3 regions are required, got %s", regions)
+		}
+
+		c.Regions = values
+		return nil
+	}),
 }
 
 func (c *Controller) ReconcileHealthChecks(ctx context.Context, dnsRecord *v1.DNSRecord) error {
@@ -79,19 +119,32 @@ func (c *Controller) reconcileHealthCheck(ctx context.Context, config *healthChe
 			c.Logger.Info("Skipping health check creation: no address set", "record", dnsRecord, "endpoint", dnsEndpoint.DNSName)
 			continue
 		}
+		// Other records, e.g. geo CNAMEs, point to the A and AAAA records that are health checked
+		if dnsEndpoint.RecordType != string(v1.ARecordType) && dnsEndpoint.RecordType != string(v1.AAAARecordType) {
+			continue
+		}
+		// The health check is reconciled by the HealthCheck controller
+		if dnsEndpoint.HealthCheckRef != nil {
+			continue
+		}
 
 		endpointId, err := idForEndpoint(dnsRecord, dnsEndpoint)
 		if err != nil {
 			return err
 		}
 
-		spec := v1.HealthCheck{
-			Id:               endpointId,
-			Name:             fmt.Sprintf("%s-%s", dnsEndpoint.DNSName, dnsEndpoint.SetIdentifier),
-			Path:             config.Endpoint,
-			Port:             config.Port,
-			Protocol:         config.Protocol,
-			FailureThreshold: config.FailureThreshold,
+		spec := v1.EndpointHealthCheck{
+			Id:   endpointId,
+			Name: fmt.Sprintf("%s-%s", dnsEndpoint.DNSName, dnsEndpoint.SetIdentifier),
+			HealthCheckSpec: v1.HealthCheckSpec{
+				Path:             config.Endpoint,
+				Port:             config.Port,
+				Protocol:         config.Protocol,
+				FailureThreshold: config.FailureThreshold,
+				SearchString:     config.SearchString,
+				Interval:         config.Interval,
+				Regions:          config.Regions,
+			},
 		}
 
 		c.Logger.Info("Reconciling health check for endpoint", "name", dnsEndpoint.DNSName, "identifier", dnsEndpoint.SetIdentifier)
@@ -109,6 +162,10 @@ func (c *Controller) reconcileHealthCheckDeletion(ctx context.Context, dnsRecord
 
 	for _, zone := range dnsRecord.Status.Zones {
 		for _, endpoint := range zone.Endpoints {
+			// The health check is deleted by the HealthCheck controller
+			if endpoint.HealthCheckRef != nil {
+				continue
+			}
 			if err := c.dnsProvider.DeleteHealthCheck(ctx, endpoint); err != nil {
 				return err
 			}
@@ -170,16 +227,24 @@ func validateHealthChecksConfig(config *healthChecksConfig) error {
 		return errors.New("health checks config can't be nil")
 	}
 
-	if config.Endpoint == "" {
-		return errors.New("endpoint is a required value to configure health checks")
-	}
-	if config.Port == nil {
-		config.Port = aws.Int64(80)
-	}
 	if config.Protocol == nil {
 		defaultProtocol := v1.HealthCheckProtocolHTTP
 		config.Protocol = &defaultProtocol
 	}
+	// The TCP health checks only open a connection
+	if config.Endpoint == "" && *config.Protocol != v1.HealthCheckProtocolTCP {
+		return errors.New("endpoint is a required value to configure HTTP and HTTPS health checks")
+	}
+	if config.Port == nil {
+		if *config.Protocol == v1.HealthCheckProtocolHTTPS {
+			config.Port = aws.Int64(443)
+		} else {
+			config.Port = aws.Int64(80)
+		}
+	}
+	if config.SearchString != "" && *config.Protocol == v1.HealthCheckProtocolTCP {
+		return errors.New("search string is not supported by TCP health checks")
+	}
 
 	return nil
 }
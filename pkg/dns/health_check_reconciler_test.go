@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+func TestConfigFromAnnotations(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expectErr   bool
+		verify      func(t *testing.T, config *healthChecksConfig)
+	}{
+		{
+			name:        "no health check annotation",
+			annotations: map[string]string{"kuadrant.experimental/ttl": "60"},
+			verify: func(t *testing.T, config *healthChecksConfig) {
+				if config != nil {
+					t.Fatalf("expected no config, got %+v", config)
+				}
+			},
+		},
+		{
+			name: "HTTPS with search string, interval and regions",
+			annotations: map[string]string{
+				ANNOTATION_HEALTH_CHECK_PREFIX + "endpoint":      "/healthz",
+				ANNOTATION_HEALTH_CHECK_PREFIX + "protocol":      "HTTPS",
+				ANNOTATION_HEALTH_CHECK_PREFIX + "search-string": "ok",
+				ANNOTATION_HEALTH_CHECK_PREFIX + "interval":      "10",
+				ANNOTATION_HEALTH_CHECK_PREFIX + "regions":       "us-east-1, eu-west-1,ap-southeast-1",
+			},
+			verify: func(t *testing.T, config *healthChecksConfig) {
+				if err := validateHealthChecksConfig(config); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if *config.Protocol != v1.HealthCheckProtocolHTTPS || *config.Port != 443 {
+					t.Fatalf("expected HTTPS on port 443, got %s on port %d", *config.Protocol, *config.Port)
+				}
+				if config.SearchString != "ok" || *config.Interval != 10 || len(config.Regions) != 3 || config.Regions[1] != "eu-west-1" {
+					t.Fatalf("unexpected config %+v", config)
+				}
+			},
+		},
+		{
+			name: "TCP without endpoint",
+			annotations: map[string]string{
+				ANNOTATION_HEALTH_CHECK_PREFIX + "protocol": "TCP",
+				ANNOTATION_HEALTH_CHECK_PREFIX + "port":     "5432",
+			},
+			verify: func(t *testing.T, config *healthChecksConfig) {
+				if err := validateHealthChecksConfig(config); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if *config.Protocol != v1.HealthCheckProtocolTCP || *config.Port != 5432 {
+					t.Fatalf("expected TCP on port 5432, got %s on port %d", *config.Protocol, *config.Port)
+				}
+			},
+		},
+		{
+			name: "HTTP without endpoint",
+			annotations: map[string]string{
+				ANNOTATION_HEALTH_CHECK_PREFIX + "port": "8080",
+			},
+			verify: func(t *testing.T, config *healthChecksConfig) {
+				if err := validateHealthChecksConfig(config); err == nil {
+					t.Fatalf("expected an error for an HTTP health check without endpoint")
+				}
+			},
+		},
+		{
+			name: "TCP with search string",
+			annotations: map[string]string{
+				ANNOTATION_HEALTH_CHECK_PREFIX + "endpoint":      "/",
+				ANNOTATION_HEALTH_CHECK_PREFIX + "protocol":      "TCP",
+				ANNOTATION_HEALTH_CHECK_PREFIX + "search-string": "ok",
+			},
+			verify: func(t *testing.T, config *healthChecksConfig) {
+				if err := validateHealthChecksConfig(config); err == nil {
+					t.Fatalf("expected an error for a TCP search string")
+				}
+			},
+		},
+		{
+			name:        "invalid protocol",
+			annotations: map[string]string{ANNOTATION_HEALTH_CHECK_PREFIX + "protocol": "UDP"},
+			expectErr:   true,
+		},
+		{
+			name:        "invalid interval",
+			annotations: map[string]string{ANNOTATION_HEALTH_CHECK_PREFIX + "interval": "20"},
+			expectErr:   true,
+		},
+		{
+			name:        "too few regions",
+			annotations: map[string]string{ANNOTATION_HEALTH_CHECK_PREFIX + "regions": "us-east-1,eu-west-1"},
+			expectErr:   true,
+		},
+		{
+			name:        "invalid region",
+			annotations: map[string]string{ANNOTATION_HEALTH_CHECK_PREFIX + "regions": "us-east-1,eu-west-1,eu-central-1"},
+			expectErr:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			config, err := configFromAnnotations(testCase.annotations)
+			if (err != nil) != testCase.expectErr {
+				t.Fatalf("expected error to be %t, got %v", testCase.expectErr, err)
+			}
+			if testCase.verify != nil {
+				testCase.verify(t, config)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package dns
+
+import (
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clusters"
+
+	"github.com/kcp-dev/logicalcluster/v2"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// applyHealthCheckRefs sets the IDs of the health checks reconciled by the HealthCheck controller on the endpoints
+// referencing a HealthCheck, so that the records are published with their health check, and the monitoring of the
+// endpoints by the providers implementing EndpointMonitor
+func (c *Controller) applyHealthCheckRefs(dnsRecord *v1.DNSRecord) error {
+	monitor, _ := c.dnsProvider.(EndpointMonitor)
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		if endpoint.HealthCheckRef == nil {
+			continue
+		}
+
+		healthCheck, err := c.healthCheckLister.HealthChecks(dnsRecord.Namespace).Get(clusters.ToClusterAwareKey(logicalcluster.From(dnsRecord), endpoint.HealthCheckRef.Name))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+
+		id := ""
+		var spec *v1.HealthCheckSpec
+		if healthCheck != nil && healthCheck.DeletionTimestamp == nil {
+			spec = &healthCheck.Spec
+			for _, status := range healthCheck.Status.Endpoints {
+				if status.DNSRecord == dnsRecord.Name && status.DNSName == endpoint.DNSName && status.SetIdentifier == endpoint.SetIdentifier {
+					id = status.HealthCheckID
+					break
+				}
+			}
+		}
+
+		if id == "" {
+			endpoint.DeleteProviderSpecific(aws.ProviderSpecificHealthCheckID)
+		} else {
+			endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, id)
+		}
+		if monitor != nil {
+			monitor.SetEndpointMonitor(endpoint, spec)
+		}
+	}
+	return nil
+}
+
+// enqueueReferencingRecords enqueues the DNSRecords with endpoints referencing the HealthCheck
+func (c *Controller) enqueueReferencingRecords(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	healthCheck, ok := obj.(*v1.HealthCheck)
+	if !ok {
+		return
+	}
+
+	records, err := c.lister.DNSRecords(healthCheck.Namespace).List(labels.Everything())
+	if err != nil {
+		c.Logger.Error(err, "Failed to list DNSRecords referencing HealthCheck", "healthCheck", healthCheck.Name)
+		return
+	}
+	for _, record := range records {
+		if logicalcluster.From(record) != logicalcluster.From(healthCheck) {
+			continue
+		}
+		for _, endpoint := range record.Spec.Endpoints {
+			if endpoint.HealthCheckRef != nil && endpoint.HealthCheckRef.Name == healthCheck.Name {
+				c.Enqueue(record)
+				break
+			}
+		}
+	}
+}
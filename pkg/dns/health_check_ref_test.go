@@ -0,0 +1,107 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
+)
+
+func TestApplyHealthCheckRefs(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	c := &Controller{
+		Controller:        &reconciler.Controller{Logger: logr.Discard()},
+		healthCheckLister: kuadrantv1lister.NewHealthCheckLister(indexer),
+	}
+
+	err := indexer.Add(&v1.HealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Status: v1.HealthCheckStatus{Endpoints: []v1.HealthCheckEndpointStatus{
+			{DNSRecord: "echo", DNSName: "echo.example.com", SetIdentifier: "cluster1", HealthCheckID: "abc"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withHealthCheck := &v1.Endpoint{DNSName: "echo.example.com", SetIdentifier: "cluster1", HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}}
+	pending := &v1.Endpoint{DNSName: "echo.example.com", SetIdentifier: "cluster2", HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}}
+	pending.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "stale")
+	missing := &v1.Endpoint{DNSName: "echo.example.com", SetIdentifier: "cluster3", HealthCheckRef: &v1.HealthCheckReference{Name: "missing"}}
+	annotated := &v1.Endpoint{DNSName: "echo.example.com", SetIdentifier: "cluster4"}
+	annotated.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "def")
+
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec:       v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{withHealthCheck, pending, missing, annotated}},
+	}
+	if err := c.applyHealthCheckRefs(record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if id, _ := withHealthCheck.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); id != "abc" {
+		t.Fatalf("expected the health check ID of the HealthCheck status, got %q", id)
+	}
+	if _, ok := pending.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok {
+		t.Fatalf("expected the health check ID of an endpoint missing from the HealthCheck status to be removed")
+	}
+	if _, ok := missing.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok {
+		t.Fatalf("expected no health check ID for a missing HealthCheck")
+	}
+	if id, _ := annotated.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); id != "def" {
+		t.Fatalf("expected the health check ID of an endpoint without HealthCheck reference to be kept, got %q", id)
+	}
+}
+
+// monitoringProvider records the endpoint monitoring set by the controller
+type monitoringProvider struct {
+	FakeProvider
+	specs map[string]*v1.HealthCheckSpec
+}
+
+func (p *monitoringProvider) SetEndpointMonitor(endpoint *v1.Endpoint, spec *v1.HealthCheckSpec) {
+	p.specs[endpoint.SetIdentifier] = spec
+}
+
+func TestApplyHealthCheckRefsEndpointMonitor(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	provider := &monitoringProvider{specs: map[string]*v1.HealthCheckSpec{}}
+	c := &Controller{
+		Controller:        &reconciler.Controller{Logger: logr.Discard()},
+		healthCheckLister: kuadrantv1lister.NewHealthCheckLister(indexer),
+		dnsProvider:       provider,
+	}
+
+	err := indexer.Add(&v1.HealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec:       v1.HealthCheckSpec{Path: "/healthz"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{
+			{DNSName: "echo.example.com", SetIdentifier: "cluster1", HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+			{DNSName: "echo.example.com", SetIdentifier: "cluster2", HealthCheckRef: &v1.HealthCheckReference{Name: "missing"}},
+		}},
+	}
+	if err := c.applyHealthCheckRefs(record); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if spec := provider.specs["cluster1"]; spec == nil || spec.Path != "/healthz" {
+		t.Fatalf("expected the endpoint to be monitored with the HealthCheck spec, got %v", spec)
+	}
+	if spec, ok := provider.specs["cluster2"]; !ok || spec != nil {
+		t.Fatalf("expected the monitoring of the endpoint referencing a missing HealthCheck to be removed, got %v", spec)
+	}
+}
@@ -0,0 +1,181 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/informers/externalversions"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+type HealthCheckSweeperConfig struct {
+	DNSProvider string
+	// SharedInformerFactories are the informer factories of the DNSRecords and HealthChecks referencing the health
+	// checks, one per APIExport
+	SharedInformerFactories []externalversions.SharedInformerFactory
+	// DNSZones are the zones the records are published to. Only the health checks of the DNS names of the zones are
+	// garbage collected.
+	DNSZones Zones
+	// Interval is the interval the health checks are swept at. The health checks are not swept when zero.
+	Interval time.Duration
+	// DryRun reports the orphaned health checks without deleting them
+	DryRun bool
+	// OwnerID is the ID of the GLBC instance the health checks are created by. The health checks are not swept
+	// without it, as the health checks of the other instances sharing the DNS provider can't be told apart.
+	OwnerID string
+}
+
+// HealthCheckSweeper periodically deletes the health checks created by the DNS provider that are no longer
+// referenced by any DNSRecord or HealthCheck, across the logical clusters, e.g. when the controller failed to delete
+// them, or the endpoint they were created for was removed from the record before they were deleted.
+type HealthCheckSweeper struct {
+	logger             logr.Logger
+	dnsProvider        Provider
+	recordListers      []kuadrantv1lister.DNSRecordLister
+	healthCheckListers []kuadrantv1lister.HealthCheckLister
+	dnsZones           Zones
+	interval           time.Duration
+	dryRun             bool
+	ownerID            string
+	// orphans are the health checks found unreferenced by the previous sweep. The health checks are only deleted
+	// once found unreferenced by two consecutive sweeps, so that the health checks created since the records were
+	// last listed are not deleted.
+	orphans map[string]bool
+}
+
+func NewHealthCheckSweeper(config *HealthCheckSweeperConfig) (*HealthCheckSweeper, error) {
+	dnsProvider, err := DNSProvider(config.DNSProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &HealthCheckSweeper{
+		logger:      log.Logger.WithName("health-check-sweeper"),
+		dnsProvider: dnsProvider,
+		dnsZones:    config.DNSZones,
+		interval:    config.Interval,
+		dryRun:      config.DryRun,
+		ownerID:     config.OwnerID,
+		orphans:     map[string]bool{},
+	}
+	for _, factory := range config.SharedInformerFactories {
+		s.recordListers = append(s.recordListers, factory.Kuadrant().V1().DNSRecords().Lister())
+		s.healthCheckListers = append(s.healthCheckListers, factory.Kuadrant().V1().HealthChecks().Lister())
+	}
+	return s, nil
+}
+
+func (s *HealthCheckSweeper) Start(ctx context.Context, _ int) {
+	if s.interval <= 0 {
+		return
+	}
+	if _, ok := s.dnsProvider.(HealthCheckCollector); !ok {
+		s.logger.Info("Health check garbage collection not supported by the DNS provider, skipping")
+		return
+	}
+	if s.ownerID == "" {
+		s.logger.Info("Health check garbage collection requires an owner ID, skipping")
+		return
+	}
+
+	s.logger.Info("Starting health check sweeper", "interval", s.interval, "dryRun", s.dryRun)
+	defer s.logger.Info("Stopping health check sweeper")
+	wait.UntilWithContext(ctx, s.sweep, s.interval)
+}
+
+// sweep deletes the health checks found unreferenced by the previous sweep, and still unreferenced
+func (s *HealthCheckSweeper) sweep(ctx context.Context) {
+	collector, ok := s.dnsProvider.(HealthCheckCollector)
+	if !ok {
+		return
+	}
+
+	managed, err := collector.ManagedHealthChecks(ctx)
+	if err != nil {
+		s.logger.Error(err, "Failed to list the managed health checks")
+		healthCheckSweepErrors.Inc()
+		return
+	}
+	referenced, err := s.referencedHealthChecks()
+	if err != nil {
+		s.logger.Error(err, "Failed to list the referenced health checks")
+		healthCheckSweepErrors.Inc()
+		return
+	}
+
+	orphans := map[string]bool{}
+	for id, dnsName := range managed {
+		if referenced[id] || s.dnsZones.ForDNSName(dnsName) == nil {
+			continue
+		}
+		orphans[id] = true
+		if !s.orphans[id] {
+			s.logger.V(3).Info("Found orphaned health check", "id", id, "dnsName", dnsName)
+			continue
+		}
+		if s.dryRun {
+			s.logger.Info("Orphaned health check not deleted in dry run mode", "id", id, "dnsName", dnsName)
+			continue
+		}
+
+		endpoint := &v1.Endpoint{DNSName: dnsName}
+		endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, id)
+		if err := s.dnsProvider.DeleteHealthCheck(ctx, endpoint); err != nil {
+			s.logger.Error(err, "Failed to delete orphaned health check", "id", id, "dnsName", dnsName)
+			healthCheckSweepErrors.Inc()
+			continue
+		}
+		s.logger.Info("Deleted orphaned health check", "id", id, "dnsName", dnsName)
+		orphanedHealthChecksDeleted.Inc()
+		delete(orphans, id)
+	}
+
+	orphanedHealthChecks.Set(float64(len(orphans)))
+	s.orphans = orphans
+}
+
+// referencedHealthChecks returns the IDs of the health checks referenced by the endpoints of the DNSRecords and
+// HealthChecks of all the logical clusters, including the endpoints only reported in their status.
+func (s *HealthCheckSweeper) referencedHealthChecks() (map[string]bool, error) {
+	referenced := map[string]bool{}
+	for _, lister := range s.recordListers {
+		records, err := lister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list DNSRecords: %w", err)
+		}
+		for _, record := range records {
+			for _, endpoint := range record.Spec.Endpoints {
+				if id, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok {
+					referenced[id] = true
+				}
+			}
+			for _, zone := range record.Status.Zones {
+				for _, status := range zone.EndpointStatuses {
+					referenced[status.HealthCheckID] = true
+				}
+			}
+		}
+	}
+	for _, lister := range s.healthCheckListers {
+		healthChecks, err := lister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list HealthChecks: %w", err)
+		}
+		for _, healthCheck := range healthChecks {
+			for _, status := range healthCheck.Status.Endpoints {
+				referenced[status.HealthCheckID] = true
+			}
+		}
+	}
+	delete(referenced, "")
+	return referenced, nil
+}
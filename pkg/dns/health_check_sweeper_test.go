@@ -0,0 +1,163 @@
+package dns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kcp-dev/logicalcluster/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// fakeHealthCheckCollector lists the managed health checks, deleting them from the managed health checks
+type fakeHealthCheckCollector struct {
+	FakeProvider
+	managed map[string]string
+	deleted []string
+}
+
+func (f *fakeHealthCheckCollector) ManagedHealthChecks(_ context.Context) (map[string]string, error) {
+	managed := map[string]string{}
+	for id, dnsName := range f.managed {
+		managed[id] = dnsName
+	}
+	return managed, nil
+}
+
+func (f *fakeHealthCheckCollector) DeleteHealthCheck(_ context.Context, endpoint *v1.Endpoint) error {
+	id, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	delete(f.managed, id)
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func TestHealthCheckSweeper(t *testing.T) {
+	provider := &fakeHealthCheckCollector{managed: map[string]string{
+		"hc-1": "echo.example.com",
+		"hc-2": "echo.example.com",
+		"hc-3": "echo.example.com",
+		"hc-4": "orphan.example.com",
+		"hc-5": "echo.other.com",
+	}}
+
+	// The records of the logical clusters are listed from the informers of each APIExport
+	recordIndexers := []cache.Indexer{
+		cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	healthCheckIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	s := &HealthCheckSweeper{
+		logger:      logr.Discard(),
+		dnsProvider: provider,
+		dnsZones:    Zones{{DNSZone: v1.DNSZone{ID: "Z1"}, Domain: "example.com"}},
+		dryRun:      true,
+		orphans:     map[string]bool{},
+		recordListers: []kuadrantv1lister.DNSRecordLister{
+			kuadrantv1lister.NewDNSRecordLister(recordIndexers[0]),
+			kuadrantv1lister.NewDNSRecordLister(recordIndexers[1]),
+		},
+		healthCheckListers: []kuadrantv1lister.HealthCheckLister{kuadrantv1lister.NewHealthCheckLister(healthCheckIndexer)},
+	}
+
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1"}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-1")
+	first := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default", Annotations: map[string]string{logicalcluster.AnnotationKey: "root:a"}},
+		Spec:       v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{endpoint}},
+	}
+	// The health checks of the endpoints removed from the spec are still referenced by the status until deleted
+	second := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default", Annotations: map[string]string{logicalcluster.AnnotationKey: "root:b"}},
+		Status: v1.DNSRecordStatus{Zones: []v1.DNSZoneStatus{{
+			EndpointStatuses: []v1.EndpointStatus{{DNSName: "echo.example.com", HealthCheckID: "hc-2"}},
+		}}},
+	}
+	healthCheck := &v1.HealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Status: v1.HealthCheckStatus{Endpoints: []v1.HealthCheckEndpointStatus{
+			{DNSRecord: "echo", DNSName: "echo.example.com", HealthCheckID: "hc-3"},
+		}},
+	}
+	for indexer, obj := range map[cache.Indexer]interface{}{recordIndexers[0]: first, recordIndexers[1]: second, healthCheckIndexer: healthCheck} {
+		if err := indexer.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The orphaned health checks are only deleted once found unreferenced by two consecutive sweeps
+	s.sweep(context.TODO())
+	if len(provider.deleted) != 0 || len(s.orphans) != 1 || !s.orphans["hc-4"] {
+		t.Fatalf("expected hc-4 to be found orphaned without being deleted, got orphans %v and deleted %v", s.orphans, provider.deleted)
+	}
+	if orphans := testutil.ToFloat64(orphanedHealthChecks); orphans != 1 {
+		t.Fatalf("expected 1 orphaned health check to be reported, got %v", orphans)
+	}
+
+	// The orphaned health checks are not deleted in dry run mode
+	s.sweep(context.TODO())
+	if len(provider.deleted) != 0 {
+		t.Fatalf("expected no health check to be deleted in dry run mode, got %v", provider.deleted)
+	}
+
+	// A health check created since the last sweep is not deleted
+	provider.managed["hc-6"] = "new.example.com"
+	s.dryRun = false
+	deleted := testutil.ToFloat64(orphanedHealthChecksDeleted)
+	s.sweep(context.TODO())
+	if len(provider.deleted) != 1 || provider.deleted[0] != "hc-4" {
+		t.Fatalf("expected hc-4 to be deleted, got %v", provider.deleted)
+	}
+	if len(s.orphans) != 1 || !s.orphans["hc-6"] {
+		t.Fatalf("expected hc-6 to be found orphaned, got %v", s.orphans)
+	}
+	if count := testutil.ToFloat64(orphanedHealthChecksDeleted) - deleted; count != 1 {
+		t.Fatalf("expected 1 deleted health check to be reported, got %v", count)
+	}
+
+	// The health checks referenced again are no longer orphaned
+	endpoint = &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "c1"}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-6")
+	first = first.DeepCopy()
+	first.Spec.Endpoints = []*v1.Endpoint{endpoint}
+	if err := recordIndexers[0].Update(first); err != nil {
+		t.Fatal(err)
+	}
+	s.sweep(context.TODO())
+	if len(provider.deleted) != 1 || len(s.orphans) != 1 || !s.orphans["hc-1"] {
+		t.Fatalf("expected hc-6 not to be deleted and hc-1 to be found orphaned, got orphans %v and deleted %v", s.orphans, provider.deleted)
+	}
+}
+
+func TestHealthCheckSweeperWithoutOwnerID(t *testing.T) {
+	provider := &fakeHealthCheckCollector{managed: map[string]string{"hc-1": "echo.example.com"}}
+	s := &HealthCheckSweeper{
+		logger:      logr.Discard(),
+		dnsProvider: provider,
+		dnsZones:    Zones{{DNSZone: v1.DNSZone{ID: "Z1"}, Domain: "example.com"}},
+		interval:    time.Millisecond,
+		orphans:     map[string]bool{},
+	}
+
+	// The sweeper isn't started, as the health checks of the other instances can't be told apart
+	done := make(chan struct{})
+	go func() {
+		s.Start(context.Background(), 1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the sweeper not to be started without owner ID")
+	}
+	if len(s.orphans) != 0 || len(provider.deleted) != 0 {
+		t.Fatalf("expected no health check to be swept, got orphans %v and deleted %v", s.orphans, provider.deleted)
+	}
+}
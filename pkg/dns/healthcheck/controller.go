@@ -0,0 +1,183 @@
+package healthcheck
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clusters"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/kcp-dev/logicalcluster/v2"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	kuadrantv1 "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/clientset/versioned"
+	"github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/informers/externalversions"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	basereconciler "github.com/kuadrant/kcp-glbc/pkg/reconciler"
+)
+
+const (
+	defaultControllerName = "kcp-glbc-health-check"
+
+	// statusCheckInterval is the interval the health of the endpoints is refreshed at
+	statusCheckInterval = time.Minute
+)
+
+// NewController returns a new Controller which reconciles HealthCheck.
+func NewController(config *ControllerConfig) (*Controller, error) {
+	controllerName := config.GetName(defaultControllerName)
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), controllerName)
+	c := &Controller{
+		Controller:            basereconciler.NewController(controllerName, queue),
+		healthCheckClient:     config.HealthCheckClient,
+		sharedInformerFactory: config.SharedInformerFactory,
+	}
+	c.Process = c.process
+
+	dnsProvider, err := dns.DNSProvider(config.DNSProvider)
+	if err != nil {
+		return nil, err
+	}
+	c.dnsProvider = dnsProvider
+
+	// The health of the endpoints is refreshed periodically, so that the status updates aren't reconciled, as each
+	// reconciliation requests the provider
+	c.sharedInformerFactory.Kuadrant().V1().HealthChecks().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.Enqueue(obj) },
+		UpdateFunc: func(old, obj interface{}) {
+			oldHealthCheck, healthCheck := old.(*v1.HealthCheck), obj.(*v1.HealthCheck)
+			if oldHealthCheck.Generation != healthCheck.Generation || !oldHealthCheck.DeletionTimestamp.Equal(healthCheck.DeletionTimestamp) {
+				c.Enqueue(obj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.Enqueue(obj) },
+	})
+
+	// Reconcile the HealthChecks referenced by the endpoints of the DNSRecords, so that the health checks are
+	// created, updated and deleted along with the endpoints
+	c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueueReferencedHealthChecks(obj) },
+		UpdateFunc: func(old, obj interface{}) {
+			oldRecord, record := old.(*v1.DNSRecord), obj.(*v1.DNSRecord)
+			if !equality.Semantic.DeepEqual(healthCheckedEndpoints(oldRecord), healthCheckedEndpoints(record)) || !oldRecord.DeletionTimestamp.Equal(record.DeletionTimestamp) {
+				c.enqueueReferencedHealthChecks(old)
+				c.enqueueReferencedHealthChecks(obj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.enqueueReferencedHealthChecks(obj) },
+	})
+
+	c.indexer = c.sharedInformerFactory.Kuadrant().V1().HealthChecks().Informer().GetIndexer()
+	c.dnsRecordLister = c.sharedInformerFactory.Kuadrant().V1().DNSRecords().Lister()
+
+	return c, nil
+}
+
+type ControllerConfig struct {
+	*basereconciler.ControllerConfig
+	HealthCheckClient     kuadrantv1.ClusterInterface
+	SharedInformerFactory externalversions.SharedInformerFactory
+	DNSProvider           string
+}
+
+type Controller struct {
+	*basereconciler.Controller
+	sharedInformerFactory externalversions.SharedInformerFactory
+	healthCheckClient     kuadrantv1.ClusterInterface
+	indexer               cache.Indexer
+	dnsRecordLister       kuadrantv1lister.DNSRecordLister
+	dnsProvider           dns.Provider
+}
+
+func (c *Controller) process(ctx context.Context, key string) error {
+	object, exists, err := c.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		c.Logger.Info("HealthCheck was deleted", "key", key)
+		return nil
+	}
+
+	previous := object.(*v1.HealthCheck)
+	current := previous.DeepCopy()
+
+	// The status is updated even if the reconciliation failed, so that the IDs of the health checks created by the
+	// provider are not lost
+	reconcileErr := c.reconcile(ctx, current)
+
+	if !equality.Semantic.DeepEqual(previous.Status, current.Status) {
+		refresh, err := c.healthCheckClient.Cluster(logicalcluster.From(current)).KuadrantV1().HealthChecks(current.Namespace).UpdateStatus(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		current.ObjectMeta.ResourceVersion = refresh.ObjectMeta.ResourceVersion
+	}
+
+	if !equality.Semantic.DeepEqual(previous, current) {
+		_, err := c.healthCheckClient.Cluster(logicalcluster.From(current)).KuadrantV1().HealthChecks(current.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	if reconcileErr != nil {
+		return reconcileErr
+	}
+
+	// Refresh the health of the endpoints periodically
+	if _, ok := c.dnsProvider.(dns.HealthCheckStatusReporter); ok && len(current.Status.Endpoints) > 0 && current.DeletionTimestamp == nil {
+		c.Queue.AddAfter(key, statusCheckInterval)
+	}
+
+	return nil
+}
+
+func (c *Controller) enqueueReferencedHealthChecks(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	record, ok := obj.(*v1.DNSRecord)
+	if !ok {
+		return
+	}
+
+	for _, name := range referencedHealthChecks(record) {
+		c.Queue.Add(record.Namespace + "/" + clusters.ToClusterAwareKey(logicalcluster.From(record), name))
+	}
+}
+
+// healthCheckedEndpoints returns the endpoints of record referencing a HealthCheck, without the ID of their health
+// check, which is set from the HealthCheck status
+func healthCheckedEndpoints(record *v1.DNSRecord) []*v1.Endpoint {
+	var endpoints []*v1.Endpoint
+	for _, endpoint := range record.Spec.Endpoints {
+		if endpoint == nil || endpoint.HealthCheckRef == nil {
+			continue
+		}
+		endpoint = endpoint.DeepCopy()
+		endpoint.DeleteProviderSpecific(aws.ProviderSpecificHealthCheckID)
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// referencedHealthChecks returns the names of the HealthChecks referenced by the endpoints of record
+func referencedHealthChecks(record *v1.DNSRecord) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, endpoint := range record.Spec.Endpoints {
+		if endpoint == nil || endpoint.HealthCheckRef == nil || seen[endpoint.HealthCheckRef.Name] {
+			continue
+		}
+		seen[endpoint.HealthCheckRef.Name] = true
+		names = append(names, endpoint.HealthCheckRef.Name)
+	}
+	return names
+}
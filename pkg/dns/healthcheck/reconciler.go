@@ -0,0 +1,216 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/kcp-dev/logicalcluster/v2"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/metadata"
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/slice"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const HealthCheckFinalizer = "kuadrant.dev/health-check"
+
+func (c *Controller) reconcile(ctx context.Context, healthCheck *v1.HealthCheck) error {
+	c.Logger.V(3).Info("starting reconcile of healthCheck ", "name", healthCheck.Name, "namespace", healthCheck.Namespace, "cluster", logicalcluster.From(healthCheck))
+	// If the HealthCheck was deleted, delete the health checks of the endpoints and return.
+	if healthCheck.DeletionTimestamp != nil && !healthCheck.DeletionTimestamp.IsZero() {
+		var remaining []v1.HealthCheckEndpointStatus
+		var errs []error
+		for _, status := range healthCheck.Status.Endpoints {
+			if err := c.deleteEndpointHealthCheck(ctx, status); err != nil {
+				errs = append(errs, err)
+				remaining = append(remaining, status)
+			}
+		}
+		healthCheck.Status.Endpoints = remaining
+
+		if len(errs) > 0 {
+			return utilerrors.NewAggregate(errs)
+		}
+		metadata.RemoveFinalizer(healthCheck, HealthCheckFinalizer)
+
+		return nil
+	}
+
+	if !slice.ContainsString(healthCheck.Finalizers, HealthCheckFinalizer) {
+		healthCheck.Finalizers = append(healthCheck.Finalizers, HealthCheckFinalizer)
+	}
+
+	records, err := c.dnsRecordLister.DNSRecords(healthCheck.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]v1.HealthCheckEndpointStatus{}
+	for _, status := range healthCheck.Status.Endpoints {
+		previous[endpointStatusKey(status.DNSRecord, status.DNSName, status.SetIdentifier)] = status
+	}
+
+	// The health checks of an invalid spec are left as is, until it is fixed
+	specErr := validateSpec(healthCheck.Spec)
+
+	var statuses []v1.HealthCheckEndpointStatus
+	var errs []error
+	for _, record := range records {
+		if logicalcluster.From(record) != logicalcluster.From(healthCheck) || record.DeletionTimestamp != nil {
+			continue
+		}
+		for _, endpoint := range record.Spec.Endpoints {
+			if endpoint == nil || endpoint.HealthCheckRef == nil || endpoint.HealthCheckRef.Name != healthCheck.Name {
+				continue
+			}
+			// Other records, e.g. geo CNAMEs, point to the A and AAAA records that are health checked
+			if endpoint.RecordType != string(v1.ARecordType) && endpoint.RecordType != string(v1.AAAARecordType) {
+				continue
+			}
+			address, ok := endpoint.GetAddress()
+			if !ok {
+				continue
+			}
+
+			key := endpointStatusKey(record.Name, endpoint.DNSName, endpoint.SetIdentifier)
+			status := v1.HealthCheckEndpointStatus{
+				DNSRecord:     record.Name,
+				DNSName:       endpoint.DNSName,
+				SetIdentifier: endpoint.SetIdentifier,
+				Address:       address,
+				Health:        v1.EndpointUnknown,
+			}
+
+			// The provider reads the ID of the existing health check from the endpoint
+			endpoint = endpoint.DeepCopy()
+			if previousStatus, ok := previous[key]; ok {
+				delete(previous, key)
+				if previousStatus.HealthCheckID != "" {
+					endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, previousStatus.HealthCheckID)
+				}
+			}
+
+			if specErr != nil {
+				status.Message = fmt.Sprintf("Invalid health check: %v", specErr)
+			} else if err := c.reconcileEndpointHealthCheck(ctx, healthCheck, record, endpoint); err != nil {
+				c.Logger.Error(err, "Failed to reconcile health check for endpoint", "healthCheck", healthCheck.Name, "record", record.Name, "endpoint", endpoint.SetID())
+				status.Message = fmt.Sprintf("The DNS provider failed to reconcile the health check: %v", err)
+				errs = append(errs, err)
+			}
+			status.HealthCheckID, _ = endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+
+			if status.Message == "" {
+				status.Health, status.Message = c.endpointHealth(ctx, endpoint)
+			}
+
+			statuses = append(statuses, status)
+		}
+	}
+
+	// Delete the health checks of the endpoints that no longer reference the HealthCheck
+	for _, status := range previous {
+		if err := c.deleteEndpointHealthCheck(ctx, status); err != nil {
+			c.Logger.Error(err, "Failed to delete health check for endpoint", "healthCheck", healthCheck.Name, "record", status.DNSRecord, "id", status.HealthCheckID)
+			errs = append(errs, err)
+			// Keep the status, so that the deletion is retried
+			status.Message = fmt.Sprintf("The DNS provider failed to delete the health check: %v", err)
+			statuses = append(statuses, status)
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return endpointStatusKey(statuses[i].DNSRecord, statuses[i].DNSName, statuses[i].SetIdentifier) <
+			endpointStatusKey(statuses[j].DNSRecord, statuses[j].DNSName, statuses[j].SetIdentifier)
+	})
+	healthCheck.Status.Endpoints = statuses
+	healthCheck.Status.ObservedGeneration = healthCheck.Generation
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateSpec validates the constraints of the spec the HealthCheck schema can't express
+func validateSpec(spec v1.HealthCheckSpec) error {
+	if spec.Protocol != nil && *spec.Protocol == v1.HealthCheckProtocolTCP {
+		if spec.SearchString != "" {
+			return errors.New("search string is not supported by TCP health checks")
+		}
+		return nil
+	}
+	if spec.Path == "" {
+		return errors.New("path is required by HTTP and HTTPS health checks")
+	}
+	return nil
+}
+
+func (c *Controller) reconcileEndpointHealthCheck(ctx context.Context, healthCheck *v1.HealthCheck, record *v1.DNSRecord, endpoint *v1.Endpoint) error {
+	id, err := idForEndpoint(healthCheck, record, endpoint)
+	if err != nil {
+		return err
+	}
+
+	spec := v1.EndpointHealthCheck{
+		Id:              id,
+		Name:            fmt.Sprintf("%s-%s", endpoint.DNSName, endpoint.SetIdentifier),
+		HealthCheckSpec: healthCheck.Spec,
+	}
+
+	c.Logger.V(3).Info("Reconciling health check for endpoint", "healthCheck", healthCheck.Name, "name", endpoint.DNSName, "identifier", endpoint.SetIdentifier)
+
+	return c.dnsProvider.ReconcileHealthCheck(ctx, spec, endpoint)
+}
+
+// endpointHealth returns the health of the endpoint reported by the provider, and a message if it can't be reported
+func (c *Controller) endpointHealth(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, string) {
+	reporter, ok := c.dnsProvider.(dns.HealthCheckStatusReporter)
+	if !ok {
+		return v1.EndpointUnknown, ""
+	}
+	if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); !ok {
+		return v1.EndpointUnknown, ""
+	}
+
+	health, err := reporter.HealthCheckStatus(ctx, endpoint)
+	if err != nil {
+		return v1.EndpointUnknown, fmt.Sprintf("The DNS provider failed to report the health of the endpoint: %v", err)
+	}
+	return health, ""
+}
+
+func (c *Controller) deleteEndpointHealthCheck(ctx context.Context, status v1.HealthCheckEndpointStatus) error {
+	if status.HealthCheckID == "" {
+		return nil
+	}
+
+	endpoint := &v1.Endpoint{
+		DNSName:       status.DNSName,
+		SetIdentifier: status.SetIdentifier,
+		RecordType:    string(v1.ARecordType),
+		Targets:       v1.Targets{status.Address},
+	}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, status.HealthCheckID)
+
+	c.Logger.Info("Deleting health check for endpoint", "record", status.DNSRecord, "name", status.DNSName, "identifier", status.SetIdentifier)
+
+	return c.dnsProvider.DeleteHealthCheck(ctx, endpoint)
+}
+
+func endpointStatusKey(record, dnsName, setIdentifier string) string {
+	return fmt.Sprintf("%s/%s@%s", record, setIdentifier, dnsName)
+}
+
+// idForEndpoint returns a unique identifier for the health check of an endpoint
+func idForEndpoint(healthCheck *v1.HealthCheck, record *v1.DNSRecord, endpoint *v1.Endpoint) (string, error) {
+	hash := md5.New()
+	if _, err := io.WriteString(hash, fmt.Sprintf("%s|%s/%s/%s", logicalcluster.From(healthCheck), healthCheck.Namespace, healthCheck.Name,
+		endpointStatusKey(record.Name, endpoint.DNSName, endpoint.SetIdentifier))); err != nil {
+		return "", fmt.Errorf("unexpected error creating ID for endpoint %s", endpoint.SetIdentifier)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,207 @@
+package healthcheck
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	kuadrantv1lister "github.com/kuadrant/kcp-glbc/pkg/client/kuadrant/listers/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
+)
+
+// fakeHealthCheckProvider creates a health check per endpoint address, reported healthy
+type fakeHealthCheckProvider struct {
+	dns.FakeProvider
+	healthChecks map[string]v1.EndpointHealthCheck
+}
+
+func (f *fakeHealthCheckProvider) ReconcileHealthCheck(_ context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
+	id, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	if !ok {
+		id = "hc-" + endpoint.Targets[0]
+		endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, id)
+	}
+	f.healthChecks[id] = hc
+	return nil
+}
+
+func (f *fakeHealthCheckProvider) DeleteHealthCheck(_ context.Context, endpoint *v1.Endpoint) error {
+	id, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	delete(f.healthChecks, id)
+	return nil
+}
+
+func (f *fakeHealthCheckProvider) HealthCheckStatus(_ context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	return v1.EndpointHealthy, nil
+}
+
+func TestReconcile(t *testing.T) {
+	provider := &fakeHealthCheckProvider{healthChecks: map[string]v1.EndpointHealthCheck{}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	c := &Controller{
+		Controller:      &reconciler.Controller{Logger: logr.Discard()},
+		dnsRecordLister: kuadrantv1lister.NewDNSRecordLister(indexer),
+		dnsProvider:     provider,
+	}
+
+	healthCheck := &v1.HealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default", Generation: 1},
+		Spec:       v1.HealthCheckSpec{Path: "/healthz"},
+	}
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{
+			{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "cluster1", Targets: v1.Targets{"192.168.0.1"}, HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+			{DNSName: "echo.example.com", RecordType: "AAAA", SetIdentifier: "cluster2", Targets: v1.Targets{"2001:db8::2"}, HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+			{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "cluster3", Targets: v1.Targets{"192.168.0.3"}, HealthCheckRef: &v1.HealthCheckReference{Name: "other"}},
+			{DNSName: "other.example.com", RecordType: "CNAME", Targets: v1.Targets{"echo.example.com"}, HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+		}},
+	}
+	if err := indexer.Add(record); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.reconcile(context.TODO(), healthCheck); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(healthCheck.Finalizers) != 1 || healthCheck.Finalizers[0] != HealthCheckFinalizer {
+		t.Fatalf("expected the finalizer to be added, got %v", healthCheck.Finalizers)
+	}
+	if healthCheck.Status.ObservedGeneration != 1 {
+		t.Fatalf("expected the generation to be observed, got %d", healthCheck.Status.ObservedGeneration)
+	}
+	endpoints := healthCheck.Status.Endpoints
+	if len(endpoints) != 2 || endpoints[0].SetIdentifier != "cluster1" || endpoints[1].SetIdentifier != "cluster2" {
+		t.Fatalf("expected the health checks of the referencing A and AAAA endpoints, got %v", endpoints)
+	}
+	if endpoints[0].HealthCheckID != "hc-192.168.0.1" || endpoints[0].Health != v1.EndpointHealthy || endpoints[0].Address != "192.168.0.1" {
+		t.Fatalf("unexpected endpoint status %v", endpoints[0])
+	}
+	if len(provider.healthChecks) != 2 || provider.healthChecks["hc-192.168.0.1"].Path != "/healthz" {
+		t.Fatalf("expected the health checks to be reconciled with the spec, got %v", provider.healthChecks)
+	}
+	if record.Spec.Endpoints[0].ProviderSpecific != nil {
+		t.Fatalf("expected the DNSRecord from the lister not to be modified")
+	}
+
+	// The existing health checks are updated with the spec
+	healthCheck.Spec.Path = "/ready"
+	if err := c.reconcile(context.TODO(), healthCheck); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(provider.healthChecks) != 2 || provider.healthChecks["hc-2001:db8::2"].Path != "/ready" {
+		t.Fatalf("expected the health checks to be updated, got %v", provider.healthChecks)
+	}
+
+	// The health check of an endpoint no longer referencing the HealthCheck is deleted
+	updated := record.DeepCopy()
+	updated.Spec.Endpoints[1].HealthCheckRef = nil
+	if err := indexer.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reconcile(context.TODO(), healthCheck); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(healthCheck.Status.Endpoints) != 1 || len(provider.healthChecks) != 1 {
+		t.Fatalf("expected the health check of cluster2 to be deleted, got %v", healthCheck.Status.Endpoints)
+	}
+
+	// The health checks are deleted along with the HealthCheck
+	now := metav1.NewTime(time.Now())
+	healthCheck.DeletionTimestamp = &now
+	if err := c.reconcile(context.TODO(), healthCheck); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(provider.healthChecks) != 0 || len(healthCheck.Status.Endpoints) != 0 || len(healthCheck.Finalizers) != 0 {
+		t.Fatalf("expected the health checks and the finalizer to be removed, got %v", healthCheck)
+	}
+}
+
+func TestReconcileInvalidSpec(t *testing.T) {
+	provider := &fakeHealthCheckProvider{healthChecks: map[string]v1.EndpointHealthCheck{}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	c := &Controller{
+		Controller:      &reconciler.Controller{Logger: logr.Discard()},
+		dnsRecordLister: kuadrantv1lister.NewDNSRecordLister(indexer),
+		dnsProvider:     provider,
+	}
+
+	healthCheck := &v1.HealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default", Generation: 1},
+	}
+	record := &v1.DNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "echo", Namespace: "default"},
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{
+			{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "cluster1", Targets: v1.Targets{"192.168.0.1"}, HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+		}},
+	}
+	if err := indexer.Add(record); err != nil {
+		t.Fatal(err)
+	}
+
+	// The HTTP health checks require a path
+	if err := c.reconcile(context.TODO(), healthCheck); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(provider.healthChecks) != 0 || len(healthCheck.Status.Endpoints) != 1 || !strings.HasPrefix(healthCheck.Status.Endpoints[0].Message, "Invalid health check") {
+		t.Fatalf("expected the endpoint not to be health checked, got %v", healthCheck.Status.Endpoints)
+	}
+
+	// The TCP health checks don't
+	tcp := v1.HealthCheckProtocolTCP
+	healthCheck.Spec.Protocol = &tcp
+	if err := c.reconcile(context.TODO(), healthCheck); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(provider.healthChecks) != 1 || healthCheck.Status.Endpoints[0].Health != v1.EndpointHealthy {
+		t.Fatalf("expected the endpoint to be health checked, got %v", healthCheck.Status.Endpoints)
+	}
+}
+
+func TestReferencedHealthChecks(t *testing.T) {
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{
+			{DNSName: "a.example.com", HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+			{DNSName: "b.example.com", HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+			{DNSName: "c.example.com", HealthCheckRef: &v1.HealthCheckReference{Name: "other"}},
+			{DNSName: "d.example.com"},
+		}},
+	}
+	names := referencedHealthChecks(record)
+	if len(names) != 2 || names[0] != "echo" || names[1] != "other" {
+		t.Fatalf("expected echo and other, got %v", names)
+	}
+}
+
+func TestHealthCheckedEndpoints(t *testing.T) {
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{
+			{DNSName: "echo.example.com", SetIdentifier: "c1", Targets: v1.Targets{"192.168.0.1"}, HealthCheckRef: &v1.HealthCheckReference{Name: "echo"}},
+			{DNSName: "other.example.com", Targets: v1.Targets{"192.168.0.2"}},
+		}},
+	}
+	updated := record.DeepCopy()
+
+	// The health check IDs are set from the HealthCheck status
+	updated.Spec.Endpoints[0].SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "hc-1")
+	updated.Spec.Endpoints[1].Targets = v1.Targets{"192.168.0.3"}
+	updated.Status.Zones = []v1.DNSZoneStatus{{DNSZone: v1.DNSZone{ID: "Z1"}}}
+	if !equality.Semantic.DeepEqual(healthCheckedEndpoints(record), healthCheckedEndpoints(updated)) {
+		t.Fatalf("expected the health checked endpoints to be unchanged")
+	}
+
+	updated.Spec.Endpoints[0].Targets = v1.Targets{"192.168.0.4"}
+	if equality.Semantic.DeepEqual(healthCheckedEndpoints(record), healthCheckedEndpoints(updated)) {
+		t.Fatalf("expected the health checked endpoints to be changed")
+	}
+}
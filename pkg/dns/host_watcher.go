@@ -38,11 +38,17 @@ func (w *HostsWatcher) ListHostRecordWatchers(obj interface{}) []RecordWatcher {
 	return recordWatchers
 }
 
-// StartWatching begins tracking changes in the addresses for host
-func (w *HostsWatcher) StartWatching(ctx context.Context, obj interface{}, host string) bool {
+// StartWatching begins tracking changes in the addresses for host. The addresses are refreshed at least twice per
+// recordTTL, the TTL of the records the addresses are published to, when it is set, so that the records are updated
+// before they expire from the caches. The watcher is restarted when recordTTL changes.
+func (w *HostsWatcher) StartWatching(ctx context.Context, obj interface{}, host string, recordTTL time.Duration) bool {
 	for _, recordWatcher := range w.Records {
 		if recordWatcher.key == obj && recordWatcher.Host == host {
-			return false
+			if recordWatcher.recordTTL == recordTTL {
+				return false
+			}
+			w.StopWatching(obj, host)
+			break
 		}
 	}
 
@@ -57,6 +63,7 @@ func (w *HostsWatcher) StartWatching(ctx context.Context, obj interface{}, host
 		onChange:      w.OnChange,
 		records:       []HostAddress{},
 		watchInterval: w.WatchInterval,
+		recordTTL:     recordTTL,
 		errInterval:   errorInterval,
 	}
 	recordWatcher.watch(c)
@@ -87,6 +94,7 @@ type RecordWatcher struct {
 	key           interface{}
 	onChange      func(key interface{})
 	watchInterval func(ttl time.Duration) time.Duration
+	recordTTL     time.Duration
 	errInterval   time.Duration
 	Host          string
 	records       []HostAddress
@@ -126,13 +134,23 @@ func (w *RecordWatcher) watch(ctx context.Context) {
 			}
 
 			ttl := w.records[0].TTL
-			refreshInterval := w.watchInterval(ttl)
+			refreshInterval := w.refreshInterval(ttl)
 			time.Sleep(refreshInterval)
 			w.logger.V(3).Info("Refreshing records for host", "TTL", int(ttl.Seconds()), "interval", int(refreshInterval.Seconds()))
 		}
 	}()
 }
 
+// refreshInterval returns the watch interval for the TTL of the host addresses, bounded by half the TTL of the
+// records they are published to
+func (w *RecordWatcher) refreshInterval(ttl time.Duration) time.Duration {
+	interval := w.watchInterval(ttl)
+	if w.recordTTL > 0 && interval > w.recordTTL/2 {
+		return w.recordTTL / 2
+	}
+	return interval
+}
+
 func (w *RecordWatcher) updateRecords(newRecords []HostAddress) bool {
 	if len(w.records) != len(newRecords) {
 		w.records = newRecords
@@ -108,25 +108,35 @@ func (r *ConfigMapHostResolver) TxtRecordExists(ctx context.Context, domain stri
 
 type DefaultHostResolver struct {
 	Client dns.Client
+	// Servers are the addresses, as host:port, of the nameservers to query.
+	// Defaults to the nameservers configured in /etc/resolv.conf.
+	Servers []string
 }
 
-func NewDefaultHostResolver() *DefaultHostResolver {
+func NewDefaultHostResolver(servers ...string) *DefaultHostResolver {
 	return &DefaultHostResolver{
-		Client: dns.Client{},
+		Client:  dns.Client{},
+		Servers: servers,
 	}
 }
 
 func (hr *DefaultHostResolver) LookupIPAddr(ctx context.Context, host string) ([]HostAddress, error) {
-	cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
-	if err != nil {
-		return nil, err
+	servers := hr.Servers
+	if len(servers) == 0 {
+		cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range cfg.Servers {
+			servers = append(servers, gonet.JoinHostPort(server, cfg.Port))
+		}
 	}
 
-	for _, server := range cfg.Servers {
+	for _, server := range servers {
 		m := dns.Msg{}
 		m.SetQuestion(fmt.Sprintf("%s.", host), dns.TypeA)
 
-		r, _, err := hr.Client.ExchangeContext(ctx, &m, fmt.Sprintf("%s:53", server))
+		r, _, err := hr.Client.ExchangeContext(ctx, &m, server)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,245 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/prober"
+)
+
+const (
+	ListenAddressEnvVar = "GLBC_INMEMORY_DNS_ADDRESS"
+
+	DefaultListenAddress = "127.0.0.1:1053"
+)
+
+// Provider stores records in memory, per zone, and serves them through an
+// embedded authoritative DNS server.
+//
+// The DNSZone.ID is the apex domain of the zone. Endpoints are stored as
+// record sets identified by their name, type and set identifier, so weighted
+// endpoints are answered as Route53 would, one weighted record set at a time.
+type Provider struct {
+	mu     sync.RWMutex
+	zones  map[string]map[recordSetKey]*v1.Endpoint
+	server *Server
+	prober *prober.Prober
+	logger logr.Logger
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	// ListenAddress is the UDP and TCP address the embedded server listens on.
+	// The embedded server is not started when empty.
+	ListenAddress string
+	// Prober probes the health of the endpoints, as the provider has no health checks. The endpoints aren't health
+	// checked when nil.
+	Prober *prober.Prober
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	p := &Provider{
+		zones:  map[string]map[recordSetKey]*v1.Endpoint{},
+		prober: config.Prober,
+		logger: log.Logger.WithName("inmemory-dns"),
+	}
+
+	if config.ListenAddress != "" {
+		server, err := NewServer(config.ListenAddress, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start embedded DNS server: %v", err)
+		}
+		p.server = server
+		p.logger.Info("Serving DNS records", "address", server.Address())
+	}
+
+	return p, nil
+}
+
+// Server returns the embedded DNS server, or nil if it is not started.
+func (p *Provider) Server() *Server {
+	return p.server
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	desired, err := recordSetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+	// Ignore previously published endpoints that can't be stored anyway
+	published, _ := recordSetsForEndpoints(record.Status.ZoneEndpoints(zone))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recordSets := p.zone(zone.ID)
+	for key := range published {
+		if _, found := desired[key]; !found {
+			delete(recordSets, key)
+		}
+	}
+	for key, endpoint := range desired {
+		recordSets[key] = endpoint
+	}
+
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	published, err := recordSetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recordSets := p.zone(zone.ID)
+	for key := range published {
+		delete(recordSets, key)
+	}
+
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// Drift compares the record sets of the record endpoints with the record
+// sets stored in the zone.
+func (p *Provider) Drift(record *v1.DNSRecord, zone v1.DNSZone) ([]string, error) {
+	desired, err := recordSetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var drift []string
+	for key, endpoint := range desired {
+		stored, found := p.zones[zone.ID][key]
+		switch {
+		case !found:
+			drift = append(drift, fmt.Sprintf("record set %s is missing", key))
+		case !reflect.DeepEqual(stored, endpoint):
+			drift = append(drift, fmt.Sprintf("record set %s has been modified", key))
+		}
+	}
+	sort.Strings(drift)
+	return drift, nil
+}
+
+// ReconcileHealthCheck probes the endpoint with the prober, if any: the in-memory provider has no health checks.
+func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
+	return p.prober.ReconcileHealthCheck(ctx, hc, endpoint)
+}
+
+func (p *Provider) DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error {
+	return p.prober.DeleteHealthCheck(ctx, endpoint)
+}
+
+func (p *Provider) HealthCheckStatus(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	return p.prober.HealthCheckStatus(ctx, endpoint)
+}
+
+func (p *Provider) EndpointHealth(endpoint *v1.Endpoint) v1.EndpointHealth {
+	return p.prober.EndpointHealth(endpoint)
+}
+
+func (p *Provider) OnHealthChange(listener func(id string)) {
+	p.prober.OnHealthChange(listener)
+}
+
+// Endpoints returns a copy of the endpoints stored for a name and type in the
+// zone, ordered by set identifier.
+func (p *Provider) Endpoints(zoneID, dnsName, recordType string) []*v1.Endpoint {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var endpoints []*v1.Endpoint
+	for key, endpoint := range p.zones[zoneID] {
+		if key.name == normalizeName(dnsName) && key.recordType == recordType {
+			endpoints = append(endpoints, endpoint.DeepCopy())
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].SetID() < endpoints[j].SetID()
+	})
+	return endpoints
+}
+
+// findZone returns the ID of the zone with the longest apex containing name.
+func (p *Provider) findZone(name string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	name = normalizeName(name)
+	found := ""
+	for zoneID := range p.zones {
+		apex := normalizeName(zoneID)
+		if (name == apex || strings.HasSuffix(name, "."+apex)) && len(apex) > len(normalizeName(found)) {
+			found = zoneID
+		}
+	}
+	return found, found != ""
+}
+
+// zone returns the record sets of the zone, creating them if needed. Must be
+// called with the lock held.
+func (p *Provider) zone(zoneID string) map[recordSetKey]*v1.Endpoint {
+	recordSets, ok := p.zones[zoneID]
+	if !ok {
+		recordSets = map[recordSetKey]*v1.Endpoint{}
+		p.zones[zoneID] = recordSets
+	}
+	return recordSets
+}
+
+type recordSetKey struct {
+	name          string
+	recordType    string
+	setIdentifier string
+}
+
+func (k recordSetKey) String() string {
+	if k.setIdentifier == "" {
+		return fmt.Sprintf("%s %s", k.recordType, k.name)
+	}
+	return fmt.Sprintf("%s %s (%s)", k.recordType, k.name, k.setIdentifier)
+}
+
+func recordSetsForEndpoints(endpoints []*v1.Endpoint) (map[recordSetKey]*v1.Endpoint, error) {
+	result := make(map[recordSetKey]*v1.Endpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return nil, fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required")
+		}
+		switch endpoint.RecordType {
+		case string(v1.ARecordType), string(v1.AAAARecordType), string(v1.CNAMERecordType):
+		default:
+			return nil, fmt.Errorf("unsupported record type %s", endpoint.RecordType)
+		}
+		key := recordSetKey{
+			name:          normalizeName(endpoint.DNSName),
+			recordType:    endpoint.RecordType,
+			setIdentifier: endpoint.SetIdentifier,
+		}
+		result[key] = endpoint.DeepCopy()
+	}
+	return result, nil
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
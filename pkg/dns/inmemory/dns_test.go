@@ -0,0 +1,132 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	glbcdns "github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/inmemory"
+)
+
+func weightedEndpoint(dnsName, target, weight string) *v1.Endpoint {
+	endpoint := &v1.Endpoint{
+		DNSName:       dnsName,
+		Targets:       v1.Targets{target},
+		RecordType:    string(v1.ARecordType),
+		SetIdentifier: target,
+		RecordTTL:     60,
+	}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificWeight, weight)
+	return endpoint
+}
+
+func exchange(t *testing.T, address, name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	r, _, err := new(dns.Client).Exchange(m, address)
+	if err != nil {
+		t.Fatalf("unexpected error querying %s: %v", name, err)
+	}
+	return r
+}
+
+func TestProvider(t *testing.T) {
+	provider, err := inmemory.NewProvider(inmemory.Config{ListenAddress: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	defer func() { _ = provider.Server().Shutdown() }()
+	address := provider.Server().Address()
+
+	zone := v1.DNSZone{ID: "example.com"}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				weightedEndpoint("test.example.com", "192.168.0.1", "120"),
+				weightedEndpoint("test.example.com", "192.168.0.2", "0"),
+				{DNSName: "www.example.com", RecordType: "CNAME", Targets: v1.Targets{"test.example.com"}, RecordTTL: 30},
+				{DNSName: "ipv6.example.com", RecordType: "AAAA", Targets: v1.Targets{"2001:db8::1"}, RecordTTL: 30},
+			},
+		},
+	}
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if endpoints := provider.Endpoints("example.com", "test.example.com", "A"); len(endpoints) != 2 {
+		t.Fatalf("expected 2 record sets, got %d", len(endpoints))
+	}
+
+	// The record set with a weight of 0 is never answered
+	for i := 0; i < 10; i++ {
+		r := exchange(t, address, "test.example.com", dns.TypeA)
+		if !r.Authoritative || len(r.Answer) != 1 || r.Answer[0].(*dns.A).A.String() != "192.168.0.1" {
+			t.Fatalf("unexpected answer %v", r)
+		}
+	}
+
+	// CNAMEs are followed within the zone
+	r := exchange(t, address, "www.example.com", dns.TypeA)
+	if len(r.Answer) != 2 || r.Answer[0].Header().Rrtype != dns.TypeCNAME || r.Answer[1].Header().Rrtype != dns.TypeA {
+		t.Fatalf("unexpected answer %v", r)
+	}
+
+	// AAAA queries are answered with the IPv6 record sets
+	r = exchange(t, address, "ipv6.example.com", dns.TypeAAAA)
+	if len(r.Answer) != 1 || r.Answer[0].(*dns.AAAA).AAAA.String() != "2001:db8::1" {
+		t.Fatalf("unexpected answer %v", r)
+	}
+
+	if r := exchange(t, address, "missing.example.com", dns.TypeA); r.Rcode != dns.RcodeNameError || len(r.Ns) != 1 {
+		t.Fatalf("expected NXDOMAIN with SOA, got %v", r)
+	}
+	if r := exchange(t, address, "example.com", dns.TypeNS); len(r.Answer) != 1 {
+		t.Fatalf("expected NS record, got %v", r)
+	}
+	if r := exchange(t, address, "example.org", dns.TypeA); r.Rcode != dns.RcodeRefused {
+		t.Fatalf("expected queries outside of the zones to be refused, got %v", r)
+	}
+
+	// The host resolver can be pointed at the embedded server
+	resolver := glbcdns.NewDefaultHostResolver(address)
+	addresses, err := resolver.LookupIPAddr(context.TODO(), "test.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error resolving host: %v", err)
+	}
+	if len(addresses) != 1 || addresses[0].IP.String() != "192.168.0.1" {
+		t.Fatalf("unexpected addresses %v", addresses)
+	}
+
+	// Stale endpoints recorded in the zone status are removed
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = record.Spec.Endpoints[:1]
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if endpoints := provider.Endpoints("example.com", "www.example.com", "CNAME"); len(endpoints) != 0 {
+		t.Fatalf("expected stale CNAME to be removed, got %v", endpoints)
+	}
+	if endpoints := provider.Endpoints("example.com", "test.example.com", "A"); len(endpoints) != 1 {
+		t.Fatalf("expected stale weighted record set to be removed, got %v", endpoints)
+	}
+
+	if drift, err := provider.Drift(record, zone); err != nil || len(drift) != 0 {
+		t.Fatalf("expected no drift, got %v, %v", drift, err)
+	}
+
+	if err := provider.Delete(record, zone); err != nil {
+		t.Fatalf("unexpected error deleting record: %v", err)
+	}
+	if drift, err := provider.Drift(record, zone); err != nil || len(drift) != 1 {
+		t.Fatalf("expected the deleted record set to be reported as drifted, got %v, %v", drift, err)
+	}
+	if endpoints := provider.Endpoints("example.com", "test.example.com", "A"); len(endpoints) != 0 {
+		t.Fatalf("expected record sets to be deleted, got %v", endpoints)
+	}
+	if r := exchange(t, address, "test.example.com", dns.TypeA); r.Rcode != dns.RcodeNameError {
+		t.Fatalf("expected NXDOMAIN once deleted, got %v", r)
+	}
+}
@@ -0,0 +1,270 @@
+package inmemory
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+
+	"github.com/miekg/dns"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const (
+	// maxCNAMEChain bounds how many CNAMEs are followed within the stored zones.
+	maxCNAMEChain = 8
+
+	soaTTL = 300
+)
+
+// Server is an authoritative DNS server answering queries for the zones
+// stored in a Provider. Queries for names outside of these zones are refused.
+type Server struct {
+	provider *Provider
+	udp      *dns.Server
+	tcp      *dns.Server
+}
+
+// NewServer starts serving the provider's zones over UDP and TCP on address.
+// The TCP listener uses the same port as the UDP one, so port 0 can be used
+// to pick a free port.
+func NewServer(address string, provider *Provider) (*Server, error) {
+	packetConn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		_ = packetConn.Close()
+		return nil, err
+	}
+
+	s := &Server{provider: provider}
+	s.udp = &dns.Server{PacketConn: packetConn, Handler: s}
+	s.tcp = &dns.Server{Listener: listener, Handler: s}
+
+	for _, server := range []*dns.Server{s.udp, s.tcp} {
+		started := make(chan struct{})
+		server.NotifyStartedFunc = func() { close(started) }
+		go func(server *dns.Server) {
+			if err := server.ActivateAndServe(); err != nil {
+				provider.logger.Error(err, "Embedded DNS server stopped")
+			}
+		}(server)
+		<-started
+	}
+
+	return s, nil
+}
+
+// Address returns the address the server listens on, as host:port.
+func (s *Server) Address() string {
+	return s.udp.PacketConn.LocalAddr().String()
+}
+
+func (s *Server) Shutdown() error {
+	if err := s.udp.Shutdown(); err != nil {
+		return err
+	}
+	return s.tcp.Shutdown()
+}
+
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	if len(r.Question) != 1 {
+		m.Rcode = dns.RcodeFormatError
+		_ = w.WriteMsg(m)
+		return
+	}
+	q := r.Question[0]
+
+	zoneID, ok := s.provider.findZone(q.Name)
+	if !ok {
+		m.Rcode = dns.RcodeRefused
+		_ = w.WriteMsg(m)
+		return
+	}
+	m.Authoritative = true
+	apex := dns.Fqdn(normalizeName(zoneID))
+
+	switch {
+	case q.Qtype == dns.TypeSOA && normalizeName(q.Name) == normalizeName(apex):
+		m.Answer = append(m.Answer, soa(apex))
+	case q.Qtype == dns.TypeNS && normalizeName(q.Name) == normalizeName(apex):
+		m.Answer = append(m.Answer, &dns.NS{
+			Hdr: dns.RR_Header{Name: apex, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: soaTTL},
+			Ns:  nameserver(apex),
+		})
+	default:
+		m.Answer = s.answer(zoneID, q.Name, q.Qtype, 0)
+	}
+
+	if len(m.Answer) == 0 {
+		if !s.provider.hasName(zoneID, q.Name) && normalizeName(q.Name) != normalizeName(apex) {
+			m.Rcode = dns.RcodeNameError
+		}
+		m.Ns = append(m.Ns, soa(apex))
+	}
+
+	_ = w.WriteMsg(m)
+}
+
+// answer returns the records answering a query for name and qtype, following
+// CNAMEs pointing into the stored zones.
+func (s *Server) answer(zoneID, name string, qtype uint16, depth int) []dns.RR {
+	if qtype != dns.TypeCNAME {
+		if cnames := s.records(zoneID, name, string(v1.CNAMERecordType)); len(cnames) > 0 {
+			answer := cnames
+			target := cnames[0].(*dns.CNAME).Target
+			if targetZoneID, ok := s.provider.findZone(target); ok && depth < maxCNAMEChain {
+				answer = append(answer, s.answer(targetZoneID, target, qtype, depth+1)...)
+			}
+			return answer
+		}
+	}
+
+	switch qtype {
+	case dns.TypeA:
+		return s.records(zoneID, name, string(v1.ARecordType))
+	case dns.TypeAAAA:
+		return s.records(zoneID, name, string(v1.AAAARecordType))
+	case dns.TypeCNAME:
+		return s.records(zoneID, name, string(v1.CNAMERecordType))
+	}
+	return nil
+}
+
+// records returns the records of the selected record sets for name and type.
+func (s *Server) records(zoneID, name, recordType string) []dns.RR {
+	var records []dns.RR
+	for _, endpoint := range selectEndpoints(s.provider.Endpoints(zoneID, name, recordType)) {
+		for _, target := range endpoint.Targets {
+			if rr := rrForTarget(dns.Fqdn(name), recordType, uint32(endpoint.RecordTTL), target); rr != nil {
+				records = append(records, rr)
+			}
+		}
+	}
+	return records
+}
+
+// selectEndpoints returns the endpoints to answer with. Unweighted endpoints
+// are always returned. Among weighted endpoints, a single one is picked with a
+// probability proportional to its weight; endpoints with a weight of 0 are only
+// picked if all the weights are 0, as Route53 does.
+//
+// The location of the clients is unknown, so among geolocation endpoints only
+// the default one is answered, and a single latency endpoint is picked at random.
+// The endpoints are not health checked, so secondary failover endpoints are never
+// answered.
+func selectEndpoints(endpoints []*v1.Endpoint) []*v1.Endpoint {
+	var selected, weighted, latency []*v1.Endpoint
+	var weights []int64
+	total := int64(0)
+	for _, endpoint := range endpoints {
+		if _, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificGeolocationContinentCode); ok {
+			continue
+		}
+		if prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificGeolocationCountryCode); ok && prop.Value != "*" {
+			continue
+		}
+		if prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificFailover); ok && prop.Value == aws.FailoverSecondary {
+			continue
+		}
+		if _, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificRegion); ok {
+			latency = append(latency, endpoint)
+			continue
+		}
+		prop, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificWeight)
+		if !ok {
+			selected = append(selected, endpoint)
+			continue
+		}
+		weight, err := strconv.ParseInt(prop.Value, 10, 64)
+		if err != nil || weight < 0 {
+			weight = 0
+		}
+		weighted = append(weighted, endpoint)
+		weights = append(weights, weight)
+		total += weight
+	}
+
+	if len(latency) > 0 {
+		selected = append(selected, latency[rand.Intn(len(latency))])
+	}
+	if len(weighted) == 0 {
+		return selected
+	}
+	if total == 0 {
+		return append(selected, weighted[rand.Intn(len(weighted))])
+	}
+	n := rand.Int63n(total)
+	for i, weight := range weights {
+		if n < weight {
+			return append(selected, weighted[i])
+		}
+		n -= weight
+	}
+	return selected
+}
+
+func (p *Provider) hasName(zoneID, name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for key := range p.zones[zoneID] {
+		if key.name == normalizeName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func rrForTarget(name, recordType string, ttl uint32, target string) dns.RR {
+	switch recordType {
+	case string(v1.ARecordType):
+		ip := net.ParseIP(target).To4()
+		if ip == nil {
+			return nil
+		}
+		return &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   ip,
+		}
+	case string(v1.AAAARecordType):
+		ip := net.ParseIP(target)
+		if ip == nil || ip.To4() != nil {
+			return nil
+		}
+		return &dns.AAAA{
+			Hdr:  dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ttl},
+			AAAA: ip,
+		}
+	case string(v1.CNAMERecordType):
+		return &dns.CNAME{
+			Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: ttl},
+			Target: dns.Fqdn(target),
+		}
+	}
+	return nil
+}
+
+func nameserver(apex string) string {
+	return fmt.Sprintf("ns.%s", apex)
+}
+
+func soa(apex string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: apex, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: soaTTL},
+		Ns:      nameserver(apex),
+		Mbox:    fmt.Sprintf("hostmaster.%s", apex),
+		Serial:  1,
+		Refresh: soaTTL,
+		Retry:   soaTTL,
+		Expire:  soaTTL,
+		Minttl:  soaTTL,
+	}
+}
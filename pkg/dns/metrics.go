@@ -0,0 +1,147 @@
+package dns
+
+import (
+	"github.com/kcp-dev/logicalcluster/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/metrics"
+)
+
+const (
+	workspaceLabel     = "workspace"
+	namespaceLabel     = "namespace"
+	recordLabel        = "record"
+	dnsNameLabel       = "dns_name"
+	setIdentifierLabel = "set_identifier"
+	clusterLabel       = "cluster"
+)
+
+var (
+	// endpointHealthGauge is a prometheus metric which holds the health of the
+	// health checked endpoints, 1 when healthy and 0 when unhealthy. The
+	// endpoints of unknown health are not reported.
+	endpointHealthGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "glbc_dns_endpoint_health",
+			Help: "GLBC DNS health checked endpoint health",
+		},
+		[]string{workspaceLabel, namespaceLabel, recordLabel, dnsNameLabel, setIdentifierLabel, clusterLabel},
+	)
+
+	// orphanedHealthChecks is a prometheus metric which holds the number of
+	// orphaned health checks found by the last sweep, and not yet deleted.
+	orphanedHealthChecks = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "glbc_dns_orphaned_health_checks",
+			Help: "GLBC DNS orphaned health checks not yet deleted",
+		},
+	)
+
+	// orphanedHealthChecksDeleted is a prometheus counter metrics which holds
+	// the total number of orphaned health checks deleted.
+	orphanedHealthChecksDeleted = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "glbc_dns_orphaned_health_checks_deleted_total",
+			Help: "GLBC DNS total number of orphaned health checks deleted",
+		},
+	)
+
+	// healthCheckSweepErrors is a prometheus counter metrics which holds the
+	// total number of errors sweeping the orphaned health checks.
+	healthCheckSweepErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "glbc_dns_health_check_sweep_errors_total",
+			Help: "GLBC DNS total number of health check sweep errors",
+		},
+	)
+)
+
+func init() {
+	// Register metrics into the global prometheus registry
+	metrics.Registry.MustRegister(
+		endpointHealthGauge,
+		orphanedHealthChecks,
+		orphanedHealthChecksDeleted,
+		healthCheckSweepErrors,
+	)
+}
+
+// reportEndpointHealthMetrics sets the health metric of the health checked endpoints of the record, and deletes the
+// metric of the endpoints no longer reported.
+func (c *Controller) reportEndpointHealthMetrics(record *v1.DNSRecord) {
+	key, err := cache.MetaNamespaceKeyFunc(record)
+	if err != nil {
+		return
+	}
+
+	var reported []prometheus.Labels
+	for _, endpoint := range healthCheckedEndpoints(record.Spec.Endpoints, record.Status.Zones) {
+		var value float64
+		switch endpoint.health {
+		case v1.EndpointHealthy:
+			value = 1
+		case v1.EndpointUnhealthy:
+			value = 0
+		default:
+			continue
+		}
+		labels := prometheus.Labels{
+			workspaceLabel:     logicalcluster.From(record).String(),
+			namespaceLabel:     record.Namespace,
+			recordLabel:        record.Name,
+			dnsNameLabel:       endpoint.dnsName,
+			setIdentifierLabel: endpoint.setIdentifier,
+			clusterLabel:       endpoint.cluster,
+		}
+		endpointHealthGauge.With(labels).Set(value)
+		reported = append(reported, labels)
+	}
+
+	c.healthMetricsLock.Lock()
+	defer c.healthMetricsLock.Unlock()
+	for _, previous := range c.healthMetrics[key] {
+		if !containsLabels(reported, previous) {
+			endpointHealthGauge.Delete(previous)
+		}
+	}
+	if len(reported) == 0 {
+		delete(c.healthMetrics, key)
+	} else {
+		c.healthMetrics[key] = reported
+	}
+}
+
+// forgetEndpointHealth deletes the health metrics and the health status check of the record, once deleted
+func (c *Controller) forgetEndpointHealth(key string) {
+	c.healthStatusLock.Lock()
+	delete(c.healthStatusChecks, key)
+	c.healthStatusLock.Unlock()
+
+	c.healthMetricsLock.Lock()
+	defer c.healthMetricsLock.Unlock()
+	for _, labels := range c.healthMetrics[key] {
+		endpointHealthGauge.Delete(labels)
+	}
+	delete(c.healthMetrics, key)
+}
+
+func containsLabels(labelSets []prometheus.Labels, labels prometheus.Labels) bool {
+	for _, set := range labelSets {
+		if len(set) != len(labels) {
+			continue
+		}
+		equal := true
+		for name, value := range labels {
+			if set[name] != value {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return true
+		}
+	}
+	return false
+}
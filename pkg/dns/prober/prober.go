@@ -0,0 +1,327 @@
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+const (
+	IntervalEnvVar = "GLBC_HEALTH_PROBE_INTERVAL"
+	TimeoutEnvVar  = "GLBC_HEALTH_PROBE_TIMEOUT"
+
+	// DefaultInterval is the interval the endpoints are probed at
+	DefaultInterval = 30 * time.Second
+	// DefaultTimeout is the time a probe waits for the endpoint to respond
+	DefaultTimeout = 5 * time.Second
+	// DefaultFailureThreshold is the number of consecutive probes an endpoint can fail before it is considered
+	// unhealthy, as for Route53 health checks
+	DefaultFailureThreshold = 3
+
+	// searchLength is the length of the response body searched for the search string, as for Route53 health checks
+	searchLength = 5120
+)
+
+// Prober probes the health of endpoints in process, for the DNS providers that have no health checks. The health
+// checks of the endpoints are identified by the ID of their v1.EndpointHealthCheck, set on the endpoints as the
+// aws.ProviderSpecificHealthCheckID provider specific property, as for Route53 health checks.
+//
+// A nil Prober probes no endpoint, and reports the health of all the endpoints as unknown.
+type Prober struct {
+	interval time.Duration
+	timeout  time.Duration
+	logger   logr.Logger
+	probe    func(ctx context.Context, target Target) error
+
+	mu        sync.Mutex
+	probes    map[string]*probe
+	listeners []func(id string)
+}
+
+// Target is the endpoint a health check probes
+type Target struct {
+	// Host is the DNS name of the endpoint, sent as the HTTP Host header and TLS server name
+	Host             string
+	Address          string
+	Port             int64
+	Path             string
+	Protocol         v1.HealthCheckProtocol
+	FailureThreshold int64
+	// SearchString is the string the response body of HTTP(S) targets must contain
+	SearchString string
+	// Interval between the probes, the interval of the prober when zero
+	Interval time.Duration
+}
+
+type probe struct {
+	target    Target
+	cancel    context.CancelFunc
+	health    v1.EndpointHealth
+	failures  int64
+	successes int64
+}
+
+// NewProber returns a prober probing the endpoints every interval, waiting timeout for each probe
+func NewProber(interval, timeout time.Duration) *Prober {
+	p := &Prober{
+		interval: interval,
+		timeout:  timeout,
+		logger:   log.Logger.WithName("health-prober"),
+		probes:   map[string]*probe{},
+	}
+	p.probe = p.probeTarget
+	return p
+}
+
+// ReconcileHealthCheck starts probing the endpoint with the health check, or restarts it when the health check has
+// changed, and sets the ID of the health check on the endpoint.
+func (p *Prober) ReconcileHealthCheck(_ context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
+	if p == nil {
+		return nil
+	}
+	address, ok := endpoint.GetAddress()
+	if !ok {
+		return fmt.Errorf("endpoint %s has no address to probe", endpoint.SetID())
+	}
+	target := newTarget(hc, endpoint.DNSName, address)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if existing, ok := p.probes[hc.Id]; !ok || existing.target != target {
+		p.stop(hc.Id)
+		p.start(hc.Id, target)
+	}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, hc.Id)
+	return nil
+}
+
+// DeleteHealthCheck stops probing the endpoint
+func (p *Prober) DeleteHealthCheck(_ context.Context, endpoint *v1.Endpoint) error {
+	if p == nil {
+		return nil
+	}
+	id, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	if !ok {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stop(id)
+	endpoint.DeleteProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	return nil
+}
+
+// HealthCheckStatus returns the last probed health of the endpoint
+func (p *Prober) HealthCheckStatus(_ context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	return p.EndpointHealth(endpoint), nil
+}
+
+// EndpointHealth returns the last probed health of the endpoint, or unknown if it isn't probed
+func (p *Prober) EndpointHealth(endpoint *v1.Endpoint) v1.EndpointHealth {
+	if p == nil {
+		return v1.EndpointUnknown
+	}
+	id, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID)
+	if !ok {
+		return v1.EndpointUnknown
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if probe, ok := p.probes[id]; ok {
+		return probe.health
+	}
+	return v1.EndpointUnknown
+}
+
+// OnHealthChange registers a function called with the ID of the health checks whose health changes
+func (p *Prober) OnHealthChange(listener func(id string)) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.listeners = append(p.listeners, listener)
+}
+
+func (p *Prober) start(id string, target Target) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.probes[id] = &probe{
+		target: target,
+		cancel: cancel,
+		health: v1.EndpointUnknown,
+	}
+	p.logger.V(3).Info("Started probing endpoint", "id", id, "host", target.Host, "address", target.Address)
+
+	interval := p.interval
+	if target.Interval > 0 {
+		interval = target.Interval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.record(id, target, p.probe(ctx, target))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *Prober) stop(id string) {
+	if probe, ok := p.probes[id]; ok {
+		probe.cancel()
+		delete(p.probes, id)
+		p.logger.V(3).Info("Stopped probing endpoint", "id", id, "host", probe.target.Host, "address", probe.target.Address)
+	}
+}
+
+// record updates the health of the endpoint with the result of a probe, and notifies the listeners when it changes
+func (p *Prober) record(id string, target Target, err error) {
+	p.mu.Lock()
+	probe, ok := p.probes[id]
+	// The probe has been stopped or restarted since
+	if !ok || probe.target != target {
+		p.mu.Unlock()
+		return
+	}
+
+	previous := probe.health
+	if err == nil {
+		probe.failures = 0
+		probe.successes++
+		if probe.health == v1.EndpointUnknown || probe.successes >= target.FailureThreshold {
+			probe.health = v1.EndpointHealthy
+		}
+	} else {
+		p.logger.V(3).Info("Endpoint probe failed", "id", id, "host", target.Host, "address", target.Address, "error", err.Error())
+		probe.successes = 0
+		probe.failures++
+		if probe.failures >= target.FailureThreshold {
+			probe.health = v1.EndpointUnhealthy
+		}
+	}
+
+	health := probe.health
+	if health == previous {
+		p.mu.Unlock()
+		return
+	}
+	listeners := append([]func(string){}, p.listeners...)
+	p.mu.Unlock()
+
+	p.logger.Info("Endpoint health changed", "id", id, "host", target.Host, "address", target.Address, "health", health)
+	for _, listener := range listeners {
+		listener(id)
+	}
+}
+
+// probeTarget requests the target, returning an error if it is unhealthy. HTTP(S) targets are healthy when they
+// respond with a 2xx or 3xx status code, and the search string if any, as for Route53 health checks, and TCP targets
+// when they accept connections.
+func (p *Prober) probeTarget(ctx context.Context, target Target) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	address := net.JoinHostPort(target.Address, strconv.FormatInt(target.Port, 10))
+	if target.Protocol == v1.HealthCheckProtocolTCP {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	scheme := "http"
+	if target.Protocol == v1.HealthCheckProtocolHTTPS {
+		scheme = "https"
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s%s", scheme, address, target.Path), nil)
+	if err != nil {
+		return err
+	}
+	request.Host = target.Host
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			// The certificate isn't verified, as for Route53 health checks, since the endpoint address is requested
+			TLSClientConfig:   &tls.Config{ServerName: target.Host, InsecureSkipVerify: true}, // #nosec G402
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 400 {
+		return fmt.Errorf("unhealthy status code %d", response.StatusCode)
+	}
+	if target.SearchString == "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, searchLength))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(body), target.SearchString) {
+		return fmt.Errorf("search string %q not found in the response body", target.SearchString)
+	}
+	return nil
+}
+
+func newTarget(hc v1.EndpointHealthCheck, host, address string) Target {
+	target := Target{
+		Host:             host,
+		Address:          address,
+		Path:             hc.Path,
+		Protocol:         v1.HealthCheckProtocolHTTP,
+		Port:             80,
+		FailureThreshold: DefaultFailureThreshold,
+		SearchString:     hc.SearchString,
+	}
+	if hc.Protocol != nil {
+		target.Protocol = *hc.Protocol
+	}
+	if target.Protocol == v1.HealthCheckProtocolHTTPS {
+		target.Port = 443
+	}
+	if hc.Port != nil {
+		target.Port = *hc.Port
+	}
+	if hc.FailureThreshold != nil && *hc.FailureThreshold > 0 {
+		target.FailureThreshold = *hc.FailureThreshold
+	}
+	if hc.Interval != nil {
+		target.Interval = time.Duration(*hc.Interval) * time.Second
+	}
+	return target
+}
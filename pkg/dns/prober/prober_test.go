@@ -0,0 +1,239 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+func TestProber(t *testing.T) {
+	var healthy atomic.Value
+	healthy.Store(true)
+
+	p := NewProber(10*time.Millisecond, time.Second)
+	p.probe = func(_ context.Context, _ Target) error {
+		if healthy.Load().(bool) {
+			return nil
+		}
+		return errors.New("unhealthy")
+	}
+	changes := make(chan string, 10)
+	p.OnHealthChange(func(id string) { changes <- id })
+
+	threshold := int64(2)
+	hc := v1.EndpointHealthCheck{Id: "abc", HealthCheckSpec: v1.HealthCheckSpec{Path: "/healthz", FailureThreshold: &threshold}}
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "192.168.0.1", Targets: v1.Targets{"192.168.0.1"}}
+	if err := p.ReconcileHealthCheck(context.TODO(), hc, endpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); id != "abc" {
+		t.Fatalf("expected the health check ID to be set on the endpoint, got %q", id)
+	}
+
+	waitForChange := func(expected v1.EndpointHealth) {
+		t.Helper()
+		select {
+		case id := <-changes:
+			if id != "abc" {
+				t.Fatalf("expected a change of abc, got %s", id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the endpoint to be %s", expected)
+		}
+		if health := p.EndpointHealth(endpoint); health != expected {
+			t.Fatalf("expected the endpoint to be %s, got %s", expected, health)
+		}
+	}
+
+	waitForChange(v1.EndpointHealthy)
+	healthy.Store(false)
+	waitForChange(v1.EndpointUnhealthy)
+	healthy.Store(true)
+	waitForChange(v1.EndpointHealthy)
+
+	if err := p.DeleteHealthCheck(context.TODO(), endpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok {
+		t.Fatalf("expected the health check ID to be removed from the endpoint")
+	}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckID, "abc")
+	if health := p.EndpointHealth(endpoint); health != v1.EndpointUnknown {
+		t.Fatalf("expected the health of an endpoint no longer probed to be unknown, got %s", health)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	p := NewProber(time.Minute, time.Second)
+	target := Target{Address: "192.168.0.1", FailureThreshold: 3}
+	p.probes["abc"] = &probe{target: target, cancel: func() {}, health: v1.EndpointUnknown}
+	failure := errors.New("unhealthy")
+
+	steps := []struct {
+		err      error
+		expected v1.EndpointHealth
+	}{
+		{err: failure, expected: v1.EndpointUnknown},
+		{err: failure, expected: v1.EndpointUnknown},
+		{err: failure, expected: v1.EndpointUnhealthy},
+		// An unhealthy endpoint must succeed the threshold of consecutive probes to be healthy again
+		{err: nil, expected: v1.EndpointUnhealthy},
+		{err: nil, expected: v1.EndpointUnhealthy},
+		{err: failure, expected: v1.EndpointUnhealthy},
+		{err: nil, expected: v1.EndpointUnhealthy},
+		{err: nil, expected: v1.EndpointUnhealthy},
+		{err: nil, expected: v1.EndpointHealthy},
+		{err: failure, expected: v1.EndpointHealthy},
+	}
+	for i, step := range steps {
+		p.record("abc", target, step.err)
+		if health := p.probes["abc"].health; health != step.expected {
+			t.Fatalf("step %d: expected %s, got %s", i, step.expected, health)
+		}
+	}
+
+	// The results of a restarted probe are ignored
+	p.record("abc", Target{Address: "192.168.0.2"}, nil)
+	if failures := p.probes["abc"].failures; failures != 1 {
+		t.Fatalf("expected the result of another target to be ignored")
+	}
+}
+
+func TestProbeTarget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "echo.example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ok"))
+		case "/moved":
+			http.Redirect(w, r, "/healthz", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portValue, err := net.SplitHostPort(serverURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.ParseInt(portValue, 10, 64)
+
+	p := NewProber(time.Minute, time.Second)
+	testCases := []struct {
+		name    string
+		target  Target
+		healthy bool
+	}{
+		{
+			name:    "healthy HTTP endpoint",
+			target:  Target{Host: "echo.example.com", Address: host, Port: port, Path: "/healthz", Protocol: v1.HealthCheckProtocolHTTP},
+			healthy: true,
+		},
+		{
+			name:    "redirecting HTTP endpoint",
+			target:  Target{Host: "echo.example.com", Address: host, Port: port, Path: "/moved", Protocol: v1.HealthCheckProtocolHTTP},
+			healthy: true,
+		},
+		{
+			name:   "unhealthy HTTP endpoint",
+			target: Target{Host: "echo.example.com", Address: host, Port: port, Path: "/unavailable", Protocol: v1.HealthCheckProtocolHTTP},
+		},
+		{
+			name:    "HTTP endpoint matching the search string",
+			target:  Target{Host: "echo.example.com", Address: host, Port: port, Path: "/healthz", Protocol: v1.HealthCheckProtocolHTTP, SearchString: "ok"},
+			healthy: true,
+		},
+		{
+			name:   "HTTP endpoint not matching the search string",
+			target: Target{Host: "echo.example.com", Address: host, Port: port, Path: "/healthz", Protocol: v1.HealthCheckProtocolHTTP, SearchString: "ready"},
+		},
+		{
+			name:    "listening TCP endpoint",
+			target:  Target{Address: host, Port: port, Protocol: v1.HealthCheckProtocolTCP},
+			healthy: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := p.probeTarget(context.TODO(), testCase.target); (err == nil) != testCase.healthy {
+				t.Fatalf("expected healthy to be %t, got error %v", testCase.healthy, err)
+			}
+		})
+	}
+
+	server.Close()
+	if err := p.probeTarget(context.TODO(), Target{Address: host, Port: port, Protocol: v1.HealthCheckProtocolTCP}); err == nil {
+		t.Fatalf("expected a closed TCP endpoint to be unhealthy")
+	}
+}
+
+func TestNewTarget(t *testing.T) {
+	https := v1.HealthCheckProtocolHTTPS
+	interval := int64(10)
+	hc := v1.EndpointHealthCheck{HealthCheckSpec: v1.HealthCheckSpec{Path: "/healthz", Protocol: &https, Interval: &interval, SearchString: "ok"}}
+
+	target := newTarget(hc, "echo.example.com", "192.168.0.1")
+	expected := Target{
+		Host:             "echo.example.com",
+		Address:          "192.168.0.1",
+		Port:             443,
+		Path:             "/healthz",
+		Protocol:         v1.HealthCheckProtocolHTTPS,
+		FailureThreshold: DefaultFailureThreshold,
+		SearchString:     "ok",
+		Interval:         10 * time.Second,
+	}
+	if target != expected {
+		t.Fatalf("expected %+v, got %+v", expected, target)
+	}
+}
+
+func TestNilProber(t *testing.T) {
+	var p *Prober
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "192.168.0.1", Targets: v1.Targets{"192.168.0.1"}}
+	if err := p.ReconcileHealthCheck(context.TODO(), v1.EndpointHealthCheck{Id: "abc"}, endpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificHealthCheckID); ok {
+		t.Fatalf("expected no health check ID to be set")
+	}
+	if health := p.EndpointHealth(endpoint); health != v1.EndpointUnknown {
+		t.Fatalf("expected unknown health, got %s", health)
+	}
+}
+
+// The records with several targets, e.g. the failover records, are only probed on their first address
+func TestProberProbesFirstAddress(t *testing.T) {
+	p := NewProber(time.Minute, time.Second)
+	p.probe = func(_ context.Context, _ Target) error { return nil }
+	endpoint := &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", SetIdentifier: "primary", Targets: v1.Targets{"192.168.0.1", "192.168.0.2"}}
+	if err := p.ReconcileHealthCheck(context.TODO(), v1.EndpointHealthCheck{Id: "abc"}, endpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer p.DeleteHealthCheck(context.TODO(), endpoint)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if address := p.probes["abc"].target.Address; address != "192.168.0.1" {
+		t.Fatalf("expected the first address to be probed, got %s", address)
+	}
+}
@@ -0,0 +1,152 @@
+package dns
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+const (
+	// propagationCheckInterval is the interval the pending changes are checked at
+	propagationCheckInterval = 5 * time.Second
+	// maxVerificationCheckInterval caps the interval the records failing verification are checked at, which
+	// doubles on each attempt
+	maxVerificationCheckInterval = 5 * time.Minute
+)
+
+// publishedCondition returns the Propagated condition of a record just published to zone, and sets the ID of the
+// change to track on the zone status, or false if the provider doesn't track its changes. The propagation of the
+// records published with those providers is then left to be verified against the nameservers.
+func (c *Controller) publishedCondition(zoneRecord *v1.DNSRecord, zone v1.DNSZone, status *v1.DNSZoneStatus) (v1.DNSZoneCondition, bool) {
+	status.ChangeID = ""
+	tracker, ok := c.dnsProvider.(ChangeTracker)
+	if !ok {
+		return v1.DNSZoneCondition{}, false
+	}
+	status.ChangeID = tracker.LastChange(zoneRecord, zone)
+	if status.ChangeID == "" {
+		// No change was submitted, the records were already in sync
+		return v1.DNSZoneCondition{
+			Type:               v1.DNSRecordPropagatedConditionType,
+			Status:             string(ConditionTrue),
+			Reason:             "Applied",
+			Message:            "The DNS provider applied the changes",
+			LastTransitionTime: metav1.Now(),
+		}, true
+	}
+	return pendingCondition(status.ChangeID), true
+}
+
+// propagatedCondition returns the Propagated condition of the change, or false if it can't be checked
+func (c *Controller) propagatedCondition(zone v1.DNSZone, changeID string) (v1.DNSZoneCondition, bool) {
+	tracker, ok := c.dnsProvider.(ChangeTracker)
+	if !ok || changeID == "" {
+		return v1.DNSZoneCondition{}, false
+	}
+	propagated, err := tracker.ChangePropagated(changeID)
+	if err != nil {
+		c.Logger.Error(err, "Failed to check DNS change propagation", "change", changeID, "zone", zone)
+		return v1.DNSZoneCondition{}, false
+	}
+	if !propagated {
+		return pendingCondition(changeID), true
+	}
+	c.Logger.Info("DNS change propagated", "change", changeID, "zone", zone)
+	return v1.DNSZoneCondition{
+		Type:               v1.DNSRecordPropagatedConditionType,
+		Status:             string(ConditionTrue),
+		Reason:             "InSync",
+		Message:            fmt.Sprintf("The change %s has been propagated to the authoritative nameservers", changeID),
+		LastTransitionTime: metav1.Now(),
+	}, true
+}
+
+func pendingCondition(changeID string) v1.DNSZoneCondition {
+	return v1.DNSZoneCondition{
+		Type:               v1.DNSRecordPropagatedConditionType,
+		Status:             string(ConditionFalse),
+		Reason:             "Pending",
+		Message:            fmt.Sprintf("The change %s is pending propagation to the authoritative nameservers", changeID),
+		LastTransitionTime: metav1.Now(),
+	}
+}
+
+// RecordIsPropagatedToZone returns whether the last change of the record published to the zone has been
+// propagated to the authoritative nameservers, as determined from the record's Propagated status condition. The
+// second value is false if the condition isn't reported for the zone.
+func RecordIsPropagatedToZone(record *v1.DNSRecord, zone *v1.DNSZone) (bool, bool) {
+	condition := zoneCondition(record, zone, v1.DNSRecordPropagatedConditionType)
+	if condition == nil {
+		return false, false
+	}
+	return condition.Status == string(ConditionTrue), true
+}
+
+func recordIsVerifiedInZone(record *v1.DNSRecord, zone *v1.DNSZone) bool {
+	condition := zoneCondition(record, zone, v1.DNSRecordVerifiedConditionType)
+	return condition != nil && condition.Status == string(ConditionTrue)
+}
+
+// hasPendingChanges returns whether the record has changes pending propagation, or verification when the records
+// are verified, in any zone
+func (c *Controller) hasPendingChanges(record *v1.DNSRecord) bool {
+	propagation, verification := c.pendingChanges(record)
+	return propagation || verification
+}
+
+// pendingChanges returns whether the record has changes pending propagation, and whether it has changes pending
+// verification, in any zone
+func (c *Controller) pendingChanges(record *v1.DNSRecord) (bool, bool) {
+	var propagation, verification bool
+	for i := range record.Status.Zones {
+		zone := &record.Status.Zones[i].DNSZone
+		if !RecordIsAlreadyPublishedToZone(record, zone) {
+			continue
+		}
+		propagated, ok := RecordIsPropagatedToZone(record, zone)
+		if ok && !propagated {
+			propagation = true
+		}
+		if c.recordVerifier != nil && propagated && !recordIsVerifiedInZone(record, zone) {
+			verification = true
+		}
+	}
+	return propagation, verification
+}
+
+// pendingChangesCheckInterval returns the interval the pending changes of the record are checked after. The
+// propagation is checked every propagationCheckInterval, while the verification backs off exponentially, up to
+// maxVerificationCheckInterval or the drift check interval, as some records may never be verified, e.g. those of
+// split-horizon zones.
+func (c *Controller) pendingChangesCheckInterval(key string, record *v1.DNSRecord) time.Duration {
+	c.verifyAttemptsLock.Lock()
+	defer c.verifyAttemptsLock.Unlock()
+
+	if propagation, _ := c.pendingChanges(record); propagation {
+		delete(c.verifyAttempts, key)
+		return propagationCheckInterval
+	}
+
+	maxInterval := maxVerificationCheckInterval
+	if c.driftCheckInterval > 0 && c.driftCheckInterval < maxInterval {
+		maxInterval = c.driftCheckInterval
+	}
+	interval := propagationCheckInterval
+	for i := 0; i < c.verifyAttempts[key] && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	c.verifyAttempts[key]++
+	return interval
+}
+
+func (c *Controller) forgetVerifyAttempts(key string) {
+	c.verifyAttemptsLock.Lock()
+	defer c.verifyAttemptsLock.Unlock()
+	delete(c.verifyAttempts, key)
+}
@@ -0,0 +1,162 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/reconciler"
+)
+
+// fakeChangeTracker submits a new change on each Ensure, propagated once checked
+type fakeChangeTracker struct {
+	FakeProvider
+	changes    int
+	propagated map[string]bool
+}
+
+func (f *fakeChangeTracker) Ensure(_ *v1.DNSRecord, _ v1.DNSZone) error {
+	f.changes++
+	return nil
+}
+
+func (f *fakeChangeTracker) LastChange(_ *v1.DNSRecord, _ v1.DNSZone) string {
+	if f.changes == 0 {
+		return ""
+	}
+	return fmt.Sprintf("C%d", f.changes)
+}
+
+func (f *fakeChangeTracker) ChangePropagated(changeID string) (bool, error) {
+	return f.propagated[changeID], nil
+}
+
+func TestPropagation(t *testing.T) {
+	zone := v1.DNSZone{ID: "Z1"}
+	provider := &fakeChangeTracker{propagated: map[string]bool{}}
+	c := &Controller{
+		Controller:  &reconciler.Controller{Logger: logr.Discard()},
+		dnsProvider: provider,
+		dnsZones:    Zones{{DNSZone: zone}},
+	}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{{DNSName: "echo.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}}}},
+	}
+	record.Generation = 1
+
+	reconcile := func() {
+		record.Status.Zones = c.publishRecordToZones(context.TODO(), c.dnsZones, record)
+		record.Status.ObservedGeneration = record.Generation
+	}
+
+	reconcile()
+	if propagated, tracked := RecordIsPropagatedToZone(record, &zone); propagated || !tracked {
+		t.Fatalf("expected the change to be pending, got %v", record.Status.Zones)
+	}
+	if changeID := record.Status.Zones[0].ChangeID; changeID != "C1" {
+		t.Fatalf("expected change C1 to be tracked, got %q", changeID)
+	}
+	if !c.hasPendingChanges(record) {
+		t.Fatalf("expected the record to have pending changes")
+	}
+
+	// The change is checked until it is propagated, without being submitted again
+	reconcile()
+	if propagated, _ := RecordIsPropagatedToZone(record, &zone); propagated {
+		t.Fatalf("expected the change to still be pending")
+	}
+	provider.propagated["C1"] = true
+	reconcile()
+	if propagated, _ := RecordIsPropagatedToZone(record, &zone); !propagated {
+		t.Fatalf("expected the change to be propagated, got %v", record.Status.Zones)
+	}
+	if provider.changes != 1 || c.hasPendingChanges(record) {
+		t.Fatalf("expected a single change without pending changes, got %d changes", provider.changes)
+	}
+
+	// A new change of the record is tracked
+	record.Generation = 2
+	reconcile()
+	if propagated, _ := RecordIsPropagatedToZone(record, &zone); propagated || record.Status.Zones[0].ChangeID != "C2" {
+		t.Fatalf("expected change C2 to be pending, got %v", record.Status.Zones)
+	}
+}
+
+func TestPropagationWithoutChangeTracker(t *testing.T) {
+	zone := v1.DNSZone{ID: "Z1"}
+	c := &Controller{
+		Controller:  &reconciler.Controller{Logger: logr.Discard()},
+		dnsProvider: &FakeProvider{},
+		dnsZones:    Zones{{DNSZone: zone}},
+	}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{{DNSName: "echo.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}}}},
+	}
+	record.Status.Zones = c.publishRecordToZones(context.TODO(), c.dnsZones, record)
+	// The propagation is left to be verified against the nameservers
+	if _, tracked := RecordIsPropagatedToZone(record, &zone); tracked {
+		t.Fatalf("expected the propagation not to be tracked, got %v", record.Status.Zones)
+	}
+	if c.hasPendingChanges(record) {
+		t.Fatalf("expected no pending changes")
+	}
+}
+
+// unverifiedRecordVerifier never verifies the records, e.g. as for a split-horizon zone
+type unverifiedRecordVerifier struct{}
+
+func (unverifiedRecordVerifier) Verify(_ context.Context, _ []*v1.Endpoint) *VerificationResult {
+	return &VerificationResult{Err: errors.New("no nameserver answers the records")}
+}
+
+func TestVerificationBackoff(t *testing.T) {
+	zone := v1.DNSZone{ID: "Z1"}
+	provider := &fakeChangeTracker{propagated: map[string]bool{"C1": true}}
+	c := &Controller{
+		Controller:         &reconciler.Controller{Logger: logr.Discard()},
+		dnsProvider:        provider,
+		dnsZones:           Zones{{DNSZone: zone}},
+		recordVerifier:     unverifiedRecordVerifier{},
+		driftCheckInterval: 2 * time.Minute,
+		verifyAttempts:     map[string]int{},
+	}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{{DNSName: "echo.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}}}},
+	}
+	record.Generation = 1
+
+	reconcile := func() time.Duration {
+		record.Status.Zones = c.publishRecordToZones(context.TODO(), c.dnsZones, record)
+		record.Status.ObservedGeneration = record.Generation
+		return c.pendingChangesCheckInterval("echo", record)
+	}
+
+	// The propagation is checked at a fixed interval
+	provider.propagated["C1"] = false
+	if interval := reconcile(); interval != propagationCheckInterval {
+		t.Fatalf("expected the propagation to be checked after %v, got %v", propagationCheckInterval, interval)
+	}
+
+	// The verification backs off up to the drift check interval
+	provider.propagated["C1"] = true
+	for _, expected := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, 2 * time.Minute, 2 * time.Minute} {
+		if interval := reconcile(); interval != expected {
+			t.Fatalf("expected the verification to be checked after %v, got %v", expected, interval)
+		}
+	}
+
+	// The backoff is reset by a new change
+	record.Generation = 2
+	if interval := reconcile(); interval != propagationCheckInterval {
+		t.Fatalf("expected the new change to be checked after %v, got %v", propagationCheckInterval, interval)
+	}
+	provider.propagated["C2"] = true
+	if interval := reconcile(); interval != 5*time.Second {
+		t.Fatalf("expected the verification of the new change to be checked after 5s, got %v", interval)
+	}
+}
@@ -0,0 +1,153 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// RecordValidator validates the endpoints of DNSRecords before they are published
+type RecordValidator struct {
+	// Domains are the managed domains the DNS names of the endpoints must fall inside. Any DNS name is valid when empty.
+	Domains []string
+	// MinTTL is the minimum TTL of the endpoints, in seconds
+	MinTTL v1.TTL
+	// MaxTTL is the maximum TTL of the endpoints, in seconds. The TTL isn't bounded when 0.
+	MaxTTL v1.TTL
+}
+
+// NewRecordValidator returns a validator of the endpoints within the domains of zones and domain
+func NewRecordValidator(zones Zones, domain string, minTTL, maxTTL v1.TTL) *RecordValidator {
+	var domains []string
+	if domain != "" {
+		domains = append(domains, normalizeDNSName(domain))
+	}
+	for _, zone := range zones {
+		if zone.Domain == "" {
+			// The zone holds the records of any DNS name
+			return &RecordValidator{MinTTL: minTTL, MaxTTL: maxTTL}
+		}
+		domains = append(domains, zone.Domain)
+	}
+	return &RecordValidator{Domains: domains, MinTTL: minTTL, MaxTTL: maxTTL}
+}
+
+// Validate returns the errors of the endpoints of record
+func (v *RecordValidator) Validate(record *v1.DNSRecord) field.ErrorList {
+	var errs field.ErrorList
+	endpointsPath := field.NewPath("spec", "endpoints")
+	setIdentifiers := map[string]int{}
+	for i, endpoint := range record.Spec.Endpoints {
+		path := endpointsPath.Index(i)
+		if endpoint == nil {
+			errs = append(errs, field.Required(path, "endpoint must not be null"))
+			continue
+		}
+		errs = append(errs, v.validateEndpoint(endpoint, path)...)
+
+		// Route53 refuses a change batch holding the same record set twice
+		key := strings.Join([]string{normalizeDNSName(endpoint.DNSName), endpoint.RecordType, endpoint.SetIdentifier}, "/")
+		if j, ok := setIdentifiers[key]; ok {
+			errs = append(errs, field.Duplicate(path.Child("setIdentifier"), fmt.Sprintf("%q, already set for the %s %s record of endpoint %d", endpoint.SetIdentifier, endpoint.DNSName, endpoint.RecordType, j)))
+		} else {
+			setIdentifiers[key] = i
+		}
+	}
+	return errs
+}
+
+func (v *RecordValidator) validateEndpoint(endpoint *v1.Endpoint, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	dnsName := normalizeDNSName(endpoint.DNSName)
+	if dnsName == "" {
+		errs = append(errs, field.Required(path.Child("dnsName"), ""))
+	} else if msgs := validation.IsDNS1123Subdomain(strings.TrimPrefix(dnsName, "*.")); len(msgs) > 0 {
+		errs = append(errs, field.Invalid(path.Child("dnsName"), endpoint.DNSName, strings.Join(msgs, ", ")))
+	} else if !v.isManaged(dnsName) {
+		errs = append(errs, field.Invalid(path.Child("dnsName"), endpoint.DNSName, fmt.Sprintf("must be inside one of the managed domains %v", v.Domains)))
+	}
+
+	if ttl := endpoint.RecordTTL; ttl < 0 || (ttl > 0 && (ttl < v.MinTTL || (v.MaxTTL > 0 && ttl > v.MaxTTL))) {
+		errs = append(errs, field.Invalid(path.Child("recordTTL"), endpoint.RecordTTL, v.ttlBoundsMessage()))
+	}
+
+	targetsPath := path.Child("targets")
+	if len(endpoint.Targets) == 0 {
+		return append(errs, field.Required(targetsPath, "at least one target is required"))
+	}
+
+	recordType := v1.DNSRecordType(endpoint.RecordType)
+	if alias, ok := endpoint.GetProviderSpecificProperty(aws.ProviderSpecificAlias); ok && alias.Value == "true" {
+		if recordType != v1.ARecordType && recordType != v1.AAAARecordType {
+			errs = append(errs, field.NotSupported(path.Child("recordType"), endpoint.RecordType, []string{string(v1.ARecordType), string(v1.AAAARecordType)}))
+		}
+		if len(endpoint.Targets) != 1 {
+			return append(errs, field.TooMany(targetsPath, len(endpoint.Targets), 1))
+		}
+		return append(errs, validateHostname(targetsPath.Index(0), endpoint.Targets[0])...)
+	}
+
+	switch recordType {
+	case v1.ARecordType:
+		for i, target := range endpoint.Targets {
+			if ip := net.ParseIP(target); ip == nil || ip.To4() == nil {
+				errs = append(errs, field.Invalid(targetsPath.Index(i), target, "must be an IPv4 address"))
+			}
+		}
+	case v1.AAAARecordType:
+		for i, target := range endpoint.Targets {
+			if ip := net.ParseIP(target); ip == nil || ip.To4() != nil {
+				errs = append(errs, field.Invalid(targetsPath.Index(i), target, "must be an IPv6 address"))
+			}
+		}
+	case v1.CNAMERecordType:
+		// A CNAME record set holds a single record
+		if len(endpoint.Targets) != 1 {
+			return append(errs, field.TooMany(targetsPath, len(endpoint.Targets), 1))
+		}
+		errs = append(errs, validateHostname(targetsPath.Index(0), endpoint.Targets[0])...)
+	case v1.TXTRecordType:
+		for i, target := range endpoint.Targets {
+			if target == "" {
+				errs = append(errs, field.Required(targetsPath.Index(i), ""))
+			}
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("recordType"), endpoint.RecordType,
+			[]string{string(v1.ARecordType), string(v1.AAAARecordType), string(v1.CNAMERecordType), string(v1.TXTRecordType)}))
+	}
+	return errs
+}
+
+func (v *RecordValidator) isManaged(dnsName string) bool {
+	if len(v.Domains) == 0 {
+		return true
+	}
+	for _, domain := range v.Domains {
+		if dnsName == domain || strings.HasSuffix(dnsName, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *RecordValidator) ttlBoundsMessage() string {
+	if v.MaxTTL > 0 {
+		return fmt.Sprintf("must be between %d and %d seconds", v.MinTTL, v.MaxTTL)
+	}
+	return fmt.Sprintf("must be at least %d seconds", v.MinTTL)
+}
+
+func validateHostname(path *field.Path, hostname string) field.ErrorList {
+	if msgs := validation.IsDNS1123Subdomain(normalizeDNSName(hostname)); len(msgs) > 0 {
+		return field.ErrorList{field.Invalid(path, hostname, strings.Join(msgs, ", "))}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package dns
+
+import (
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+func TestRecordValidator(t *testing.T) {
+	validator := NewRecordValidator(Zones{{Domain: "example.com", DNSZone: v1.DNSZone{ID: "Z2"}}}, "dev.hcpapps.net", 10, 3600)
+
+	alias := &v1.Endpoint{DNSName: "lb.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"lb-1234.elb.eu-west-1.amazonaws.com"}}
+	alias.SetProviderSpecific(aws.ProviderSpecificAlias, "true")
+
+	cases := []struct {
+		Name           string
+		Endpoints      []*v1.Endpoint
+		ExpectedErrors []string
+	}{
+		{
+			Name: "valid endpoints",
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "app.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"192.168.0.1", "192.168.0.2"}, RecordTTL: 60, SetIdentifier: "c1"},
+				{DNSName: "app.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"192.168.1.1"}, RecordTTL: 60, SetIdentifier: "c2"},
+				{DNSName: "app.dev.hcpapps.net", RecordType: "AAAA", Targets: v1.Targets{"2001:db8::1"}, SetIdentifier: "c1"},
+				{DNSName: "*.apps.example.com.", RecordType: "CNAME", Targets: v1.Targets{"app.dev.hcpapps.net"}},
+				{DNSName: "example.com", RecordType: "TXT", Targets: v1.Targets{"v=spf1 -all"}},
+				alias,
+			},
+		},
+		{
+			Name: "invalid record type",
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "app.dev.hcpapps.net", RecordType: "SRV", Targets: v1.Targets{"0 5 5060 sip.dev.hcpapps.net"}},
+			},
+			ExpectedErrors: []string{"spec.endpoints[0].recordType"},
+		},
+		{
+			Name: "invalid targets",
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "a.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"192.168.0.1", "2001:db8::1"}},
+				{DNSName: "aaaa.dev.hcpapps.net", RecordType: "AAAA", Targets: v1.Targets{"192.168.0.1"}},
+				{DNSName: "cname.dev.hcpapps.net", RecordType: "CNAME", Targets: v1.Targets{"a.example.com", "b.example.com"}},
+				{DNSName: "host.dev.hcpapps.net", RecordType: "CNAME", Targets: v1.Targets{"not a hostname"}},
+				{DNSName: "none.dev.hcpapps.net", RecordType: "A"},
+			},
+			ExpectedErrors: []string{
+				"spec.endpoints[0].targets[1]",
+				"spec.endpoints[1].targets[0]",
+				"spec.endpoints[2].targets",
+				"spec.endpoints[3].targets[0]",
+				"spec.endpoints[4].targets",
+			},
+		},
+		{
+			Name: "TTL out of bounds",
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "short.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 5},
+				{DNSName: "long.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 86400},
+			},
+			ExpectedErrors: []string{"spec.endpoints[0].recordTTL", "spec.endpoints[1].recordTTL"},
+		},
+		{
+			Name: "duplicate set identifiers",
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "app.dev.hcpapps.net", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}, SetIdentifier: "c1"},
+				{DNSName: "App.dev.hcpapps.net.", RecordType: "A", Targets: v1.Targets{"192.168.0.2"}, SetIdentifier: "c1"},
+			},
+			ExpectedErrors: []string{"spec.endpoints[1].setIdentifier"},
+		},
+		{
+			Name: "DNS name outside the managed domains",
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "app.example.org", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}},
+				{DNSName: "notexample.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}},
+			},
+			ExpectedErrors: []string{"spec.endpoints[0].dnsName", "spec.endpoints[1].dnsName"},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			errs := validator.Validate(&v1.DNSRecord{Spec: v1.DNSRecordSpec{Endpoints: testCase.Endpoints}})
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if len(fields) != len(testCase.ExpectedErrors) {
+				t.Fatalf("expected errors for %v, got %v", testCase.ExpectedErrors, errs)
+			}
+			for i := range fields {
+				if fields[i] != testCase.ExpectedErrors[i] {
+					t.Errorf("expected errors for %v, got %v", testCase.ExpectedErrors, errs)
+				}
+			}
+		})
+	}
+}
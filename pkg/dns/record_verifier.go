@@ -0,0 +1,284 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	gonet "net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	dnsAWS "github.com/kuadrant/kcp-glbc/pkg/dns/aws"
+)
+
+// verificationLabel replaces the wildcard label of the names queried to verify wildcard records
+const verificationLabel = "glbc-verification"
+
+// RecordVerifier verifies that the endpoints of a record are published, i.e. answered by the authoritative
+// nameservers of their zone.
+type RecordVerifier interface {
+	Verify(ctx context.Context, endpoints []*v1.Endpoint) *VerificationResult
+}
+
+// VerificationResult is the result of the verification of the endpoints against each nameserver
+type VerificationResult struct {
+	// Nameservers are the results of each nameserver queried, ordered by nameserver
+	Nameservers []NameserverResult
+	// Err is set when the nameservers can't be found
+	Err error
+}
+
+// NameserverResult is the result of the verification of the endpoints against a nameserver
+type NameserverResult struct {
+	// Nameserver is the address of the nameserver, as host:port
+	Nameserver string
+	// Mismatches describe the endpoints the answers of the nameserver don't match
+	Mismatches []string
+	// Err is set when the nameserver fails to answer
+	Err error
+}
+
+// Verified returns whether the endpoints are answered by all the nameservers
+func (r *VerificationResult) Verified() bool {
+	if r.Err != nil || len(r.Nameservers) == 0 {
+		return false
+	}
+	for _, nameserver := range r.Nameservers {
+		if nameserver.Err != nil || len(nameserver.Mismatches) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *VerificationResult) String() string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	if len(r.Nameservers) == 0 {
+		return "no nameserver found"
+	}
+	var results []string
+	for _, nameserver := range r.Nameservers {
+		switch {
+		case nameserver.Err != nil:
+			results = append(results, fmt.Sprintf("%s: %v", nameserver.Nameserver, nameserver.Err))
+		case len(nameserver.Mismatches) > 0:
+			results = append(results, fmt.Sprintf("%s: %s", nameserver.Nameserver, strings.Join(nameserver.Mismatches, ", ")))
+		default:
+			results = append(results, fmt.Sprintf("%s: verified", nameserver.Nameserver))
+		}
+	}
+	return strings.Join(results, "; ")
+}
+
+// Condition returns the Verified condition reporting the result
+func (r *VerificationResult) Condition() v1.DNSZoneCondition {
+	condition := v1.DNSZoneCondition{
+		Type:               v1.DNSRecordVerifiedConditionType,
+		Status:             string(ConditionTrue),
+		Reason:             "RecordsAnswered",
+		Message:            fmt.Sprintf("The records are answered by the authoritative nameservers: %s", r),
+		LastTransitionTime: metav1.Now(),
+	}
+	if !r.Verified() {
+		condition.Status = string(ConditionFalse)
+		condition.Reason = "RecordsNotAnswered"
+		condition.Message = fmt.Sprintf("The records are not answered by the authoritative nameservers: %s", r)
+	}
+	return condition
+}
+
+// NewRecordVerifier returns a RecordVerifier querying the authoritative nameservers of the zone of each endpoint,
+// or the given nameservers, as host:port, instead if any.
+func NewRecordVerifier(nameservers ...string) RecordVerifier {
+	return &nameserverRecordVerifier{
+		nameservers: nameservers,
+		client:      &dns.Client{Timeout: 2 * time.Second},
+		lookupNS:    gonet.DefaultResolver.LookupNS,
+	}
+}
+
+type nameserverRecordVerifier struct {
+	nameservers []string
+	client      *dns.Client
+	lookupNS    func(ctx context.Context, name string) ([]*gonet.NS, error)
+}
+
+var _ RecordVerifier = &nameserverRecordVerifier{}
+
+// recordQuery is the query verifying the endpoints of a name and type
+type recordQuery struct {
+	name  string
+	qtype uint16
+}
+
+// recordAnswers are the answers expected for a query. Any answer is expected for the alias endpoints, as they are
+// answered with the addresses of their alias target.
+type recordAnswers struct {
+	values map[string]struct{}
+	any    bool
+}
+
+func (v *nameserverRecordVerifier) Verify(ctx context.Context, endpoints []*v1.Endpoint) *VerificationResult {
+	expected := map[recordQuery]*recordAnswers{}
+	for _, endpoint := range endpoints {
+		qtype, ok := dns.StringToType[endpoint.RecordType]
+		if !ok {
+			continue
+		}
+		query := recordQuery{name: verificationName(endpoint.DNSName), qtype: qtype}
+		answers, ok := expected[query]
+		if !ok {
+			answers = &recordAnswers{values: map[string]struct{}{}}
+			expected[query] = answers
+		}
+		if prop, ok := endpoint.GetProviderSpecificProperty(dnsAWS.ProviderSpecificAlias); ok && prop.Value == "true" {
+			answers.any = true
+		}
+		for _, target := range endpoint.Targets {
+			answers.values[normalizeAnswer(qtype, target)] = struct{}{}
+		}
+	}
+
+	// The queries of each nameserver
+	queries := map[string][]recordQuery{}
+	for query := range expected {
+		nameservers, err := v.nameserversOf(ctx, query.name)
+		if err != nil {
+			return &VerificationResult{Err: err}
+		}
+		for _, nameserver := range nameservers {
+			queries[nameserver] = append(queries[nameserver], query)
+		}
+	}
+
+	result := &VerificationResult{}
+	for nameserver, nameserverQueries := range queries {
+		sort.Slice(nameserverQueries, func(i, j int) bool {
+			if nameserverQueries[i].name != nameserverQueries[j].name {
+				return nameserverQueries[i].name < nameserverQueries[j].name
+			}
+			return nameserverQueries[i].qtype < nameserverQueries[j].qtype
+		})
+		nameserverResult := NameserverResult{Nameserver: nameserver}
+		for _, query := range nameserverQueries {
+			mismatch, err := v.verify(ctx, nameserver, query, expected[query])
+			if err != nil {
+				nameserverResult.Err = err
+				break
+			}
+			if mismatch != "" {
+				nameserverResult.Mismatches = append(nameserverResult.Mismatches, mismatch)
+			}
+		}
+		result.Nameservers = append(result.Nameservers, nameserverResult)
+	}
+	sort.Slice(result.Nameservers, func(i, j int) bool {
+		return result.Nameservers[i].Nameserver < result.Nameservers[j].Nameserver
+	})
+	return result
+}
+
+// verify queries the nameserver, and returns the description of the mismatch of its answers with the expected ones,
+// if any
+func (v *nameserverRecordVerifier) verify(ctx context.Context, nameserver string, query recordQuery, expected *recordAnswers) (string, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(query.name), query.qtype)
+	m.RecursionDesired = false
+	r, _, err := v.client.ExchangeContext(ctx, m, nameserver)
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s: %v", query.name, err)
+	}
+
+	description := fmt.Sprintf("%s %s", dns.TypeToString[query.qtype], query.name)
+	if r.Rcode != dns.RcodeSuccess {
+		return fmt.Sprintf("%s: %s", description, dns.RcodeToString[r.Rcode]), nil
+	}
+	var answers []string
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype != query.qtype || !strings.EqualFold(rr.Header().Name, dns.Fqdn(query.name)) {
+			continue
+		}
+		answers = append(answers, answerValue(rr))
+	}
+	if len(answers) == 0 {
+		return fmt.Sprintf("%s: no answer", description), nil
+	}
+	if expected.any {
+		return "", nil
+	}
+	var unexpected []string
+	for _, answer := range answers {
+		if _, ok := expected.values[answer]; !ok {
+			unexpected = append(unexpected, answer)
+		}
+	}
+	if len(unexpected) > 0 {
+		return fmt.Sprintf("%s: unexpected answers %s", description, strings.Join(unexpected, ", ")), nil
+	}
+	return "", nil
+}
+
+// nameserversOf returns the addresses of the nameservers of the zone holding name, i.e. the nameservers of the
+// closest ancestor of name having some
+func (v *nameserverRecordVerifier) nameserversOf(ctx context.Context, name string) ([]string, error) {
+	if len(v.nameservers) > 0 {
+		return v.nameservers, nil
+	}
+	labels := dns.SplitDomainName(name)
+	for i := range labels {
+		domain := strings.Join(labels[i:], ".")
+		nss, err := v.lookupNS(ctx, domain)
+		if err != nil || len(nss) == 0 {
+			continue
+		}
+		var nameservers []string
+		for _, ns := range nss {
+			nameservers = append(nameservers, gonet.JoinHostPort(strings.TrimSuffix(ns.Host, "."), "53"))
+		}
+		return nameservers, nil
+	}
+	return nil, fmt.Errorf("no nameserver found for %s", name)
+}
+
+// verificationName returns the name queried to verify the records of name
+func verificationName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if strings.HasPrefix(name, "*.") {
+		return verificationLabel + strings.TrimPrefix(name, "*")
+	}
+	return name
+}
+
+func normalizeAnswer(qtype uint16, value string) string {
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		if ip := gonet.ParseIP(value); ip != nil {
+			return ip.String()
+		}
+	case dns.TypeCNAME:
+		return strings.ToLower(strings.TrimSuffix(value, "."))
+	case dns.TypeTXT:
+		return strings.Trim(value, `"`)
+	}
+	return value
+}
+
+func answerValue(rr dns.RR) string {
+	switch rr := rr.(type) {
+	case *dns.A:
+		return rr.A.String()
+	case *dns.AAAA:
+		return rr.AAAA.String()
+	case *dns.CNAME:
+		return normalizeAnswer(dns.TypeCNAME, rr.Target)
+	case *dns.TXT:
+		return strings.Join(rr.Txt, "")
+	}
+	return rr.String()
+}
@@ -0,0 +1,58 @@
+package dns_test
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/inmemory"
+)
+
+func TestRecordVerifier(t *testing.T) {
+	provider, err := inmemory.NewProvider(inmemory.Config{ListenAddress: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	defer func() { _ = provider.Server().Shutdown() }()
+
+	endpoints := []*v1.Endpoint{
+		{DNSName: "echo.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1", "192.168.0.2"}, RecordTTL: 60},
+		{DNSName: "www.example.com", RecordType: "CNAME", Targets: v1.Targets{"echo.example.com"}, RecordTTL: 60},
+	}
+	record := &v1.DNSRecord{Spec: v1.DNSRecordSpec{Endpoints: endpoints}}
+	if err := provider.Ensure(record, v1.DNSZone{ID: "example.com"}); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+
+	verifier := dns.NewRecordVerifier(provider.Server().Address())
+	result := verifier.Verify(context.TODO(), endpoints)
+	if !result.Verified() {
+		t.Fatalf("expected the records to be verified, got %s", result)
+	}
+	if condition := result.Condition(); condition.Type != v1.DNSRecordVerifiedConditionType || condition.Status != "True" {
+		t.Fatalf("unexpected condition %v", condition)
+	}
+
+	cases := []struct {
+		Name     string
+		Endpoint *v1.Endpoint
+	}{
+		{Name: "unexpected answer", Endpoint: &v1.Endpoint{DNSName: "echo.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}}},
+		{Name: "missing record", Endpoint: &v1.Endpoint{DNSName: "missing.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}}},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			result := verifier.Verify(context.TODO(), []*v1.Endpoint{testCase.Endpoint})
+			if result.Verified() {
+				t.Fatalf("expected the records not to be verified")
+			}
+			if len(result.Nameservers) != 1 || len(result.Nameservers[0].Mismatches) != 1 {
+				t.Fatalf("expected a mismatch, got %s", result)
+			}
+			if condition := result.Condition(); condition.Status != "False" {
+				t.Fatalf("unexpected condition %v", condition)
+			}
+		})
+	}
+}
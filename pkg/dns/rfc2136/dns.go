@@ -0,0 +1,284 @@
+package rfc2136
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+
+	"github.com/kuadrant/kcp-glbc/pkg/_internal/log"
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/prober"
+)
+
+const (
+	NameserverEnvVar    = "RFC2136_NAMESERVER"
+	TSIGKeyNameEnvVar   = "RFC2136_TSIG_KEYNAME"
+	TSIGSecretEnvVar    = "RFC2136_TSIG_SECRET"
+	TSIGAlgorithmEnvVar = "RFC2136_TSIG_ALGORITHM"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Provider manages records through RFC 2136 dynamic updates, optionally signed
+// with a TSIG key (RFC 8945), e.g. against a BIND server.
+//
+// The DNSZone.ID is the name of the zone to update. DNS has no notion of
+// weighted records, so all the targets of the endpoints sharing the same name
+// and type are published in a single RRset and served round robin.
+type Provider struct {
+	client *dns.Client
+	config Config
+	logger logr.Logger
+	prober *prober.Prober
+}
+
+// Config is the necessary input to configure the manager.
+type Config struct {
+	// Nameserver is the address of the primary nameserver accepting updates, as host:port.
+	Nameserver string
+	// TSIGKeyName, TSIGSecret and TSIGAlgorithm configure the TSIG key used to sign
+	// the updates. Updates are sent unsigned when TSIGKeyName is empty.
+	TSIGKeyName   string
+	TSIGSecret    string
+	TSIGAlgorithm string
+	// Net is the transport used to send updates, "tcp" or "udp". Defaults to "tcp".
+	Net string
+	// Timeout of each update exchange. Defaults to 10 seconds.
+	Timeout time.Duration
+	// Prober probes the health of the endpoints, as the provider has no
+	// health checks. The endpoints are not health checked when nil.
+	Prober *prober.Prober
+}
+
+func NewProvider(config Config) (*Provider, error) {
+	if config.Nameserver == "" {
+		return nil, fmt.Errorf("a nameserver is required (%s)", NameserverEnvVar)
+	}
+	if _, _, err := net.SplitHostPort(config.Nameserver); err != nil {
+		config.Nameserver = net.JoinHostPort(config.Nameserver, "53")
+	}
+	if config.Net == "" {
+		config.Net = "tcp"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	client := &dns.Client{
+		Net:     config.Net,
+		Timeout: config.Timeout,
+	}
+	if config.TSIGKeyName != "" {
+		if config.TSIGSecret == "" {
+			return nil, fmt.Errorf("a TSIG secret is required for key %s (%s)", config.TSIGKeyName, TSIGSecretEnvVar)
+		}
+		algorithm, err := tsigAlgorithm(config.TSIGAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		config.TSIGKeyName = dns.Fqdn(strings.ToLower(config.TSIGKeyName))
+		config.TSIGAlgorithm = algorithm
+		client.TsigSecret = map[string]string{config.TSIGKeyName: config.TSIGSecret}
+	}
+
+	return &Provider{
+		client: client,
+		prober: config.Prober,
+		config: config,
+		logger: log.Logger.WithName("rfc2136").WithValues("nameserver", config.Nameserver),
+	}, nil
+}
+
+func (p *Provider) Ensure(record *v1.DNSRecord, zone v1.DNSZone) error {
+	desired, err := rrsetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zone.ID))
+
+	// Replace each RRset: RFC 2136 processes the update section in order, so
+	// the RRset is removed before the desired records are inserted.
+	for _, key := range sortedKeys(desired) {
+		m.RemoveRRset([]dns.RR{key.rr()})
+		m.Insert(desired[key])
+	}
+
+	// Delete any previously published RRsets that are no longer present in record.Spec.Endpoints
+	published, err := rrsetsForEndpoints(record.Status.ZoneEndpoints(zone))
+	if err != nil {
+		p.logger.Error(err, "Failed to compute previously published RRsets, skipping clean up", "record", record.Name, "zone", zone.ID)
+		published = nil
+	}
+	for _, key := range sortedKeys(published) {
+		if _, found := desired[key]; !found {
+			m.RemoveRRset([]dns.RR{key.rr()})
+		}
+	}
+
+	if err := p.update(m, record, zone); err != nil {
+		return err
+	}
+	p.logger.Info("Upserted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+func (p *Provider) Delete(record *v1.DNSRecord, zone v1.DNSZone) error {
+	published, err := rrsetsForEndpoints(record.Spec.Endpoints)
+	if err != nil {
+		return err
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zone.ID))
+	for _, key := range sortedKeys(published) {
+		m.RemoveRRset([]dns.RR{key.rr()})
+	}
+
+	if err := p.update(m, record, zone); err != nil {
+		return err
+	}
+	p.logger.Info("Deleted DNS record", "record", record.Spec, "zone", zone)
+	return nil
+}
+
+// ReconcileHealthCheck probes the endpoint with the prober, if any: dynamic
+// updates have no health check support.
+func (p *Provider) ReconcileHealthCheck(ctx context.Context, hc v1.EndpointHealthCheck, endpoint *v1.Endpoint) error {
+	if p.prober == nil {
+		p.logger.V(3).Info("Health checks are not supported by the RFC 2136 provider, skipping", "endpoint", endpoint.SetID())
+		return nil
+	}
+	return p.prober.ReconcileHealthCheck(ctx, hc, endpoint)
+}
+
+func (p *Provider) DeleteHealthCheck(ctx context.Context, endpoint *v1.Endpoint) error {
+	return p.prober.DeleteHealthCheck(ctx, endpoint)
+}
+
+func (p *Provider) HealthCheckStatus(ctx context.Context, endpoint *v1.Endpoint) (v1.EndpointHealth, error) {
+	return p.prober.HealthCheckStatus(ctx, endpoint)
+}
+
+func (p *Provider) EndpointHealth(endpoint *v1.Endpoint) v1.EndpointHealth {
+	return p.prober.EndpointHealth(endpoint)
+}
+
+func (p *Provider) OnHealthChange(listener func(id string)) {
+	p.prober.OnHealthChange(listener)
+}
+
+func (p *Provider) update(m *dns.Msg, record *v1.DNSRecord, zone v1.DNSZone) error {
+	if len(m.Ns) == 0 {
+		return nil
+	}
+	if p.config.TSIGKeyName != "" {
+		m.SetTsig(p.config.TSIGKeyName, p.config.TSIGAlgorithm, 300, time.Now().Unix())
+	}
+
+	resp, _, err := p.client.Exchange(m, p.config.Nameserver)
+	if err != nil {
+		return fmt.Errorf("couldn't update DNS record %s in zone %s: %v", record.Name, zone.ID, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("couldn't update DNS record %s in zone %s: server responded with %s", record.Name, zone.ID, dns.RcodeToString[resp.Rcode])
+	}
+	p.logger.Info("Updated DNS record", "record", record, "zone", zone.ID, "changes", len(m.Ns))
+	return nil
+}
+
+type rrsetKey struct {
+	name   string
+	rrtype uint16
+}
+
+// rr returns an empty RR identifying the RRset, as used in RRset deletions.
+func (k rrsetKey) rr() dns.RR {
+	return &dns.ANY{Hdr: dns.RR_Header{Name: k.name, Rrtype: k.rrtype, Class: dns.ClassINET}}
+}
+
+// rrsetsForEndpoints groups the records of the endpoints by name and type.
+func rrsetsForEndpoints(endpoints []*v1.Endpoint) (map[rrsetKey][]dns.RR, error) {
+	result := map[rrsetKey][]dns.RR{}
+	for _, endpoint := range endpoints {
+		if len(endpoint.DNSName) == 0 {
+			return nil, fmt.Errorf("domain is required")
+		}
+		if len(endpoint.Targets) == 0 {
+			return nil, fmt.Errorf("targets is required")
+		}
+
+		name := dns.Fqdn(endpoint.DNSName)
+		for _, target := range endpoint.Targets {
+			rr, err := rrForTarget(name, endpoint.RecordType, uint32(endpoint.RecordTTL), target)
+			if err != nil {
+				return nil, err
+			}
+			key := rrsetKey{name: name, rrtype: rr.Header().Rrtype}
+			result[key] = append(result[key], rr)
+		}
+	}
+	return result, nil
+}
+
+func rrForTarget(name, recordType string, ttl uint32, target string) (dns.RR, error) {
+	switch recordType {
+	case string(v1.ARecordType):
+		ip := net.ParseIP(target).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid A record target %s", target)
+		}
+		return &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   ip,
+		}, nil
+	case string(v1.AAAARecordType):
+		ip := net.ParseIP(target)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("invalid AAAA record target %s", target)
+		}
+		return &dns.AAAA{
+			Hdr:  dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ttl},
+			AAAA: ip,
+		}, nil
+	case string(v1.CNAMERecordType):
+		return &dns.CNAME{
+			Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: ttl},
+			Target: dns.Fqdn(target),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported record type %s", recordType)
+}
+
+func tsigAlgorithm(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSuffix(name, ".")) {
+	case "", "hmac-sha256":
+		return dns.HmacSHA256, nil
+	case "hmac-sha1":
+		return dns.HmacSHA1, nil
+	case "hmac-sha512":
+		return dns.HmacSHA512, nil
+	}
+	return "", fmt.Errorf("unsupported TSIG algorithm %s", name)
+}
+
+func sortedKeys(rrsets map[rrsetKey][]dns.RR) []rrsetKey {
+	keys := make([]rrsetKey, 0, len(rrsets))
+	for key := range rrsets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name == keys[j].name {
+			return keys[i].rrtype < keys[j].rrtype
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
@@ -0,0 +1,179 @@
+package rfc2136
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+const (
+	testKeyName = "glbc-key."
+	testSecret  = "c2VjcmV0LXRzaWcta2V5LWZvci10ZXN0aW5n"
+)
+
+// updateServer is a minimal authoritative server applying TSIG signed dynamic
+// updates to an in-memory zone.
+type updateServer struct {
+	mu     sync.Mutex
+	rrsets map[rrsetKey][]dns.RR
+}
+
+func (s *updateServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if r.IsTsig() == nil || w.TsigStatus() != nil {
+		m.Rcode = dns.RcodeNotAuth
+		_ = w.WriteMsg(m)
+		return
+	}
+	m.SetTsig(testKeyName, dns.HmacSHA256, 300, int64(r.IsTsig().TimeSigned))
+
+	for _, rr := range r.Ns {
+		h := rr.Header()
+		key := rrsetKey{name: h.Name, rrtype: h.Rrtype}
+		switch h.Class {
+		case dns.ClassANY:
+			delete(s.rrsets, key)
+		case dns.ClassINET:
+			s.rrsets[key] = append(s.rrsets[key], rr)
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func startUpdateServer(t *testing.T) (*updateServer, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	handler := &updateServer{rrsets: map[rrsetKey][]dns.RR{}}
+	started := make(chan struct{})
+	server := &dns.Server{
+		Listener:          listener,
+		Handler:           handler,
+		TsigSecret:        map[string]string{testKeyName: testSecret},
+		NotifyStartedFunc: func() { close(started) },
+		// The default accept func rejects UPDATE messages as not implemented
+		MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return handler, listener.Addr().String()
+}
+
+func TestProvider(t *testing.T) {
+	server, address := startUpdateServer(t)
+
+	provider, err := NewProvider(Config{
+		Nameserver:  address,
+		TSIGKeyName: "glbc-key",
+		TSIGSecret:  testSecret,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+
+	zone := v1.DNSZone{ID: "example.com"}
+	key := rrsetKey{name: "test.example.com.", rrtype: dns.TypeA}
+	record := &v1.DNSRecord{
+		Spec: v1.DNSRecordSpec{
+			Endpoints: []*v1.Endpoint{
+				{DNSName: "test.example.com", RecordType: "A", SetIdentifier: "192.168.0.1", Targets: v1.Targets{"192.168.0.1"}, RecordTTL: 60},
+				{DNSName: "test.example.com", RecordType: "A", SetIdentifier: "192.168.0.2", Targets: v1.Targets{"192.168.0.2"}, RecordTTL: 60},
+			},
+		},
+	}
+
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if got := len(server.rrsets[key]); got != 2 {
+		t.Fatalf("expected 2 records, got %d", got)
+	}
+
+	// Ensuring again replaces the RRset rather than appending to it
+	record.Spec.Endpoints = record.Spec.Endpoints[1:]
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if got := server.rrsets[key]; len(got) != 1 || got[0].(*dns.A).A.String() != "192.168.0.2" {
+		t.Fatalf("unexpected records %v", got)
+	}
+
+	// Stale endpoints recorded in the zone status are deleted
+	record.Status.Zones = []v1.DNSZoneStatus{{DNSZone: zone, Endpoints: record.Spec.Endpoints}}
+	record.Spec.Endpoints = []*v1.Endpoint{
+		{DNSName: "other.example.com", RecordType: "CNAME", Targets: v1.Targets{"lb.example.net"}, RecordTTL: 60},
+	}
+	if err := provider.Ensure(record, zone); err != nil {
+		t.Fatalf("unexpected error ensuring record: %v", err)
+	}
+	if _, ok := server.rrsets[key]; ok {
+		t.Fatalf("expected stale RRset %v to be deleted", key)
+	}
+	cname := server.rrsets[rrsetKey{name: "other.example.com.", rrtype: dns.TypeCNAME}]
+	if len(cname) != 1 || cname[0].(*dns.CNAME).Target != "lb.example.net." {
+		t.Fatalf("unexpected records %v", cname)
+	}
+
+	if err := provider.Delete(record, zone); err != nil {
+		t.Fatalf("unexpected error deleting record: %v", err)
+	}
+	if len(server.rrsets) != 0 {
+		t.Fatalf("expected zone to be empty, got %v", server.rrsets)
+	}
+
+	// Updates signed with the wrong key are refused
+	unauthorized, err := NewProvider(Config{
+		Nameserver:  address,
+		TSIGKeyName: "glbc-key",
+		TSIGSecret:  "d3Jvbmctc2VjcmV0",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	if err := unauthorized.Ensure(record, zone); err == nil {
+		t.Fatalf("expected an error for an update signed with the wrong key")
+	}
+}
+
+func TestRRForTarget(t *testing.T) {
+	cases := []struct {
+		Name       string
+		RecordType string
+		Target     string
+		Expected   uint16
+		ExpectErr  bool
+	}{
+		{Name: "a", RecordType: "A", Target: "192.168.0.1", Expected: dns.TypeA},
+		{Name: "a with ipv6 target", RecordType: "A", Target: "2001:db8::1", ExpectErr: true},
+		{Name: "aaaa", RecordType: "AAAA", Target: "2001:db8::1", Expected: dns.TypeAAAA},
+		{Name: "aaaa with ipv4 target", RecordType: "AAAA", Target: "192.168.0.1", ExpectErr: true},
+		{Name: "cname", RecordType: "CNAME", Target: "lb.example.net", Expected: dns.TypeCNAME},
+		{Name: "unsupported type", RecordType: "SRV", Target: "lb.example.net", ExpectErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			rr, err := rrForTarget("test.example.com.", tc.RecordType, 60, tc.Target)
+			if tc.ExpectErr != (err != nil) {
+				t.Fatalf("unexpected error value %v", err)
+			}
+			if err == nil && rr.Header().Rrtype != tc.Expected {
+				t.Fatalf("expected record type %d, got %d", tc.Expected, rr.Header().Rrtype)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+// Zone is a DNS zone the records of the DNS names within its domain are published to
+type Zone struct {
+	// Domain is the apex of the zone, e.g. dev.hcpapps.net. The zone holds the records of any DNS name when empty.
+	Domain string
+	v1.DNSZone
+}
+
+// Zones are the DNS zones the records are published to
+type Zones []Zone
+
+// ParseZones parses a comma separated list of zones, each as <domain>/<id>, e.g. dev.hcpapps.net/Z08652651232L9P84LRSB,
+// or as <domain>/<key>=<value>[;<key>=<value>] to reference the zone by tags, e.g. example.com/env=prod;team=dns.
+func ParseZones(value string) (Zones, error) {
+	var zones Zones
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid DNS zone %q, expected <domain>/<id> or <domain>/<key>=<value>[;<key>=<value>]", entry)
+		}
+		zone := Zone{Domain: normalizeDNSName(parts[0])}
+		if !strings.Contains(parts[1], "=") {
+			zone.ID = parts[1]
+		} else {
+			zone.Tags = map[string]string{}
+			for _, tag := range strings.Split(parts[1], ";") {
+				kv := strings.SplitN(tag, "=", 2)
+				if len(kv) != 2 || kv[0] == "" {
+					return nil, fmt.Errorf("invalid tag %q of DNS zone %q, expected <key>=<value>", tag, entry)
+				}
+				zone.Tags[kv[0]] = kv[1]
+			}
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// DNSZones returns the DNS zones
+func (z Zones) DNSZones() []v1.DNSZone {
+	dnsZones := make([]v1.DNSZone, 0, len(z))
+	for _, zone := range z {
+		dnsZones = append(dnsZones, zone.DNSZone)
+	}
+	return dnsZones
+}
+
+// ForDNSName returns the zone of the most specific domain holding dnsName, or nil if there is none
+func (z Zones) ForDNSName(dnsName string) *Zone {
+	dnsName = normalizeDNSName(dnsName)
+	var match *Zone
+	for i := range z {
+		zone := &z[i]
+		if zone.Domain != "" && dnsName != zone.Domain && !strings.HasSuffix(dnsName, "."+zone.Domain) {
+			continue
+		}
+		if match == nil || len(zone.Domain) > len(match.Domain) {
+			match = zone
+		}
+	}
+	return match
+}
+
+// Endpoints returns the endpoints to publish to zone, i.e. the endpoints it is the zone of the most specific domain of
+func (z Zones) Endpoints(zone v1.DNSZone, endpoints []*v1.Endpoint) []*v1.Endpoint {
+	var zoneEndpoints []*v1.Endpoint
+	for _, endpoint := range endpoints {
+		if match := z.ForDNSName(endpoint.DNSName); match != nil && reflect.DeepEqual(match.DNSZone, zone) {
+			zoneEndpoints = append(zoneEndpoints, endpoint)
+		}
+	}
+	return zoneEndpoints
+}
+
+func normalizeDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
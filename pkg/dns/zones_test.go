@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
+)
+
+func TestParseZones(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Value         string
+		ExpectErr     bool
+		ExpectedZones Zones
+	}{
+		{Name: "no zone", Value: ""},
+		{
+			Name:  "zones by ID and tags",
+			Value: "dev.hcpapps.net/Z08652651232L9P84LRSB, Example.com./env=prod;team=dns",
+			ExpectedZones: Zones{
+				{Domain: "dev.hcpapps.net", DNSZone: v1.DNSZone{ID: "Z08652651232L9P84LRSB"}},
+				{Domain: "example.com", DNSZone: v1.DNSZone{Tags: map[string]string{"env": "prod", "team": "dns"}}},
+			},
+		},
+		{Name: "missing zone", Value: "dev.hcpapps.net", ExpectErr: true},
+		{Name: "missing domain", Value: "/Z08652651232L9P84LRSB", ExpectErr: true},
+		{Name: "invalid tag", Value: "example.com/env=prod;team", ExpectErr: true},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			zones, err := ParseZones(testCase.Value)
+			if testCase.ExpectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got zones %v", zones)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(zones, testCase.ExpectedZones) {
+				t.Fatalf("expected zones %v, got %v", testCase.ExpectedZones, zones)
+			}
+		})
+	}
+}
+
+func TestZonesEndpoints(t *testing.T) {
+	zones := Zones{
+		{Domain: "hcpapps.net", DNSZone: v1.DNSZone{ID: "parent"}},
+		{Domain: "dev.hcpapps.net", DNSZone: v1.DNSZone{ID: "dev"}},
+		{Domain: "example.com", DNSZone: v1.DNSZone{Tags: map[string]string{"env": "prod"}}},
+	}
+	endpoints := []*v1.Endpoint{
+		{DNSName: "xyz.dev.hcpapps.net"},
+		{DNSName: "xyz.na.dev.hcpapps.net."},
+		{DNSName: "xyz.hcpapps.net"},
+		{DNSName: "www.example.com"},
+		{DNSName: "www.example.org"},
+		// Not within the dev.hcpapps.net domain
+		{DNSName: "xyzdev.hcpapps.net"},
+	}
+
+	expected := map[string][]string{
+		"parent": {"xyz.hcpapps.net", "xyzdev.hcpapps.net"},
+		"dev":    {"xyz.dev.hcpapps.net", "xyz.na.dev.hcpapps.net."},
+		"":       {"www.example.com"},
+	}
+	for _, zone := range zones {
+		var names []string
+		for _, endpoint := range zones.Endpoints(zone.DNSZone, endpoints) {
+			names = append(names, endpoint.DNSName)
+		}
+		if !reflect.DeepEqual(names, expected[zone.ID]) {
+			t.Errorf("expected zone %s endpoints %v, got %v", zone.Domain, expected[zone.ID], names)
+		}
+	}
+
+	if zone := zones.ForDNSName("www.example.org"); zone != nil {
+		t.Errorf("expected no zone for www.example.org, got %v", zone)
+	}
+	if zone := (Zones{{DNSZone: v1.DNSZone{ID: "any"}}}).ForDNSName("www.example.org"); zone == nil {
+		t.Errorf("expected the zone without domain to hold any DNS name")
+	}
+}
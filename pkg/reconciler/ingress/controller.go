@@ -62,6 +62,10 @@ func NewController(config *ControllerConfig) *Controller {
 		kuadrantClient:          config.DnsRecordClient,
 		domain:                  config.Domain,
 		hostResolver:            hostResolver,
+		recordVerifier:          config.RecordVerifier,
+		ttlBounds:               config.TTLBounds,
+		geoLocator:              config.GeoLocator,
+		dnsZones:                config.DNSZones,
 		hostsWatcher:            dns.NewHostsWatcher(&base.Logger, hostResolver, dns.DefaultInterval),
 		certInformerFactory:     config.CertificateInformer,
 		KuadrantInformerFactory: config.KuadrantInformer,
@@ -250,6 +254,10 @@ type ControllerConfig struct {
 	Domain                   string
 	CertProvider             tls.Provider
 	HostResolver             dns.HostResolver
+	RecordVerifier           dns.RecordVerifier
+	TTLBounds                traffic.TTLBounds
+	GeoLocator               dns.GeoLocator
+	DNSZones                 dns.Zones
 	GLBCWorkspace            logicalcluster.Name
 }
 
@@ -265,6 +273,10 @@ type Controller struct {
 	certProvider            tls.Provider
 	domain                  string
 	hostResolver            dns.HostResolver
+	recordVerifier          dns.RecordVerifier
+	ttlBounds               traffic.TTLBounds
+	geoLocator              dns.GeoLocator
+	dnsZones                dns.Zones
 	hostsWatcher            *dns.HostsWatcher
 	certInformerFactory     certmaninformer.SharedInformerFactory
 	glbcInformerFactory     informers.SharedInformerFactory
@@ -67,6 +67,10 @@ func NewController(config *ControllerConfig) *Controller {
 		domain:                       config.Domain,
 		glbcWorkspace:                config.GLBCWorkspace,
 		hostResolver:                 hostResolver,
+		recordVerifier:               config.RecordVerifier,
+		ttlBounds:                    config.TTLBounds,
+		geoLocator:                   config.GeoLocator,
+		dnsZones:                     config.DNSZones,
 		hostsWatcher:                 dns.NewHostsWatcher(&base.Logger, hostResolver, dns.DefaultInterval),
 		certInformerFactory:          config.CertificateInformer,
 		KCPInformerFactory:           config.KCPInformer,
@@ -271,6 +275,10 @@ type ControllerConfig struct {
 	Domain                          string
 	CertProvider                    tls.Provider
 	HostResolver                    dns.HostResolver
+	RecordVerifier                  dns.RecordVerifier
+	TTLBounds                       traffic.TTLBounds
+	GeoLocator                      dns.GeoLocator
+	DNSZones                        dns.Zones
 	GLBCWorkspace                   logicalcluster.Name
 }
 
@@ -287,6 +295,10 @@ type Controller struct {
 	certProvider                 tls.Provider
 	domain                       string
 	hostResolver                 dns.HostResolver
+	recordVerifier               dns.RecordVerifier
+	ttlBounds                    traffic.TTLBounds
+	geoLocator                   dns.GeoLocator
+	dnsZones                     dns.Zones
 	hostsWatcher                 *dns.HostsWatcher
 	certInformerFactory          certmaninformer.SharedInformerFactory
 	glbcInformerFactory          informers.SharedInformerFactory
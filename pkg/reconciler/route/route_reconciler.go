@@ -37,6 +37,10 @@ func (c *Controller) reconcile(ctx context.Context, route *traffic.Route) error
 			ManagedDomain:    c.domain,
 			Log:              c.Logger,
 			DNSLookup:        c.hostResolver.LookupIPAddr,
+			RecordVerifier:   c.recordVerifier,
+			TTLBounds:        c.ttlBounds,
+			GeoLocator:       c.geoLocator,
+			DNSZones:         c.dnsZones,
 		},
 		&traffic.HostReconciler{
 			Log:                    c.Logger,
@@ -2,16 +2,16 @@ package traffic
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/kcp-dev/logicalcluster/v2"
-	"github.com/lixiangzhong/dnsutil"
 
 	"k8s.io/apimachinery/pkg/api/equality"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
@@ -37,12 +37,20 @@ type DnsReconciler struct {
 	GetDNS           func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error)
 	CreateDNS        func(ctx context.Context, dns *v1.DNSRecord) (*v1.DNSRecord, error)
 	UpdateDNS        func(ctx context.Context, dns *v1.DNSRecord) (*v1.DNSRecord, error)
-	WatchHost        func(ctx context.Context, key interface{}, host string) bool
+	WatchHost        func(ctx context.Context, key interface{}, host string, recordTTL time.Duration) bool
 	ForgetHost       func(key interface{}, host string)
 	ListHostWatchers func(key interface{}) []dns.RecordWatcher
 	Log              logr.Logger
 	ManagedDomain    string
 	DNSLookup        func(ctx context.Context, host string) ([]dns.HostAddress, error)
+	// GeoLocator locates the clusters of the targets. Geo aware DNS is disabled when nil
+	GeoLocator dns.GeoLocator
+	// DNSZones the records are published to
+	DNSZones dns.Zones
+	// RecordVerifier verifies the records published before their propagation was tracked
+	RecordVerifier dns.RecordVerifier
+	// TTLBounds bound the TTL of the records set with the ANNOTATION_TTL annotation
+	TTLBounds TTLBounds
 }
 
 func (r *DnsReconciler) GetName() string {
@@ -98,15 +106,28 @@ func (r *DnsReconciler) Reconcile(ctx context.Context, accessor Interface) (Reco
 	if err != nil {
 		return ReconcileStatusContinue, err
 	}
+	policy, primary := r.routingPolicy(accessor)
+	ttl := r.recordTTL(accessor)
+	// AWS load balancer hostnames are published as Route53 alias records rather than being resolved, when enabled.
+	// The failover records group the IPs of the clusters, so the hostnames are always resolved with that policy.
+	aliasLBHosts := metadata.GetAnnotation(accessor, ANNOTATION_AWS_ALIAS) == "true" && policy != RoutingPolicyFailover
+	hosts := map[string]*targetHost{}
 	var activeLBHosts []string
 	for _, target := range targets {
 		host := target.Value
+		isAlias := aliasLBHosts && target.TargetType != dns.TargetTypeIP && aws.IsLoadBalancerHostname(host)
+		hosts[host] = &targetHost{
+			cluster: target.Cluster,
+			alias:   isAlias,
+			weight:  r.clusterWeight(accessor, target.Cluster),
+			primary: primary != "" && target.Cluster == primary,
+		}
 		deleteAnnotation := workload.InternalClusterDeletionTimestampAnnotationPrefix + target.Cluster
 		if metadata.HasAnnotation(accessor, deleteAnnotation) {
 			deletingTargetIPs[host] = append(deletingTargetIPs[host], host)
 			continue
 		}
-		if target.TargetType == dns.TargetTypeIP {
+		if target.TargetType == dns.TargetTypeIP || isAlias {
 			activeDNSTargetIPs[host] = append(activeDNSTargetIPs[host], host)
 			continue
 		}
@@ -121,7 +142,7 @@ func (r *DnsReconciler) Reconcile(ctx context.Context, accessor Interface) (Reco
 		}
 		//add the host to host watcher to keep our DNS upto date
 		// If it is not an IP we add it to the host watcher that triggers an update when it gets IPS
-		r.WatchHost(ctx, key, host)
+		r.WatchHost(ctx, key, host, time.Duration(ttl)*time.Second)
 		activeLBHosts = append(activeLBHosts, host)
 	}
 
@@ -138,8 +159,14 @@ func (r *DnsReconciler) Reconcile(ctx context.Context, accessor Interface) (Reco
 		r.Log.V(3).Info("setting the dns Target to the deleting Target as no new dns targets set yet")
 		activeDNSTargetIPs = deletingTargetIPs
 	}
+	if err := r.locateHosts(ctx, hosts); err != nil {
+		return ReconcileStatusContinue, err
+	}
 	copyDNS := existing.DeepCopy()
-	r.setEndpointFromTargets(managedHost, activeDNSTargetIPs, copyDNS)
+	r.setEndpointFromTargets(managedHost, policy, activeDNSTargetIPs, hosts, copyDNS)
+	setEndpointsCluster(copyDNS, activeDNSTargetIPs, hosts)
+	setEndpointsTTL(copyDNS, ttl)
+	setEndpointsHealthCheckRef(copyDNS, metadata.GetAnnotation(accessor, ANNOTATION_HEALTH_CHECK_REF))
 	objMeta, err := meta.Accessor(accessor)
 	if err != nil {
 		return ReconcileStatusContinue, err
@@ -156,21 +183,28 @@ func (r *DnsReconciler) Reconcile(ctx context.Context, accessor Interface) (Reco
 		}
 	}
 
-	host := r.ManagedDomain
-	zoneID, _ := os.LookupEnv(aws.ZoneIDEnvVar)
-	dnsZone := &v1.DNSZone{
-		ID: zoneID,
+	if err := setClusterHealth(accessor, existing.Status.Clusters); err != nil {
+		return ReconcileStatusContinue, err
 	}
 
+	dnsZone := r.DNSZones.ForDNSName(managedHost)
+
 	// Once we know the DNS is created up and TMC is enabled for this ingress (IE status is stored in annotations) set the DNS load balancer in the ingress status.
 	if accessor.TMCEnabled() {
-		if !accessor.HasDNSLBHost() && len(copyDNS.Spec.Endpoints) > 0 && equality.Semantic.DeepEqual(copyDNS, existing) && dns.RecordIsAlreadyPublishedToZone(copyDNS, dnsZone) {
-			foundIPAddress := foundNameserversOfDomainAndIP(host, managedHost)
-			if foundIPAddress {
-				fmt.Print(" Setting DNS LB host to ingress status ")
+		if !accessor.HasDNSLBHost() && len(copyDNS.Spec.Endpoints) > 0 && equality.Semantic.DeepEqual(copyDNS, existing) && copyDNS.Status.ObservedGeneration == copyDNS.Generation && dnsZone != nil && dns.RecordIsAlreadyPublishedToZone(copyDNS, &dnsZone.DNSZone) {
+			// The changes are propagated once the provider reports them in sync with the authoritative nameservers.
+			// The records published before their propagation was tracked are verified against the nameservers.
+			propagated, tracked := dns.RecordIsPropagatedToZone(copyDNS, &dnsZone.DNSZone)
+			if !tracked && r.RecordVerifier != nil {
+				result := r.RecordVerifier.Verify(ctx, copyDNS.Spec.Endpoints)
+				r.Log.V(3).Info("verified DNS records", "host", managedHost, "verified", result.Verified(), "result", result.String())
+				propagated = result.Verified()
+			}
+			if propagated {
+				r.Log.V(3).Info("setting DNS LB host to traffic object status", "host", managedHost, "object", accessor.GetName())
 				accessor.SetDNSLBHost(managedHost)
 			} else {
-				fmt.Print(" IP address not yet found when digging managedHost ", managedHost, " against its nameservers. ")
+				r.Log.V(3).Info("DNS records not yet propagated", "host", managedHost, "object", accessor.GetName())
 				return ReconcileStatusRequeueIn5Seconds, nil
 			}
 		}
@@ -227,68 +261,457 @@ func copyHealthAnnotations(dnsRecord *v1.DNSRecord, objectMeta metav1.Object) {
 	}))
 }
 
-// foundNameserversOfDomainAndIP looks up for nameservers of a given domain, and performs a dig of the managed host against
-// the nameservers. It returns true if at least one A record is found.
-func foundNameserversOfDomainAndIP(host, managedHost string) bool {
-	nameservers, _ := net.LookupNS(host)
-	var dig dnsutil.Dig
-	var found bool
-	if len(nameservers) < 1 {
-		found = false
-	} else {
-		for _, ns := range nameservers {
-			_ = dig.At(ns.Host)
-			a, _ := dig.A(managedHost)
-			if a != nil {
-				found = true
-				break
-			}
+// targetHost holds how the addresses of a traffic target host are published
+type targetHost struct {
+	// cluster the host is exposed from
+	cluster string
+	// alias is set for AWS load balancer hostnames, published as Route53 alias records evaluating the health of
+	// the load balancer
+	alias bool
+	// geo is the location of the cluster, set when the cluster is located
+	geo *dns.Geo
+	// weight is the relative weight of the cluster, DefaultClusterWeight when nil
+	weight *int
+	// primary is set for the hosts of the primary cluster of the failover routing policy
+	primary bool
+}
+
+// routingPolicy returns the routing policy of the traffic object, and the primary cluster of the failover routing
+// policy. The latency routing policy requires geo aware DNS, and the failover routing policy requires a primary
+// cluster and health checks, otherwise the weighted routing policy is used.
+func (r *DnsReconciler) routingPolicy(accessor Interface) (RoutingPolicy, string) {
+	policy := RoutingPolicy(metadata.GetAnnotation(accessor, ANNOTATION_ROUTING_POLICY))
+	// The regions of the clusters are only located when geo aware DNS is enabled, with the aws DNS provider
+	if policy == RoutingPolicyLatency && r.GeoLocator == nil {
+		r.Log.Error(fmt.Errorf("geo aware DNS is required"), "using the weighted routing policy", "object", accessor.GetName())
+		return RoutingPolicyWeighted, ""
+	}
+	if policy != RoutingPolicyFailover {
+		return policy, ""
+	}
+	primary := metadata.GetAnnotation(accessor, ANNOTATION_FAILOVER_PRIMARY)
+	if primary == "" {
+		r.Log.Error(fmt.Errorf("the %s annotation is required", ANNOTATION_FAILOVER_PRIMARY), "using the weighted routing policy", "object", accessor.GetName())
+		return RoutingPolicyWeighted, ""
+	}
+	// The TCP health checks are configured without endpoint
+	healthChecked := metadata.HasAnnotation(accessor, ANNOTATION_HEALTH_CHECK_PREFIX+"endpoint") ||
+		metadata.GetAnnotation(accessor, ANNOTATION_HEALTH_CHECK_PREFIX+"protocol") == string(v1.HealthCheckProtocolTCP) ||
+		metadata.HasAnnotation(accessor, ANNOTATION_HEALTH_CHECK_REF)
+	if !healthChecked {
+		r.Log.Error(fmt.Errorf("health checks are required"), "using the weighted routing policy", "object", accessor.GetName())
+		return RoutingPolicyWeighted, ""
+	}
+	return policy, primary
+}
+
+// DefaultTTL is the TTL of the records of the traffic objects without a TTL annotation, in seconds
+const DefaultTTL = 60
+
+// TTLBounds are the minimum and maximum TTL of the records, in seconds. The TTL isn't bounded by a zero value.
+type TTLBounds struct {
+	Min v1.TTL
+	Max v1.TTL
+}
+
+// Bound returns the TTL within the bounds
+func (b TTLBounds) Bound(ttl v1.TTL) v1.TTL {
+	if ttl < b.Min {
+		return b.Min
+	}
+	if b.Max > 0 && ttl > b.Max {
+		return b.Max
+	}
+	return ttl
+}
+
+// recordTTL returns the TTL of the records set with the ANNOTATION_TTL annotation of the traffic object, or
+// DefaultTTL if it is not set or invalid, within the TTL bounds.
+func (r *DnsReconciler) recordTTL(accessor Interface) v1.TTL {
+	ttl := v1.TTL(DefaultTTL)
+	if value := metadata.GetAnnotation(accessor, ANNOTATION_TTL); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err != nil || parsed < 0 {
+			r.Log.Error(fmt.Errorf("invalid TTL %q, must be a non negative number of seconds", value), "using the default TTL", "object", accessor.GetName())
+		} else {
+			ttl = v1.TTL(parsed)
+		}
+	}
+	if bounded := r.TTLBounds.Bound(ttl); bounded != ttl {
+		r.Log.Info("TTL out of bounds", "ttl", ttl, "min", r.TTLBounds.Min, "max", r.TTLBounds.Max, "bounded", bounded, "object", accessor.GetName())
+		ttl = bounded
+	}
+	return ttl
+}
+
+// setEndpointsTTL sets the TTL of the endpoints of the record
+func setEndpointsTTL(dnsRecord *v1.DNSRecord, ttl v1.TTL) {
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		endpoint.RecordTTL = ttl
+	}
+}
+
+// setEndpointsHealthCheckRef sets the HealthCheck the A and AAAA records of the record are health checked with, set with the
+// ANNOTATION_HEALTH_CHECK_REF annotation of the traffic object, or removes it when name is empty. The health of the
+// alias records is evaluated by the provider.
+func setEndpointsHealthCheckRef(dnsRecord *v1.DNSRecord, name string) {
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		_, alias := endpoint.GetProviderSpecific(aws.ProviderSpecificAlias)
+		if name == "" || alias || !isAddressRecord(endpoint) {
+			endpoint.HealthCheckRef = nil
+			continue
+		}
+		endpoint.HealthCheckRef = &v1.HealthCheckReference{Name: name}
+	}
+}
 
+// setEndpointsCluster labels the A and AAAA records of the record with the cluster exposing their targets, so that their
+// health is reported per cluster. The label is removed from the records targeting several clusters, e.g. the
+// secondary record of the failover routing policy.
+func setEndpointsCluster(dnsRecord *v1.DNSRecord, dnsTargets map[string][]string, hosts map[string]*targetHost) {
+	clusters := map[string]string{}
+	for host, targets := range dnsTargets {
+		if hosts[host] == nil {
+			continue
 		}
+		for _, target := range targets {
+			clusters[target] = hosts[host].cluster
+		}
+	}
 
+	for _, endpoint := range dnsRecord.Spec.Endpoints {
+		cluster := ""
+		if isAddressRecord(endpoint) && len(endpoint.Targets) > 0 {
+			cluster = clusters[endpoint.Targets[0]]
+			for _, target := range endpoint.Targets[1:] {
+				if clusters[target] != cluster {
+					cluster = ""
+					break
+				}
+			}
+		}
+		if cluster == "" {
+			delete(endpoint.Labels, v1.ClusterLabel)
+			if len(endpoint.Labels) == 0 {
+				endpoint.Labels = nil
+			}
+			continue
+		}
+		if endpoint.Labels == nil {
+			endpoint.Labels = v1.Labels{}
+		}
+		endpoint.Labels[v1.ClusterLabel] = cluster
 	}
-	return found
 }
 
-func (r *DnsReconciler) setEndpointFromTargets(dnsName string, dnsTargets map[string][]string, dnsRecord *v1.DNSRecord) {
+// setClusterHealth sets the health of the clusters reported in the DNSRecord status to the ANNOTATION_CLUSTER_HEALTH
+// annotation of the traffic object, or removes it when no cluster health is reported.
+func setClusterHealth(accessor Interface, clusters []v1.ClusterHealth) error {
+	if len(clusters) == 0 {
+		metadata.RemoveAnnotation(accessor, ANNOTATION_CLUSTER_HEALTH)
+		return nil
+	}
+	value, err := json.Marshal(clusters)
+	if err != nil {
+		return err
+	}
+	metadata.AddAnnotation(accessor, ANNOTATION_CLUSTER_HEALTH, string(value))
+	return nil
+}
+
+// DefaultClusterWeight is the relative weight of the clusters without a weight annotation
+const DefaultClusterWeight = 100
+
+func (h *targetHost) clusterWeight() int {
+	if h == nil || h.weight == nil {
+		return DefaultClusterWeight
+	}
+	return *h.weight
+}
+
+// clusterWeight returns the relative weight of a cluster set with the ANNOTATION_WEIGHT_PREFIX annotation of the
+// traffic object, or nil if it is not set or invalid.
+func (r *DnsReconciler) clusterWeight(accessor Interface, cluster string) *int {
+	value := metadata.GetAnnotation(accessor, ANNOTATION_WEIGHT_PREFIX+cluster)
+	if value == "" {
+		return nil
+	}
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 0 {
+		r.Log.Error(fmt.Errorf("invalid weight %q, must be a non negative integer", value), "ignoring cluster weight", "cluster", cluster, "object", accessor.GetName())
+		return nil
+	}
+	return &weight
+}
+
+// locateHosts sets the location of the cluster of each host, when a GeoLocator is configured.
+func (r *DnsReconciler) locateHosts(ctx context.Context, hosts map[string]*targetHost) error {
+	if r.GeoLocator == nil {
+		return nil
+	}
+	for _, host := range hosts {
+		geo, err := r.GeoLocator.Geo(ctx, host.cluster)
+		if err != nil {
+			return fmt.Errorf("failed to locate cluster %s: %v", host.cluster, err)
+		}
+		host.geo = geo
+	}
+	return nil
+}
+
+// setEndpointFromTargets sets a weighted A record endpoint for each target. The weights are split across the IPs of
+// each host, proportionally to the relative weight of its cluster.
+//
+// When the clusters of all the targets are located, the A records are published to a host dedicated to the
+// location of their cluster, and CNAME records routing dnsName to the location hosts are published, according to
+// the routing policy:
+//   - weighted, the DNS is geo aware: the location is the continent of the cluster, e.g. xyz.na.dev.hcpapps.net, and
+//     a CNAME record with a geolocation routing policy is published for each continent. A default CNAME record is
+//     published for the queries from the other continents.
+//   - latency: the location is the region of the cluster, e.g. xyz.us-east-1.dev.hcpapps.net, and a CNAME record
+//     with a latency routing policy is published for each region.
+//
+// With the failover routing policy, the primary and secondary A records returned by failoverEndpoints are set
+// instead, unless the primary cluster has no target.
+func (r *DnsReconciler) setEndpointFromTargets(dnsName string, policy RoutingPolicy, dnsTargets map[string][]string, hosts map[string]*targetHost, dnsRecord *v1.DNSRecord) {
 	currentEndpoints := make(map[string]*v1.Endpoint, len(dnsRecord.Spec.Endpoints))
 	for _, endpoint := range dnsRecord.Spec.Endpoints {
-		address, ok := endpoint.GetAddress()
-		if !ok {
+		if _, ok := endpoint.GetAddress(); !ok {
 			continue
 		}
-		currentEndpoints[address] = endpoint
+		currentEndpoints[endpointKey(endpoint.DNSName, endpoint.RecordType, endpoint.SetIdentifier)] = endpoint
+	}
+
+	if policy == RoutingPolicyFailover {
+		if endpoints := failoverEndpoints(dnsName, dnsTargets, hosts, currentEndpoints); endpoints != nil {
+			dnsRecord.Spec.Endpoints = endpoints
+			return
+		}
+	}
+
+	location := func(geo *dns.Geo) string {
+		return geo.ContinentCode
 	}
+	if policy == RoutingPolicyLatency {
+		location = func(geo *dns.Geo) string {
+			return geo.Region
+		}
+	}
+	located := len(dnsTargets) > 0
+	for host := range dnsTargets {
+		if h, ok := hosts[host]; !ok || h.geo == nil || location(h.geo) == "" {
+			located = false
+			break
+		}
+	}
+
+	maxClusterWeight := 0
+	for host := range dnsTargets {
+		if weight := hosts[host].clusterWeight(); weight > maxClusterWeight {
+			maxClusterWeight = weight
+		}
+	}
+
 	var (
 		newEndpoints []*v1.Endpoint
 		endpoint     *v1.Endpoint
 	)
+	// The number of A records published to each location host
+	locationHosts := map[string]int{}
 	ok := false
-	for _, targets := range dnsTargets {
+	for host, targets := range dnsTargets {
+		targetDNSName := dnsName
+		if located {
+			loc := location(hosts[host].geo)
+			targetDNSName = locationHost(dnsName, loc)
+			locationHosts[loc] += len(targets)
+		}
+		alias := hosts[host] != nil && hosts[host].alias
 		for _, target := range targets {
+			recordType := addressRecordType(target)
 			// If the endpoint for this target does not exist, add a new one
-			if endpoint, ok = currentEndpoints[target]; !ok {
+			if endpoint, ok = currentEndpoints[endpointKey(targetDNSName, recordType, target)]; !ok {
 				endpoint = &v1.Endpoint{
 					SetIdentifier: target,
 				}
 			}
 			// Update the endpoint fields
-			endpoint.DNSName = dnsName
-			endpoint.RecordType = "A"
+			endpoint.DNSName = targetDNSName
+			endpoint.RecordType = recordType
+			// The records of the location hosts are health checked with the managed host
+			endpoint.DeleteProviderSpecific(aws.ProviderSpecificHealthCheckHost)
+			if targetDNSName != dnsName {
+				endpoint.SetProviderSpecific(aws.ProviderSpecificHealthCheckHost, dnsName)
+			}
 			endpoint.Targets = []string{target}
-			endpoint.RecordTTL = 60
-			endpoint.SetProviderSpecific(aws.ProviderSpecificWeight, awsEndpointWeight(len(targets)))
+			endpoint.RecordTTL = DefaultTTL
+			endpoint.SetProviderSpecific(aws.ProviderSpecificWeight, awsClusterEndpointWeight(hosts[host].clusterWeight(), maxClusterWeight, len(targets)))
+			endpoint.DeleteProviderSpecific(aws.ProviderSpecificFailover)
+			if alias {
+				endpoint.SetProviderSpecific(aws.ProviderSpecificAlias, "true")
+				endpoint.SetProviderSpecific(aws.ProviderSpecificEvaluateTargetHealth, "true")
+			} else {
+				endpoint.DeleteProviderSpecific(aws.ProviderSpecificAlias)
+				endpoint.DeleteProviderSpecific(aws.ProviderSpecificEvaluateTargetHealth)
+			}
 			newEndpoints = append(newEndpoints, endpoint)
 		}
 	}
+	if policy == RoutingPolicyLatency {
+		newEndpoints = append(newEndpoints, latencyEndpoints(dnsName, locationHosts)...)
+	} else {
+		newEndpoints = append(newEndpoints, geoEndpoints(dnsName, locationHosts)...)
+	}
 
 	sort.Slice(newEndpoints, func(i, j int) bool {
+		if newEndpoints[i].Targets[0] == newEndpoints[j].Targets[0] {
+			return newEndpoints[i].SetIdentifier < newEndpoints[j].SetIdentifier
+		}
 		return newEndpoints[i].Targets[0] < newEndpoints[j].Targets[0]
 	})
 
 	dnsRecord.Spec.Endpoints = newEndpoints
 }
 
+// failoverEndpoints returns the records of the failover routing policy: a PRIMARY record with the IPs of the
+// primary cluster, and a SECONDARY record with the IPs of the other clusters, if any. Route53 only allows a single
+// PRIMARY and SECONDARY record per name and type, so the IPv4 and IPv6 addresses are split into A and AAAA records.
+// The records are health checked on their first IP only, so the other IPs of the primary cluster are served while
+// the first one is healthy. It returns nil when the primary cluster has no target.
+func failoverEndpoints(dnsName string, dnsTargets map[string][]string, hosts map[string]*targetHost, currentEndpoints map[string]*v1.Endpoint) []*v1.Endpoint {
+	var primaryTargets, secondaryTargets []string
+	for host, targets := range dnsTargets {
+		if hosts[host] != nil && hosts[host].primary {
+			primaryTargets = append(primaryTargets, targets...)
+		} else {
+			secondaryTargets = append(secondaryTargets, targets...)
+		}
+	}
+	if len(primaryTargets) == 0 {
+		return nil
+	}
+
+	var endpoints []*v1.Endpoint
+	for _, failover := range []struct {
+		name    string
+		targets []string
+	}{{aws.FailoverPrimary, primaryTargets}, {aws.FailoverSecondary, secondaryTargets}} {
+		byType := map[string][]string{}
+		for _, target := range failover.targets {
+			recordType := addressRecordType(target)
+			byType[recordType] = append(byType[recordType], target)
+		}
+		for _, recordType := range []string{string(v1.ARecordType), string(v1.AAAARecordType)} {
+			if targets := byType[recordType]; len(targets) > 0 {
+				endpoints = append(endpoints, failoverEndpoint(dnsName, failover.name, recordType, targets, currentEndpoints))
+			}
+		}
+	}
+	return endpoints
+}
+
+func failoverEndpoint(dnsName, failover, recordType string, targets []string, currentEndpoints map[string]*v1.Endpoint) *v1.Endpoint {
+	setIdentifier := strings.ToLower(failover)
+	endpoint, ok := currentEndpoints[endpointKey(dnsName, recordType, setIdentifier)]
+	if !ok {
+		endpoint = &v1.Endpoint{
+			SetIdentifier: setIdentifier,
+		}
+	}
+	sort.Strings(targets)
+	endpoint.DNSName = dnsName
+	endpoint.RecordType = recordType
+	endpoint.Targets = targets
+	endpoint.RecordTTL = DefaultTTL
+	endpoint.DeleteProviderSpecific(aws.ProviderSpecificWeight)
+	endpoint.SetProviderSpecific(aws.ProviderSpecificFailover, failover)
+	return endpoint
+}
+
+// isAddressRecord returns whether the endpoint is an A or AAAA record
+func isAddressRecord(endpoint *v1.Endpoint) bool {
+	return endpoint.RecordType == string(v1.ARecordType) || endpoint.RecordType == string(v1.AAAARecordType)
+}
+
+// endpointKey identifies the current endpoints updated with the targets
+func endpointKey(dnsName, recordType, setIdentifier string) string {
+	return dnsName + "/" + recordType + "/" + setIdentifier
+}
+
+// addressRecordType returns the type of the records of the target address: AAAA for the IPv6 addresses, and A
+// otherwise, including the AWS load balancer hostnames of the alias records.
+func addressRecordType(target string) string {
+	if ip := net.ParseIP(target); ip != nil && ip.To4() == nil {
+		return string(v1.AAAARecordType)
+	}
+	return string(v1.ARecordType)
+}
+
+// geoEndpoints returns the CNAME records routing dnsName to the continent hosts, by the continent the queries
+// originate from. The default record, for the queries from the other continents, points to the continent host
+// with the most records.
+func geoEndpoints(dnsName string, continentHosts map[string]int) []*v1.Endpoint {
+	if len(continentHosts) == 0 {
+		return nil
+	}
+	var endpoints []*v1.Endpoint
+	defaultCode := ""
+	for code, count := range continentHosts {
+		if defaultCode == "" || count > continentHosts[defaultCode] || (count == continentHosts[defaultCode] && code < defaultCode) {
+			defaultCode = code
+		}
+		endpoint := &v1.Endpoint{
+			DNSName:       dnsName,
+			RecordType:    string(v1.CNAMERecordType),
+			SetIdentifier: code,
+			Targets:       []string{locationHost(dnsName, code)},
+			RecordTTL:     DefaultTTL,
+			Labels:        v1.Labels{"id": code},
+		}
+		endpoint.SetProviderSpecific(aws.ProviderSpecificGeolocationContinentCode, code)
+		endpoints = append(endpoints, endpoint)
+	}
+
+	endpoint := &v1.Endpoint{
+		DNSName:       dnsName,
+		RecordType:    string(v1.CNAMERecordType),
+		SetIdentifier: "default",
+		Targets:       []string{locationHost(dnsName, defaultCode)},
+		RecordTTL:     DefaultTTL,
+		Labels:        v1.Labels{"id": "default"},
+	}
+	endpoint.SetProviderSpecific(aws.ProviderSpecificGeolocationCountryCode, "*")
+	return append(endpoints, endpoint)
+}
+
+// latencyEndpoints returns the CNAME records routing dnsName to the region hosts, by the latency between the
+// regions and the queries origin.
+func latencyEndpoints(dnsName string, regionHosts map[string]int) []*v1.Endpoint {
+	var endpoints []*v1.Endpoint
+	for region := range regionHosts {
+		endpoint := &v1.Endpoint{
+			DNSName:       dnsName,
+			RecordType:    string(v1.CNAMERecordType),
+			SetIdentifier: region,
+			Targets:       []string{locationHost(dnsName, region)},
+			RecordTTL:     DefaultTTL,
+			Labels:        v1.Labels{"id": region},
+		}
+		endpoint.SetProviderSpecific(aws.ProviderSpecificRegion, region)
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// locationHost returns the host dedicated to a location, e.g. xyz.na.dev.hcpapps.net for xyz.dev.hcpapps.net
+// and the NA continent code, or xyz.us-east-1.dev.hcpapps.net for the us-east-1 region.
+func locationHost(dnsName, location string) string {
+	parts := strings.SplitN(dnsName, ".", 2)
+	if len(parts) < 2 {
+		return fmt.Sprintf("%s.%s", dnsName, strings.ToLower(location))
+	}
+	return fmt.Sprintf("%s.%s.%s", parts[0], strings.ToLower(location), parts[1])
+}
+
 // awsEndpointWeight returns the weight Value for a single AWS record in a set of records where the traffic is split
 // evenly between a number of clusters/ingresses, each splitting traffic evenly to a number of IPs (numIPs)
 //
@@ -299,7 +722,23 @@ func (r *DnsReconciler) setEndpointFromTargets(dnsName string, dnsTargets map[st
 // The aws weight value must be an integer between 0 and 255.
 // https://docs.aws.amazon.com/Route53/latest/DeveloperGuide/resource-record-sets-values-weighted.html#rrsets-values-weighted-weight
 func awsEndpointWeight(numIPs int) string {
-	maxWeight := 120
+	return awsClusterEndpointWeight(DefaultClusterWeight, DefaultClusterWeight, numIPs)
+}
+
+// awsClusterEndpointWeight returns the weight Value for a single AWS record of a cluster/ingress splitting traffic
+// evenly to a number of IPs (numIPs), where the weight allowance of the cluster is proportional to its relative
+// weight (clusterWeight) and the highest relative weight of the clusters (maxClusterWeight).
+//
+// A cluster with a relative weight of 0 is drained: its records are kept with a weight of 0, and only answered when
+// all the records have a weight of 0. Otherwise the weight of its records is at least 1.
+func awsClusterEndpointWeight(clusterWeight, maxClusterWeight, numIPs int) string {
+	if clusterWeight <= 0 || maxClusterWeight <= 0 {
+		return "0"
+	}
+	maxWeight := 120 * clusterWeight / maxClusterWeight
+	if maxWeight < 1 {
+		maxWeight = 1
+	}
 	if numIPs > maxWeight {
 		numIPs = maxWeight
 	}
@@ -9,6 +9,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"net"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -20,6 +21,7 @@ import (
 	"github.com/kuadrant/kcp-glbc/pkg/_internal/slice"
 	v1 "github.com/kuadrant/kcp-glbc/pkg/apis/kuadrant/v1"
 	"github.com/kuadrant/kcp-glbc/pkg/dns"
+	"github.com/kuadrant/kcp-glbc/pkg/dns/aws"
 )
 
 type validatedDNSClient struct {
@@ -55,7 +57,7 @@ func TestDNSReconciler(t *testing.T) {
 	managedHost := "test.cb.example.com"
 
 	// sets up 2 ingresses one with status in the advanced scheduling annotation and one in the regular status block
-	setupAccessors := func(ingressStatus networkingv1.IngressStatus) []Interface {
+	setupAccessors := func(ingressStatus networkingv1.IngressStatus, annotations map[string]string) []Interface {
 		var accessors []Interface
 		for i := 0; i <= 1; i++ {
 			ing := &networkingv1.Ingress{
@@ -66,6 +68,9 @@ func TestDNSReconciler(t *testing.T) {
 					},
 				},
 			}
+			for k, v := range annotations {
+				ing.Annotations[k] = v
+			}
 			rule := networkingv1.IngressRule{
 				Host: managedHost,
 				IngressRuleValue: networkingv1.IngressRuleValue{
@@ -92,7 +97,7 @@ func TestDNSReconciler(t *testing.T) {
 		return []dns.RecordWatcher{}
 	}
 
-	commonDNSValidate := func(expectedIPs []string) func(dns *v1.DNSRecord) error {
+	commonDNSValidate := func(expectedIPs []string, expectedProviderSpecific map[string]string) func(dns *v1.DNSRecord) error {
 		return func(dns *v1.DNSRecord) error {
 			if dns == nil {
 				return fmt.Errorf("did not expect a nil dns record")
@@ -104,14 +109,19 @@ func TestDNSReconciler(t *testing.T) {
 				if len(ep.Targets) != len(expectedIPs) {
 					return fmt.Errorf("expected only 1 dns Target but got %d", len(ep.Targets))
 				}
-				if ep.RecordType != "A" {
-					return fmt.Errorf("expected an A record but got %s", ep.RecordType)
+				if expected := addressRecordType(expectedIPs[0]); ep.RecordType != expected {
+					return fmt.Errorf("expected an %s record but got %s", expected, ep.RecordType)
 				}
 				for _, ip := range expectedIPs {
 					if !slice.ContainsString(ep.Targets, ip) {
 						return fmt.Errorf("ip %s not in targets ", ip)
 					}
 				}
+				for name, value := range expectedProviderSpecific {
+					if got, _ := ep.GetProviderSpecific(name); got != value {
+						return fmt.Errorf("expected provider specific property %s to be %q but got %q", name, value, got)
+					}
+				}
 			}
 			return nil
 		}
@@ -122,8 +132,11 @@ func TestDNSReconciler(t *testing.T) {
 		getDNS         func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error)
 		validateResult func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error
 		ingressStatus  networkingv1.IngressStatus
+		annotations    map[string]string
 		expectedIPs    []string
-		DNSLookup      func(ctx context.Context, host string) ([]dns.HostAddress, error)
+		// expectedProviderSpecific are provider specific properties expected on all the endpoints
+		expectedProviderSpecific map[string]string
+		DNSLookup                func(ctx context.Context, host string) ([]dns.HostAddress, error)
 	}{
 		{
 			Name: "test DNSRecord is created when it doesn't exist with no endpoints",
@@ -195,6 +208,41 @@ func TestDNSReconciler(t *testing.T) {
 			},
 			expectedIPs: []string{"192.168.33.3"},
 		},
+		{
+			Name: "test DNSRecord is created with an AAAA record when an IPv6 address is returned",
+			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+				return &v1.DNSRecord{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							ANNOTATION_HCG_HOST: managedHost},
+					},
+					Spec: v1.DNSRecordSpec{
+						Endpoints: []*v1.Endpoint{},
+					},
+				}, nil
+			},
+			ingressStatus: networkingv1.IngressStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{
+						IP: "2001:db8::1",
+					},
+					},
+				},
+			},
+			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+				return nil, fmt.Errorf("DNSLookup should not have been called")
+			},
+			expectedIPs: []string{"2001:db8::1"},
+			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
+				if status != ReconcileStatusContinue || err != nil {
+					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
+				}
+				if dnsClient.updateCalled != 1 {
+					return fmt.Errorf("expected update dns to be called 1 time but was called %d", dnsClient.updateCalled)
+				}
+				return nil
+			},
+		},
 		{
 			Name: "test DNSRecord is created with correct values when it a host is returned",
 			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
@@ -235,20 +283,65 @@ func TestDNSReconciler(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			Name: "test DNSRecord is created with an alias record when an AWS load balancer host is returned",
+			getDNS: func(ctx context.Context, accessor Interface) (*v1.DNSRecord, error) {
+				return &v1.DNSRecord{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							ANNOTATION_HCG_HOST: managedHost},
+					},
+					Spec: v1.DNSRecordSpec{
+						Endpoints: []*v1.Endpoint{},
+					},
+				}, nil
+			},
+			ingressStatus: networkingv1.IngressStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{
+					Ingress: []corev1.LoadBalancerIngress{{
+						Hostname: "lb-1234.us-east-1.elb.amazonaws.com",
+					},
+					},
+				},
+			},
+			annotations: map[string]string{
+				ANNOTATION_AWS_ALIAS: "true",
+			},
+			DNSLookup: func(ctx context.Context, host string) ([]dns.HostAddress, error) {
+				return nil, fmt.Errorf("DNSLookup should not have been called")
+			},
+			expectedIPs: []string{"lb-1234.us-east-1.elb.amazonaws.com"},
+			expectedProviderSpecific: map[string]string{
+				aws.ProviderSpecificAlias:                "true",
+				aws.ProviderSpecificEvaluateTargetHealth: "true",
+			},
+			validateResult: func(status ReconcileStatus, dnsClient *validatedDNSClient, err error) error {
+				if status != ReconcileStatusContinue || err != nil {
+					return fmt.Errorf("expected Reconcile status to be %v got %v. Expected err to be nil got %v", ReconcileStatusContinue, status, err)
+				}
+				if dnsClient.createCalled != 0 {
+					return fmt.Errorf("expected create dns to be called 0 times but was called %d", dnsClient.createCalled)
+				}
+				if dnsClient.updateCalled != 1 {
+					return fmt.Errorf("expected update dns to be called 1 time but was called %d", dnsClient.updateCalled)
+				}
+				return nil
+			},
+		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.Name, func(t *testing.T) {
-			for _, acc := range setupAccessors(tc.ingressStatus) {
+			for _, acc := range setupAccessors(tc.ingressStatus, tc.annotations) {
 				fake := &validatedDNSClient{}
 				rec := &DnsReconciler{
 					GetDNS:           fake.get(tc.getDNS),
-					CreateDNS:        fake.create(commonDNSValidate(tc.expectedIPs)),
-					UpdateDNS:        fake.update(commonDNSValidate(tc.expectedIPs)),
+					CreateDNS:        fake.create(commonDNSValidate(tc.expectedIPs, tc.expectedProviderSpecific)),
+					UpdateDNS:        fake.update(commonDNSValidate(tc.expectedIPs, tc.expectedProviderSpecific)),
 					ListHostWatchers: fakewatcher,
 					Log:              log.New(),
 					DNSLookup:        tc.DNSLookup,
-					WatchHost: func(ctx context.Context, key interface{}, host string) bool {
+					WatchHost: func(ctx context.Context, key interface{}, host string, recordTTL time.Duration) bool {
 						return true
 					},
 				}
@@ -263,6 +356,133 @@ func TestDNSReconciler(t *testing.T) {
 
 }
 
+func Test_setEndpointFromTargetsGeo(t *testing.T) {
+	dnsName := "xyz.dev.hcpapps.net"
+	geo := func(code string) *dns.Geo {
+		return &dns.Geo{ContinentCode: code}
+	}
+	region := func(region string) *dns.Geo {
+		return &dns.Geo{Region: region}
+	}
+	type endpoint struct {
+		dnsName          string
+		recordType       string
+		setIdentifier    string
+		target           string
+		providerSpecific map[string]string
+	}
+	tests := []struct {
+		name      string
+		policy    RoutingPolicy
+		targets   map[string][]string
+		hosts     map[string]*targetHost
+		endpoints []endpoint
+	}{
+		{
+			name:    "flat weighted records when geo aware DNS is disabled",
+			targets: map[string][]string{"192.168.0.1": {"192.168.0.1"}, "192.168.0.2": {"192.168.0.2"}},
+			hosts: map[string]*targetHost{
+				"192.168.0.1": {cluster: "c1"},
+				"192.168.0.2": {cluster: "c2"},
+			},
+			endpoints: []endpoint{
+				{dnsName: dnsName, recordType: "A", setIdentifier: "192.168.0.1", target: "192.168.0.1", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "120"}},
+				{dnsName: dnsName, recordType: "A", setIdentifier: "192.168.0.2", target: "192.168.0.2", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "120"}},
+			},
+		},
+		{
+			name:    "flat weighted records when a cluster is not located",
+			targets: map[string][]string{"192.168.0.1": {"192.168.0.1"}, "192.168.0.2": {"192.168.0.2"}},
+			hosts: map[string]*targetHost{
+				"192.168.0.1": {cluster: "c1", geo: geo("NA")},
+				"192.168.0.2": {cluster: "c2"},
+			},
+			endpoints: []endpoint{
+				{dnsName: dnsName, recordType: "A", setIdentifier: "192.168.0.1", target: "192.168.0.1"},
+				{dnsName: dnsName, recordType: "A", setIdentifier: "192.168.0.2", target: "192.168.0.2"},
+			},
+		},
+		{
+			name: "continent records when all the clusters are located",
+			targets: map[string][]string{
+				"192.168.0.1":    {"192.168.0.1"},
+				"lb.example.com": {"192.168.1.1", "192.168.1.2"},
+				"192.168.2.1":    {"192.168.2.1"},
+			},
+			hosts: map[string]*targetHost{
+				"192.168.0.1":    {cluster: "c1", geo: geo("NA")},
+				"lb.example.com": {cluster: "c2", geo: geo("EU")},
+				"192.168.2.1":    {cluster: "c3", geo: geo("NA")},
+			},
+			endpoints: []endpoint{
+				{dnsName: "xyz.na.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.0.1", target: "192.168.0.1", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "120", aws.ProviderSpecificHealthCheckHost: dnsName}},
+				{dnsName: "xyz.eu.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.1.1", target: "192.168.1.1", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "60", aws.ProviderSpecificHealthCheckHost: dnsName}},
+				{dnsName: "xyz.eu.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.1.2", target: "192.168.1.2", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "60"}},
+				{dnsName: "xyz.na.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.2.1", target: "192.168.2.1", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "120"}},
+				{dnsName: dnsName, recordType: "CNAME", setIdentifier: "EU", target: "xyz.eu.dev.hcpapps.net", providerSpecific: map[string]string{aws.ProviderSpecificGeolocationContinentCode: "EU"}},
+				// Both continents have 2 records, the default is the first one alphabetically
+				{dnsName: dnsName, recordType: "CNAME", setIdentifier: "default", target: "xyz.eu.dev.hcpapps.net", providerSpecific: map[string]string{aws.ProviderSpecificGeolocationCountryCode: "*"}},
+				{dnsName: dnsName, recordType: "CNAME", setIdentifier: "NA", target: "xyz.na.dev.hcpapps.net", providerSpecific: map[string]string{aws.ProviderSpecificGeolocationContinentCode: "NA"}},
+			},
+		},
+		{
+			name:    "flat weighted records when a cluster region is not known",
+			policy:  RoutingPolicyLatency,
+			targets: map[string][]string{"192.168.0.1": {"192.168.0.1"}, "192.168.0.2": {"192.168.0.2"}},
+			hosts: map[string]*targetHost{
+				"192.168.0.1": {cluster: "c1", geo: region("us-east-1")},
+				"192.168.0.2": {cluster: "c2", geo: geo("EU")},
+			},
+			endpoints: []endpoint{
+				{dnsName: dnsName, recordType: "A", setIdentifier: "192.168.0.1", target: "192.168.0.1"},
+				{dnsName: dnsName, recordType: "A", setIdentifier: "192.168.0.2", target: "192.168.0.2"},
+			},
+		},
+		{
+			name:   "region records with the latency routing policy",
+			policy: RoutingPolicyLatency,
+			targets: map[string][]string{
+				"192.168.0.1":    {"192.168.0.1"},
+				"lb.example.com": {"192.168.1.1", "192.168.1.2"},
+				"192.168.2.1":    {"192.168.2.1"},
+			},
+			hosts: map[string]*targetHost{
+				"192.168.0.1":    {cluster: "c1", geo: &dns.Geo{ContinentCode: "NA", Region: "us-east-1"}},
+				"lb.example.com": {cluster: "c2", geo: region("eu-west-1")},
+				"192.168.2.1":    {cluster: "c3", geo: region("us-east-1")},
+			},
+			endpoints: []endpoint{
+				{dnsName: "xyz.us-east-1.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.0.1", target: "192.168.0.1", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "120", aws.ProviderSpecificHealthCheckHost: dnsName}},
+				{dnsName: "xyz.eu-west-1.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.1.1", target: "192.168.1.1", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "60"}},
+				{dnsName: "xyz.eu-west-1.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.1.2", target: "192.168.1.2", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "60"}},
+				{dnsName: "xyz.us-east-1.dev.hcpapps.net", recordType: "A", setIdentifier: "192.168.2.1", target: "192.168.2.1", providerSpecific: map[string]string{aws.ProviderSpecificWeight: "120"}},
+				{dnsName: dnsName, recordType: "CNAME", setIdentifier: "eu-west-1", target: "xyz.eu-west-1.dev.hcpapps.net", providerSpecific: map[string]string{aws.ProviderSpecificRegion: "eu-west-1"}},
+				{dnsName: dnsName, recordType: "CNAME", setIdentifier: "us-east-1", target: "xyz.us-east-1.dev.hcpapps.net", providerSpecific: map[string]string{aws.ProviderSpecificRegion: "us-east-1"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &v1.DNSRecord{}
+			(&DnsReconciler{}).setEndpointFromTargets(dnsName, tt.policy, tt.targets, tt.hosts, record)
+			if len(record.Spec.Endpoints) != len(tt.endpoints) {
+				t.Fatalf("expected %d endpoints, got %d: %v", len(tt.endpoints), len(record.Spec.Endpoints), record.Spec.Endpoints)
+			}
+			for i, expected := range tt.endpoints {
+				got := record.Spec.Endpoints[i]
+				if got.DNSName != expected.dnsName || got.RecordType != expected.recordType || got.SetIdentifier != expected.setIdentifier || got.Targets[0] != expected.target {
+					t.Errorf("expected endpoint %d to be %v, got %v", i, expected, got)
+				}
+				for name, value := range expected.providerSpecific {
+					if v, _ := got.GetProviderSpecific(name); v != value {
+						t.Errorf("expected endpoint %d provider specific property %s to be %q, got %q", i, name, value, v)
+					}
+				}
+			}
+		})
+	}
+}
+
 func Test_awsEndpointWeight(t *testing.T) {
 	type args struct {
 		numIPs int
@@ -338,6 +558,146 @@ func Test_awsEndpointWeight(t *testing.T) {
 	}
 }
 
+func Test_setEndpointFromTargetsFailover(t *testing.T) {
+	dnsName := "xyz.dev.hcpapps.net"
+	targets := map[string][]string{
+		"192.168.0.1":    {"192.168.0.1"},
+		"lb.example.com": {"192.168.1.2", "192.168.1.1"},
+	}
+	hosts := map[string]*targetHost{
+		"192.168.0.1":    {cluster: "c1", primary: true},
+		"lb.example.com": {cluster: "c2"},
+	}
+	record := &v1.DNSRecord{}
+	(&DnsReconciler{}).setEndpointFromTargets(dnsName, RoutingPolicyFailover, targets, hosts, record)
+
+	if len(record.Spec.Endpoints) != 2 {
+		t.Fatalf("expected a primary and a secondary endpoint, got %v", record.Spec.Endpoints)
+	}
+	primary, secondary := record.Spec.Endpoints[0], record.Spec.Endpoints[1]
+	if failover, _ := primary.GetProviderSpecific(aws.ProviderSpecificFailover); failover != aws.FailoverPrimary || primary.SetIdentifier != "primary" || len(primary.Targets) != 1 || primary.Targets[0] != "192.168.0.1" {
+		t.Errorf("unexpected primary endpoint %v", primary)
+	}
+	if failover, _ := secondary.GetProviderSpecific(aws.ProviderSpecificFailover); failover != aws.FailoverSecondary || secondary.SetIdentifier != "secondary" || len(secondary.Targets) != 2 || secondary.Targets[0] != "192.168.1.1" {
+		t.Errorf("unexpected secondary endpoint %v", secondary)
+	}
+	if _, ok := primary.GetProviderSpecific(aws.ProviderSpecificWeight); ok {
+		t.Errorf("expected failover endpoints not to be weighted")
+	}
+
+	// Weighted records are published while the primary cluster has no target
+	hosts["192.168.0.1"].primary = false
+	(&DnsReconciler{}).setEndpointFromTargets(dnsName, RoutingPolicyFailover, targets, hosts, record)
+	if len(record.Spec.Endpoints) != 3 {
+		t.Fatalf("expected 3 weighted endpoints, got %v", record.Spec.Endpoints)
+	}
+	for _, endpoint := range record.Spec.Endpoints {
+		if _, ok := endpoint.GetProviderSpecific(aws.ProviderSpecificFailover); ok {
+			t.Errorf("expected endpoint %v not to be a failover endpoint", endpoint)
+		}
+	}
+}
+
+func Test_setEndpointFromTargetsFailoverIPv6(t *testing.T) {
+	dnsName := "xyz.dev.hcpapps.net"
+	targets := map[string][]string{
+		"lb1.example.com": {"192.168.0.1", "2001:db8::1"},
+		"lb2.example.com": {"2001:db8::2"},
+	}
+	hosts := map[string]*targetHost{
+		"lb1.example.com": {cluster: "c1", primary: true},
+		"lb2.example.com": {cluster: "c2"},
+	}
+	record := &v1.DNSRecord{}
+	(&DnsReconciler{}).setEndpointFromTargets(dnsName, RoutingPolicyFailover, targets, hosts, record)
+
+	expected := []struct {
+		failover, recordType, target string
+	}{
+		{aws.FailoverPrimary, "A", "192.168.0.1"},
+		{aws.FailoverPrimary, "AAAA", "2001:db8::1"},
+		{aws.FailoverSecondary, "AAAA", "2001:db8::2"},
+	}
+	if len(record.Spec.Endpoints) != len(expected) {
+		t.Fatalf("expected %d endpoints, got %v", len(expected), record.Spec.Endpoints)
+	}
+	for i, endpoint := range record.Spec.Endpoints {
+		failover, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificFailover)
+		if failover != expected[i].failover || endpoint.RecordType != expected[i].recordType || len(endpoint.Targets) != 1 || endpoint.Targets[0] != expected[i].target {
+			t.Errorf("expected %s %s endpoint targeting %s, got %v", expected[i].failover, expected[i].recordType, expected[i].target, endpoint)
+		}
+	}
+}
+
+func Test_awsClusterEndpointWeight(t *testing.T) {
+	tests := []struct {
+		name             string
+		clusterWeight    int
+		maxClusterWeight int
+		numIPs           int
+		want             string
+	}{
+		{name: "default weight", clusterWeight: DefaultClusterWeight, maxClusterWeight: DefaultClusterWeight, numIPs: 2, want: "60"},
+		{name: "canary cluster", clusterWeight: 10, maxClusterWeight: 90, numIPs: 1, want: "13"},
+		{name: "canary cluster with more IPs than its allowance", clusterWeight: 10, maxClusterWeight: 90, numIPs: 20, want: "1"},
+		{name: "tiny weight is never drained", clusterWeight: 1, maxClusterWeight: 255, numIPs: 1, want: "1"},
+		{name: "drained cluster", clusterWeight: 0, maxClusterWeight: 100, numIPs: 2, want: "0"},
+		{name: "all clusters drained", clusterWeight: 0, maxClusterWeight: 0, numIPs: 1, want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := awsClusterEndpointWeight(tt.clusterWeight, tt.maxClusterWeight, tt.numIPs); got != tt.want {
+				t.Errorf("awsClusterEndpointWeight() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setEndpointFromTargetsWeights(t *testing.T) {
+	weight := func(w int) *int {
+		return &w
+	}
+	targets := map[string][]string{
+		"192.168.0.1":    {"192.168.0.1"},
+		"lb.example.com": {"192.168.1.1", "192.168.1.2"},
+		"192.168.2.1":    {"192.168.2.1"},
+	}
+	hosts := map[string]*targetHost{
+		"192.168.0.1":    {cluster: "c1", weight: weight(90)},
+		"lb.example.com": {cluster: "c2"},
+		"192.168.2.1":    {cluster: "c3", weight: weight(0)},
+	}
+	record := &v1.DNSRecord{}
+	(&DnsReconciler{}).setEndpointFromTargets("xyz.dev.hcpapps.net", RoutingPolicyWeighted, targets, hosts, record)
+
+	expected := map[string]string{
+		"192.168.0.1": "108",
+		"192.168.1.1": "60",
+		"192.168.1.2": "60",
+		// The records of the drained cluster are kept
+		"192.168.2.1": "0",
+	}
+	if len(record.Spec.Endpoints) != len(expected) {
+		t.Fatalf("expected %d endpoints, got %v", len(expected), record.Spec.Endpoints)
+	}
+	for _, endpoint := range record.Spec.Endpoints {
+		if got, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificWeight); got != expected[endpoint.Targets[0]] {
+			t.Errorf("expected endpoint %s weight to be %q, got %q", endpoint.Targets[0], expected[endpoint.Targets[0]], got)
+		}
+	}
+
+	// The weights of the existing endpoints are updated
+	hosts["192.168.0.1"].weight = weight(0)
+	(&DnsReconciler{}).setEndpointFromTargets("xyz.dev.hcpapps.net", RoutingPolicyWeighted, targets, hosts, record)
+	expected["192.168.0.1"] = "0"
+	expected["192.168.1.1"] = "60"
+	for _, endpoint := range record.Spec.Endpoints {
+		if got, _ := endpoint.GetProviderSpecific(aws.ProviderSpecificWeight); got != expected[endpoint.Targets[0]] {
+			t.Errorf("expected endpoint %s weight to be updated to %q, got %q", endpoint.Targets[0], expected[endpoint.Targets[0]], got)
+		}
+	}
+}
+
 func Test_objectKey(t *testing.T) {
 	type args struct {
 		obj runtime.Object
@@ -379,3 +739,209 @@ func Test_objectKey(t *testing.T) {
 		})
 	}
 }
+
+func Test_routingPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		geoLocator  dns.GeoLocator
+		want        RoutingPolicy
+		wantPrimary string
+	}{
+		{
+			name: "default routing policy",
+		},
+		{
+			name:        "latency",
+			annotations: map[string]string{ANNOTATION_ROUTING_POLICY: string(RoutingPolicyLatency)},
+			geoLocator:  dns.NewSyncTargetGeoLocator(nil),
+			want:        RoutingPolicyLatency,
+		},
+		{
+			name:        "latency without geo aware DNS",
+			annotations: map[string]string{ANNOTATION_ROUTING_POLICY: string(RoutingPolicyLatency)},
+			want:        RoutingPolicyWeighted,
+		},
+		{
+			name: "failover",
+			annotations: map[string]string{
+				ANNOTATION_ROUTING_POLICY:   string(RoutingPolicyFailover),
+				ANNOTATION_FAILOVER_PRIMARY: "c1",
+				ANNOTATION_HEALTH_CHECK_REF: "hc",
+			},
+			want:        RoutingPolicyFailover,
+			wantPrimary: "c1",
+		},
+		{
+			name: "failover without primary cluster",
+			annotations: map[string]string{
+				ANNOTATION_ROUTING_POLICY:   string(RoutingPolicyFailover),
+				ANNOTATION_HEALTH_CHECK_REF: "hc",
+			},
+			want: RoutingPolicyWeighted,
+		},
+		{
+			name: "failover without health checks",
+			annotations: map[string]string{
+				ANNOTATION_ROUTING_POLICY:   string(RoutingPolicyFailover),
+				ANNOTATION_FAILOVER_PRIMARY: "c1",
+			},
+			want: RoutingPolicyWeighted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{}
+			ingress.Annotations = tt.annotations
+			reconciler := &DnsReconciler{Log: log.New(), GeoLocator: tt.geoLocator}
+			policy, primary := reconciler.routingPolicy(NewIngress(ingress))
+			if policy != tt.want || primary != tt.wantPrimary {
+				t.Errorf("routingPolicy() = %v, %v, want %v, %v", policy, primary, tt.want, tt.wantPrimary)
+			}
+		})
+	}
+}
+
+func Test_recordTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    string
+		bounds TTLBounds
+		want   v1.TTL
+	}{
+		{
+			name: "default TTL",
+			want: DefaultTTL,
+		},
+		{
+			name: "annotation TTL",
+			ttl:  "300",
+			want: 300,
+		},
+		{
+			name: "invalid annotation TTL",
+			ttl:  "5m",
+			want: DefaultTTL,
+		},
+		{
+			name: "negative annotation TTL",
+			ttl:  "-1",
+			want: DefaultTTL,
+		},
+		{
+			name:   "annotation TTL below the minimum",
+			ttl:    "5",
+			bounds: TTLBounds{Min: 10, Max: 3600},
+			want:   10,
+		},
+		{
+			name:   "annotation TTL above the maximum",
+			ttl:    "86400",
+			bounds: TTLBounds{Min: 10, Max: 3600},
+			want:   3600,
+		},
+		{
+			name:   "default TTL below the minimum",
+			bounds: TTLBounds{Min: 120},
+			want:   120,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := &networkingv1.Ingress{}
+			if tt.ttl != "" {
+				ingress.Annotations = map[string]string{ANNOTATION_TTL: tt.ttl}
+			}
+			reconciler := &DnsReconciler{Log: log.New(), TTLBounds: tt.bounds}
+			if got := reconciler.recordTTL(NewIngress(ingress)); got != tt.want {
+				t.Errorf("recordTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setEndpointsHealthCheckRef(t *testing.T) {
+	alias := &v1.Endpoint{DNSName: "alias.example.com", RecordType: "A", Targets: v1.Targets{"lb.example.com"}}
+	alias.SetProviderSpecific(aws.ProviderSpecificAlias, "true")
+	record := &v1.DNSRecord{Spec: v1.DNSRecordSpec{Endpoints: []*v1.Endpoint{
+		{DNSName: "echo.example.com", RecordType: "A", Targets: v1.Targets{"192.168.0.1"}},
+		{DNSName: "echo.example.com", RecordType: "CNAME", Targets: v1.Targets{"eu.echo.example.com"}},
+		alias,
+	}}}
+
+	setEndpointsHealthCheckRef(record, "echo")
+	if ref := record.Spec.Endpoints[0].HealthCheckRef; ref == nil || ref.Name != "echo" {
+		t.Fatalf("expected the A record to reference the HealthCheck, got %v", ref)
+	}
+	if record.Spec.Endpoints[1].HealthCheckRef != nil || record.Spec.Endpoints[2].HealthCheckRef != nil {
+		t.Fatalf("expected the CNAME and alias records not to reference the HealthCheck")
+	}
+
+	setEndpointsHealthCheckRef(record, "")
+	if record.Spec.Endpoints[0].HealthCheckRef != nil {
+		t.Fatalf("expected the HealthCheck reference to be removed")
+	}
+}
+
+func Test_setEndpointsCluster(t *testing.T) {
+	dnsName := "xyz.dev.hcpapps.net"
+	targets := map[string][]string{
+		"192.168.0.1":    {"192.168.0.1"},
+		"lb.example.com": {"192.168.1.2", "192.168.1.1"},
+	}
+	hosts := map[string]*targetHost{
+		"192.168.0.1":    {cluster: "c1", primary: true},
+		"lb.example.com": {cluster: "c2"},
+	}
+	record := &v1.DNSRecord{}
+	reconciler := &DnsReconciler{}
+
+	reconciler.setEndpointFromTargets(dnsName, RoutingPolicyWeighted, targets, hosts, record)
+	setEndpointsCluster(record, targets, hosts)
+	clusters := map[string]string{}
+	for _, endpoint := range record.Spec.Endpoints {
+		clusters[endpoint.Targets[0]] = endpoint.Labels[v1.ClusterLabel]
+	}
+	if clusters["192.168.0.1"] != "c1" || clusters["192.168.1.1"] != "c2" || clusters["192.168.1.2"] != "c2" {
+		t.Fatalf("expected the endpoints to be labelled with the cluster of their target, got %v", clusters)
+	}
+
+	reconciler.setEndpointFromTargets(dnsName, RoutingPolicyFailover, targets, hosts, record)
+	setEndpointsCluster(record, targets, hosts)
+	primary, secondary := record.Spec.Endpoints[0], record.Spec.Endpoints[1]
+	if primary.Labels[v1.ClusterLabel] != "c1" || secondary.Labels[v1.ClusterLabel] != "c2" {
+		t.Errorf("expected the failover endpoints to be labelled with clusters c1 and c2, got %v and %v", primary.Labels, secondary.Labels)
+	}
+
+	// The label is removed from the endpoints targeting several clusters
+	record.Spec.Endpoints = []*v1.Endpoint{{DNSName: dnsName, RecordType: "A", Targets: v1.Targets{"192.168.0.1", "192.168.1.1"}, Labels: v1.Labels{v1.ClusterLabel: "c1"}}}
+	setEndpointsCluster(record, targets, hosts)
+	if record.Spec.Endpoints[0].Labels != nil {
+		t.Errorf("expected the cluster label to be removed from an endpoint targeting several clusters, got %v", record.Spec.Endpoints[0].Labels)
+	}
+}
+
+func Test_setClusterHealth(t *testing.T) {
+	accessor := NewIngress(&networkingv1.Ingress{})
+	clusters := []v1.ClusterHealth{{Cluster: "c1", Health: v1.EndpointHealthy, Endpoints: 1, HealthyEndpoints: 1}}
+
+	if err := setClusterHealth(accessor, clusters); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var annotated []v1.ClusterHealth
+	if err := json.Unmarshal([]byte(accessor.GetAnnotations()[ANNOTATION_CLUSTER_HEALTH]), &annotated); err != nil {
+		t.Fatalf("expected the cluster health annotation to be set: %v", err)
+	}
+	if len(annotated) != 1 || annotated[0] != clusters[0] {
+		t.Fatalf("unexpected cluster health annotation %v", annotated)
+	}
+
+	if err := setClusterHealth(accessor, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := accessor.GetAnnotations()[ANNOTATION_CLUSTER_HEALTH]; ok {
+		t.Fatalf("expected the cluster health annotation to be removed")
+	}
+}
@@ -31,12 +31,33 @@ const (
 	ANNOTATION_CERTIFICATE_STATE        = "kuadrant.dev/certificate-status"
 	ANNOTATION_HCG_HOST                 = "kuadrant.dev/host.generated"
 	ANNOTATION_HEALTH_CHECK_PREFIX      = "kuadrant.experimental/health-"
+	ANNOTATION_HEALTH_CHECK_REF         = "kuadrant.experimental/healthcheck"
+	ANNOTATION_AWS_ALIAS                = "kuadrant.experimental/aws-alias"
+	ANNOTATION_ROUTING_POLICY           = "kuadrant.experimental/routing-policy"
+	ANNOTATION_WEIGHT_PREFIX            = "kuadrant.experimental/weight-"
+	ANNOTATION_FAILOVER_PRIMARY         = "kuadrant.experimental/failover-primary"
+	ANNOTATION_TTL                      = "kuadrant.experimental/ttl"
 	ANNOTATION_HCG_CUSTOM_HOST_REPLACED = "kuadrant.dev/custom-hosts-status.removed"
 	ANNOTATION_PENDING_CUSTOM_HOSTS     = "kuadrant.dev/pendingCustomHosts"
+	ANNOTATION_CLUSTER_HEALTH           = "kuadrant.dev/cluster-health"
 	LABEL_HAS_PENDING_HOSTS             = "kuadrant.dev/hasPendingCustomHosts"
 	FINALIZER_CASCADE_CLEANUP           = "kuadrant.dev/cascade-cleanup"
 )
 
+// RoutingPolicy is how DNS queries for the managed host are routed to the clusters, set with the
+// ANNOTATION_ROUTING_POLICY annotation on the traffic object
+type RoutingPolicy string
+
+const (
+	// RoutingPolicyWeighted splits the traffic across the clusters. This is the default.
+	RoutingPolicyWeighted RoutingPolicy = "weighted"
+	// RoutingPolicyLatency routes the traffic to the region of the clusters with the lowest latency
+	RoutingPolicyLatency RoutingPolicy = "latency"
+	// RoutingPolicyFailover routes the traffic to the primary cluster, set with the ANNOTATION_FAILOVER_PRIMARY
+	// annotation, while it is healthy, and to the other clusters otherwise
+	RoutingPolicyFailover RoutingPolicy = "failover"
+)
+
 type patch struct {
 	OP    string      `json:"op"`
 	Path  string      `json:"path"`